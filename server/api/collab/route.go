@@ -0,0 +1,20 @@
+package collab
+
+import (
+	"github.com/Fl0rencess720/Springboard/internal/controller"
+	"github.com/Fl0rencess720/Springboard/pkgs/openapi"
+)
+
+func InitAPI(group *openapi.Router, cu *controller.CollabUsecase) {
+	group.GET("/connect", openapi.Operation{
+		Summary: "加入作品集协同编辑",
+		Description: "升级为 WebSocket 连接，只有所有者与评审人可以加入。客户端发送 {type: op, op_id, ops} 或 {type: ping}；" +
+			"服务端先发送快照或补发 since 之后的操作，之后按序号广播各副本上的修改与在线状态；" +
+			"收到 resync 时应不带 since 重连以获取快照",
+		Query: []openapi.Param{
+			{Name: "uid", Description: "作品集 UID", Required: true},
+			{Name: "since", Type: "integer", Description: "客户端已应用的最后序号，重连时填写以补发之后的操作"},
+		},
+		Response: controller.CollabServerMessage{},
+	}, cu.Connect)
+}
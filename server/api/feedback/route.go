@@ -2,12 +2,30 @@ package feedback
 
 import (
 	"github.com/Fl0rencess720/Springboard/internal/controller"
+	"github.com/Fl0rencess720/Springboard/internal/middleware"
+	"github.com/Fl0rencess720/Springboard/pkgs/openapi"
 	"github.com/gin-gonic/gin"
 )
 
-func InitAPI(group *gin.RouterGroup, sc *controller.FeedbackUseCase) {
-	group.POST("/add", sc.AddFeedback)
-	group.GET("/all", sc.GetAllFeedbacks)
-	group.GET("", sc.GetFeedbacksByStatus)
-	group.POST("/update", sc.UpdateFeedbacksStatus)
+func InitAPI(group *openapi.Router, sc *controller.FeedbackUseCase, rl *middleware.RateLimiter) {
+	group.POST("/add", openapi.Operation{
+		Summary: "提交反馈",
+		Body:    controller.AddFeedbackRequest{},
+		Before:  []gin.HandlerFunc{rl.Limit("feedback_add")},
+	}, sc.AddFeedback)
+	group.GET("/all", openapi.Operation{
+		Summary:  "获取全部反馈",
+		Response: []controller.FeedbackResponse{},
+	}, sc.GetAllFeedbacks)
+	group.GET("", openapi.Operation{
+		Summary: "按状态获取反馈",
+		Query: []openapi.Param{
+			{Name: "status", Description: "0 待处理，1 已采纳，2 已拒绝", Type: "integer", Enum: []string{"0", "1", "2"}, Default: "0"},
+		},
+		Response: []controller.FeedbackResponse{},
+	}, sc.GetFeedbacksByStatus)
+	group.POST("/update", openapi.Operation{
+		Summary: "更新反馈状态",
+		Body:    controller.UpdateStatusRequest{},
+	}, sc.UpdateFeedbacksStatus)
 }
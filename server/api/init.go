@@ -3,35 +3,74 @@ package api
 import (
 	"time"
 
+	"github.com/Fl0rencess720/Springboard/api/collab"
 	"github.com/Fl0rencess720/Springboard/api/feedback"
 	"github.com/Fl0rencess720/Springboard/api/oss"
 	"github.com/Fl0rencess720/Springboard/api/portfolio"
+	"github.com/Fl0rencess720/Springboard/api/review"
+	"github.com/Fl0rencess720/Springboard/api/share"
+	"github.com/Fl0rencess720/Springboard/internal/conf"
 	"github.com/Fl0rencess720/Springboard/internal/controller"
 	"github.com/Fl0rencess720/Springboard/internal/middleware"
+	"github.com/Fl0rencess720/Springboard/pkgs/openapi"
 
 	ginZap "github.com/gin-contrib/zap"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-func Init(au *controller.AuthUsecase, pu *controller.PortfolioUsecase, sc *controller.FeedbackUseCase, ou *controller.OSSUsecase) *gin.Engine {
+func Init(au *controller.AuthUsecase, pu *controller.PortfolioUsecase, sc *controller.FeedbackUseCase, ou *controller.OSSUsecase, ru *controller.ReviewUsecase, cu *controller.CollabUsecase, rl *middleware.RateLimiter) *gin.Engine {
 	e := gin.New()
+	// gin 默认信任所有代理，客户端伪造 X-Forwarded-For 即可绕过按 IP 的限流
+	if err := e.SetTrustedProxies(conf.Get().Server.TrustedProxies); err != nil {
+		zap.L().Panic("SetTrustedProxies error", zap.Error(err))
+	}
 	e.Use(gin.Logger(), gin.Recovery(), ginZap.Ginzap(zap.L(), time.RFC3339, false), ginZap.RecoveryWithZap(zap.L(), false))
-	auth := e.Group("/api")
+	// 跨域策略作用于所有路由，包括登录、刷新以及文档
+	e.Use(middleware.Cors())
+
+	spec := openapi.NewSpec("Springboard API", "1.0.0")
+	spec.OnInvalid = controller.InvalidParamsResponse
+	e.GET("/api/openapi.json", spec.Handler())
+	e.GET("/api/docs", openapi.UIHandler("/api/openapi.json"))
+
+	auth := spec.Router(e.Group("/api"), "auth", false)
 	{
-		auth.POST("/login", au.Login)
+		auth.POST("/login", openapi.Operation{
+			Summary: "微信小程序登录",
+			Query: []openapi.Param{
+				{Name: "code", Description: "wx.login 获取的 code", Required: true},
+			},
+			Response: controller.TokenResponse{},
+			Before:   []gin.HandlerFunc{rl.Limit("login")},
+		}, au.Login)
 
 		// auth.POST("/register/app", au.AppRegister)
 		// auth.POST("/login/app", au.AppLogin)
 
-		auth.GET("/refresh", au.RefreshAccessToken)
+		auth.GET("/refresh", openapi.Operation{
+			Summary:     "刷新 Access Token",
+			Description: "Authorization 头携带过期的 Access Token",
+			Query: []openapi.Param{
+				{Name: "refresh_token", Description: "Refresh Token", Required: true},
+			},
+			Response: controller.TokenResponse{},
+		}, au.RefreshAccessToken)
+	}
+
+	// 公开接口无需登录，通过分享链接的 token 访问
+	public := spec.Router(e.Group("/api/public"), "public", false)
+	{
+		share.InitAPI(public, pu, rl)
 	}
 
-	app := e.Group("/api", middleware.Cors(), middleware.Auth())
+	app := spec.Router(e.Group("/api", middleware.Auth()), "", true)
 	{
-		oss.InitAPI(app.Group("/oss"), ou)
-		portfolio.InitAPI(app.Group("/portfolio"), pu)
-		feedback.InitAPI(app.Group("/feedback"), sc)
+		oss.InitAPI(app.Group("/oss", "oss"), ou, rl)
+		portfolio.InitAPI(app.Group("/portfolio", "portfolio"), pu, cu)
+		feedback.InitAPI(app.Group("/feedback", "feedback"), sc, rl)
+		review.InitAPI(app.Group("/review", "review"), ru)
+		collab.InitAPI(app.Group("/collab", "collab"), cu)
 	}
 
 	return e
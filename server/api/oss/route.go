@@ -2,11 +2,91 @@ package oss
 
 import (
 	"github.com/Fl0rencess720/Springboard/internal/controller"
+	"github.com/Fl0rencess720/Springboard/internal/middleware"
+	"github.com/Fl0rencess720/Springboard/pkgs/openapi"
 	"github.com/gin-gonic/gin"
 )
 
-func InitAPI(group *gin.RouterGroup, ou *controller.OSSUsecase) {
+func InitAPI(group *openapi.Router, ou *controller.OSSUsecase, rl *middleware.RateLimiter) {
 	// group.GET("/sts", ou.GetCredentials)
-	group.GET("/sts/upload", ou.GetUploadSignedUrl)
-	group.GET("/sts/preview", ou.GetPreviewSignedUrl)
+	group.GET("/sts/upload", openapi.Operation{
+		Summary:     "获取上传预签名 URL",
+		Description: "上传时需携带响应中 headers 的全部请求头，文件类型与大小受 purpose 对应的策略限制",
+		Query: []openapi.Param{
+			{Name: "purpose", Description: "上传用途", Enum: []string{
+				controller.PurposeWork, controller.PurposeAvatar, controller.PurposeFeedback, controller.PurposeFont,
+			}, Default: controller.PurposeWork},
+			{Name: "contentType", Description: "文件的 MIME 类型", Required: true},
+			{Name: "size", Description: "文件字节数", Type: "integer", Required: true},
+		},
+		Response: controller.UploadSignedUrlResponse{},
+		Before:   []gin.HandlerFunc{rl.Limit("oss_upload")},
+	}, ou.GetUploadSignedUrl)
+	group.POST("/upload/confirm", openapi.Operation{
+		Summary:     "确认上传完成",
+		Description: "服务端通过 HEAD 检查对象已存在，大小或类型与申请时不一致时删除对象与上传记录，需要重新申请上传",
+		Body:        controller.ConfirmUploadRequest{},
+		Response:    controller.UploadResponse{},
+	}, ou.ConfirmUpload)
+	group.GET("/upload", openapi.Operation{
+		Summary:     "查询上传记录",
+		Description: "图片上传确认后在后台处理，可据此轮询 process_status 以及真实尺寸、DPI",
+		Query: []openapi.Param{
+			{Name: "ossKey", Description: "对象 key", Required: true},
+		},
+		Response: controller.UploadResponse{},
+	}, ou.GetUpload)
+	group.GET("/sts/preview", openapi.Operation{
+		Summary:     "获取预览预签名 URL",
+		Description: "仅允许访问自己上传的文件、自己作品集引用的文件以及模板公共资源",
+		Query: []openapi.Param{
+			{Name: "ossKey", Description: "对象 key", Required: true},
+			{Name: "variant", Description: "图片变体，缩略图未生成时返回原图", Enum: []string{
+				controller.VariantOriginal, controller.VariantPreview, controller.VariantThumb,
+			}, Default: controller.VariantOriginal},
+		},
+		Response: controller.PreviewSignedUrlResponse{},
+	}, ou.GetPreviewSignedUrl)
+	group.POST("/sts/preview/batch", openapi.Operation{
+		Summary:     "批量获取预览预签名 URL",
+		Description: "一次最多 200 个 key，无权访问的 key 列在 denied 中；同一对象在有效期内返回相同的 URL",
+		Body:        controller.BatchPreviewRequest{},
+		Response:    controller.BatchPreviewResponse{},
+		Before:      []gin.HandlerFunc{rl.Limit("oss_preview_batch")},
+	}, ou.BatchGetPreviewSignedUrl)
+	group.POST("/multipart/initiate", openapi.Operation{
+		Summary:     "初始化分片上传",
+		Description: "适用于大文件，响应中的 part_size、part_count 决定客户端的分片方式，文件类型与大小受 purpose 对应的策略限制",
+		Body:        controller.InitiateMultipartRequest{},
+		Response:    controller.InitiateMultipartResponse{},
+		Before:      []gin.HandlerFunc{rl.Limit("oss_upload")},
+	}, ou.InitiateMultipartUpload)
+	group.GET("/multipart/part", openapi.Operation{
+		Summary:     "获取分片上传预签名 URL",
+		Description: "客户端使用 PUT 上传分片",
+		Query: []openapi.Param{
+			{Name: "ossKey", Description: "对象 key", Required: true},
+			{Name: "partNumber", Description: "分片序号，从 1 开始", Type: "integer", Required: true},
+		},
+		Response: controller.UploadPartUrlResponse{},
+	}, ou.GetUploadPartUrl)
+	group.GET("/multipart/parts", openapi.Operation{
+		Summary:     "查询已上传的分片",
+		Description: "上传中断后据此跳过已完成的分片继续上传",
+		Query: []openapi.Param{
+			{Name: "ossKey", Description: "对象 key", Required: true},
+		},
+		Response: controller.ListPartsResponse{},
+	}, ou.ListUploadedParts)
+	group.POST("/multipart/complete", openapi.Operation{
+		Summary:     "完成分片上传",
+		Description: "合并全部已上传分片，随后与普通上传一样确认对象并开始图片处理",
+		Body:        controller.MultipartRequest{},
+		Response:    controller.UploadResponse{},
+	}, ou.CompleteMultipartUpload)
+	group.POST("/multipart/abort", openapi.Operation{
+		Summary:     "取消分片上传",
+		Description: "删除已上传的分片与上传记录",
+		Body:        controller.MultipartRequest{},
+	}, ou.AbortMultipartUpload)
 }
@@ -2,15 +2,136 @@ package portfolio
 
 import (
 	"github.com/Fl0rencess720/Springboard/internal/controller"
-	"github.com/gin-gonic/gin"
+	"github.com/Fl0rencess720/Springboard/pkgs/openapi"
 )
 
-func InitAPI(group *gin.RouterGroup, pu *controller.PortfolioUsecase) {
-	group.GET("/template/all", pu.GetAllTemplates)
-	group.GET("/template/", pu.GetTemplateByUID)
-	group.GET("/template/hot", pu.GetHotTemplates)
-	group.POST("/portfolio/save", pu.SavePortfolio)
-	group.GET("/portfolio/me", pu.GetMyPortfolios)
-	group.GET("/portfolio/", pu.GetPortfolioByUID)
-	group.GET("/portfolio/history", pu.GetHistoricalUsageTemplates)
+// resolve 为各查询接口共用的参数
+var resolve = openapi.Param{
+	Name:        "resolve",
+	Description: "为 urls 时附带资源 URL：模板资源优先使用 CDN 地址，作品使用预签名 URL",
+	Enum:        []string{"urls"},
+}
+
+func InitAPI(group *openapi.Router, pu *controller.PortfolioUsecase, cu *controller.CollabUsecase) {
+	group.GET("/template/all", openapi.Operation{
+		Summary:  "获取全部模板",
+		Query:    []openapi.Param{resolve},
+		Response: []controller.TemplateResponse{},
+	}, pu.GetAllTemplates)
+	group.GET("/template/", openapi.Operation{
+		Summary: "按 UID 获取模板",
+		Query: []openapi.Param{
+			{Name: "uid", Description: "模板 UID", Required: true},
+			resolve,
+		},
+		Response: controller.TemplateResponse{},
+	}, pu.GetTemplateByUID)
+	group.GET("/template/hot", openapi.Operation{
+		Summary:  "获取热门模板",
+		Query:    []openapi.Param{resolve},
+		Response: []controller.TemplateResponse{},
+	}, pu.GetHotTemplates)
+	group.POST("/portfolio/save", openapi.Operation{
+		Summary: "保存作品集",
+		Description: "uid 为空时创建新作品集。通过 If-Match 头或 revision 字段传回所基于的版本，" +
+			"与当前版本不一致时返回 409，data 为当前版本的作品集；成功时 ETag 为新版本。" +
+			"作品的 oss_key 只能是自己上传的对象或模板公共资源。" +
+			"不属于该作品集的项目、作品与文本 uid 会重新生成，uid 为空的作品按项目与 oss_key 沿用已有作品，以返回的 uid 为准",
+		Query:    []openapi.Param{resolve},
+		Body:     controller.SavePortfolioRequest{},
+		Response: controller.SavePortfolioResponse{},
+	}, pu.SavePortfolio)
+	group.PATCH("/portfolio/", openapi.Operation{
+		Summary: "增量保存作品集",
+		Description: "ops 为与协同编辑相同的修改（add_work、update_text、move_project、delete_page 等），执行后广播给协同连接；" +
+			"patch 为作用于 PortfolioDocument 的 JSON Patch，只写入变化的项目、作品与文本，执行后协同连接收到 resync。" +
+			"两者只能设置一个，在同一事务中执行，版本检查与冲突响应同保存作品集；未指定版本时 patch 遇到并发修改会自动重试，" +
+			"成功时返回新版本号",
+		Query: []openapi.Param{
+			{Name: "uid", Description: "作品集 UID", Required: true},
+			resolve,
+		},
+		Body:     controller.PatchPortfolioRequest{},
+		Response: controller.PatchPortfolioResponse{},
+	}, cu.PatchPortfolio)
+	group.POST("/portfolio/layout", openapi.Operation{
+		Summary:     "自动排版作品",
+		Description: "按模板内容页的空位计算作品位置，不保存；尺寸未知的图片列在 unsized 中",
+		Body:        controller.LayoutRequest{},
+		Response:    controller.LayoutResponse{},
+	}, pu.Layout)
+	group.POST("/portfolio/duplicate", openapi.Operation{
+		Summary:     "复制作品集",
+		Description: "项目、作品、文本与页面使用新的 UID，作品共用原有资源",
+		Query:       []openapi.Param{resolve},
+		Body:        controller.DuplicatePortfolioRequest{},
+		Response:    controller.PortfolioResponse{},
+	}, pu.DuplicatePortfolio)
+	group.POST("/portfolio/retemplate", openapi.Operation{
+		Summary:     "更换模板",
+		Description: "按页将占用空位的作品与文本放入新模板同类型的空位，无法放置的内容列在 unplaced 中",
+		Query:       []openapi.Param{resolve},
+		Body:        controller.RetemplateRequest{},
+		Response:    controller.RetemplateResponse{},
+	}, pu.Retemplate)
+	group.POST("/portfolio/page/insert", openapi.Operation{
+		Summary:     "插入页面",
+		Description: "之后的页面及其作品、文本的页码依次后移，返回全部页面；If-Match 与当前版本不一致时返回冲突",
+		Body:        controller.InsertPageRequest{},
+		Response:    []controller.PortfolioPageResponse{},
+	}, pu.InsertPage)
+	group.POST("/portfolio/page/duplicate", openapi.Operation{
+		Summary:     "复制页面",
+		Description: "复制页面及其中的作品与文本，副本插入在原页面之后，返回全部页面；If-Match 与当前版本不一致时返回冲突",
+		Body:        controller.PageRequest{},
+		Response:    []controller.PortfolioPageResponse{},
+	}, pu.DuplicatePage)
+	group.POST("/portfolio/page/move", openapi.Operation{
+		Summary:     "移动页面",
+		Description: "页面中的作品与文本随页面移动，返回全部页面；If-Match 与当前版本不一致时返回冲突",
+		Body:        controller.MovePageRequest{},
+		Response:    []controller.PortfolioPageResponse{},
+	}, pu.MovePage)
+	group.POST("/portfolio/page/delete", openapi.Operation{
+		Summary:     "删除页面",
+		Description: "同时删除页面中的作品与文本，之后的页面依次前移，返回全部页面；If-Match 与当前版本不一致时返回冲突",
+		Body:        controller.PageRequest{},
+		Response:    []controller.PortfolioPageResponse{},
+	}, pu.DeletePage)
+	group.POST("/portfolio/share/create", openapi.Operation{
+		Summary:     "创建分享链接",
+		Description: "持有 token 的人无需登录即可通过 /api/public/share 查看作品集",
+		Body:        controller.CreateShareLinkRequest{},
+		Response:    controller.ShareLinkResponse{},
+	}, pu.CreateShareLink)
+	group.GET("/portfolio/share/list", openapi.Operation{
+		Summary: "获取作品集的分享链接",
+		Query: []openapi.Param{
+			{Name: "portfolio_uid", Description: "作品集 UID", Required: true},
+		},
+		Response: []controller.ShareLinkResponse{},
+	}, pu.ListShareLinks)
+	group.POST("/portfolio/share/revoke", openapi.Operation{
+		Summary: "撤销分享链接",
+		Body:    controller.RevokeShareLinkRequest{},
+	}, pu.RevokeShareLink)
+	group.GET("/portfolio/me", openapi.Operation{
+		Summary:  "获取我的作品集",
+		Query:    []openapi.Param{resolve},
+		Response: []controller.PortfolioResponse{},
+	}, pu.GetMyPortfolios)
+	group.GET("/portfolio/", openapi.Operation{
+		Summary:     "按 UID 获取作品集",
+		Description: "只有作品集所有者与未被撤销的评审人可以获取。ETag 为作品集的版本，If-None-Match 与之相同时返回 304；resolve=urls 时始终返回完整响应",
+		Query: []openapi.Param{
+			{Name: "uid", Description: "作品集 UID", Required: true},
+			resolve,
+		},
+		Response: controller.PortfolioResponse{},
+	}, pu.GetPortfolioByUID)
+	group.GET("/portfolio/history", openapi.Operation{
+		Summary:  "获取使用过的模板",
+		Query:    []openapi.Param{resolve},
+		Response: []controller.TemplateResponse{},
+	}, pu.GetHistoricalUsageTemplates)
 }
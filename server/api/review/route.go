@@ -0,0 +1,58 @@
+package review
+
+import (
+	"github.com/Fl0rencess720/Springboard/internal/controller"
+	"github.com/Fl0rencess720/Springboard/pkgs/openapi"
+)
+
+func InitAPI(group *openapi.Router, ru *controller.ReviewUsecase) {
+	group.POST("/invite", openapi.Operation{
+		Summary:     "邀请评审",
+		Description: "返回的 invite_token 发给老师，老师登录后通过 /review/accept 接受",
+		Body:        controller.InviteReviewRequest{},
+		Response:    controller.ReviewResponse{},
+	}, ru.Invite)
+	group.POST("/accept", openapi.Operation{
+		Summary:  "接受评审邀请",
+		Body:     controller.AcceptReviewRequest{},
+		Response: controller.ReviewResponse{},
+	}, ru.Accept)
+	group.GET("/list", openapi.Operation{
+		Summary: "获取作品集的评审",
+		Query: []openapi.Param{
+			{Name: "portfolio_uid", Description: "作品集 UID", Required: true},
+		},
+		Response: []controller.ReviewResponse{},
+	}, ru.ListByPortfolio)
+	group.GET("/mine", openapi.Operation{
+		Summary:  "获取我参与评审的作品集",
+		Response: []controller.ReviewResponse{},
+	}, ru.ListMine)
+	group.GET("", openapi.Operation{
+		Summary:     "获取评审详情",
+		Description: "包含附带资源 URL 的作品集与全部讨论",
+		Query: []openapi.Param{
+			{Name: "uid", Description: "评审 UID", Required: true},
+		},
+		Response: controller.ReviewDetailResponse{},
+	}, ru.Get)
+	group.POST("/comment", openapi.Operation{
+		Summary:     "发表评论",
+		Description: "锚点可以是整个作品集、页面、作品或页面上的区域；填写 parent_uid 时为回复",
+		Body:        controller.AddCommentRequest{},
+		Response:    controller.CommentResponse{},
+	}, ru.AddComment)
+	group.POST("/comment/resolve", openapi.Operation{
+		Summary: "解决或重新打开讨论",
+		Body:    controller.ResolveCommentRequest{},
+	}, ru.ResolveComment)
+	group.POST("/decision", openapi.Operation{
+		Summary:  "给出评审结论",
+		Body:     controller.ReviewDecisionRequest{},
+		Response: controller.ReviewResponse{},
+	}, ru.Decide)
+	group.POST("/revoke", openapi.Operation{
+		Summary: "撤销评审",
+		Body:    controller.ReviewRequest{},
+	}, ru.Revoke)
+}
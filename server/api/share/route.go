@@ -0,0 +1,38 @@
+package share
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/Fl0rencess720/Springboard/internal/controller"
+	"github.com/Fl0rencess720/Springboard/internal/middleware"
+	"github.com/Fl0rencess720/Springboard/pkgs/openapi"
+	"github.com/gin-gonic/gin"
+)
+
+func InitAPI(group *openapi.Router, pu *controller.PortfolioUsecase, rl *middleware.RateLimiter) {
+	// 按 ip 限流之外再按 token 限制密码错误的次数，更换 ip 也无法对同一链接暴力猜测密码，
+	// 成功的查看不计数，热门链接不受影响
+	group.POST("/share", openapi.Operation{
+		Summary:     "通过分享链接查看作品集",
+		Description: "无需登录，返回附带资源 URL 的只读作品集，每次成功查看计入链接的查看次数",
+		Body:        controller.ViewShareRequest{},
+		Response:    controller.SharedPortfolioResponse{},
+		Before:      []gin.HandlerFunc{rl.Limit("share_view")},
+	}, rl.LimitFailures("share_password", shareToken), pu.ViewShare)
+}
+
+// shareToken 读取请求体中的 token 后放回请求体
+func shareToken(c *gin.Context) string {
+	raw, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return ""
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+	req := controller.ViewShareRequest{}
+	if err := json.Unmarshal(raw, &req); err != nil || req.Token == "" {
+		return ""
+	}
+	return "token:" + req.Token
+}
@@ -11,22 +11,41 @@ import (
 	"github.com/Fl0rencess720/Springboard/internal/conf"
 	"github.com/Fl0rencess720/Springboard/internal/controller"
 	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/Fl0rencess720/Springboard/internal/middleware"
 
 	"github.com/Fl0rencess720/Springboard/api"
 	"github.com/Fl0rencess720/Springboard/consts"
 	"github.com/Fl0rencess720/Springboard/pkgs/logger"
-	"github.com/spf13/viper"
+	"github.com/Fl0rencess720/Springboard/pkgs/oss"
+	"github.com/Fl0rencess720/Springboard/pkgs/ratelimit"
 	"go.uber.org/zap"
 )
 
 func init() {
 	conf.Init()
-	logger.Init(consts.DefaultLogFilePath)
+	cfg := conf.Get()
+	logger.Init(consts.DefaultLogFilePath, cfg.Project.IsDev(), cfg.Log.Level)
 	data.Init()
+	oss.Init(oss.Config{
+		AccessKeyID:     cfg.OSS.AccessKeyID,
+		AccessKeySecret: cfg.OSS.AccessKeySecret,
+		RoleArn:         cfg.OSS.RoleArn,
+		Region:          cfg.OSS.Region,
+		Bucket:          cfg.OSS.Bucket,
+		STSEndpoint:     cfg.OSS.STSEndpoint,
+		PresignTTL:      cfg.OSS.PresignTTL,
+	})
+	conf.OnReload(func(cfg *conf.Config) {
+		logger.SetLevel(cfg.Project.IsDev(), cfg.Log.Level)
+		oss.SetPresignTTL(cfg.OSS.PresignTTL)
+	})
 }
 
 func main() {
-	srv := newSrv()
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := newSrv(ctx)
+	srv.RegisterOnShutdown(cancel)
+	go controller.NewOrphanSweeper(data.NewOSSRepo(data.GetDB(), data.GetRedis()), oss.Default()).Run(ctx)
 	go func() {
 		if err := srv.ListenAndServe(); err != nil {
 			zap.L().Error("Server ListenAndServe", zap.Error(err))
@@ -36,17 +55,27 @@ func main() {
 	closeServer(srv, context.Background())
 }
 
-func newSrv() *http.Server {
+func newSrv(ctx context.Context) *http.Server {
 	authRepo := data.NewAuthRepo(data.GetDB())
 	portfolioRepo := data.NewPortfolioRepo(data.GetDB(), data.GetRedis())
 	feedbackRepo := data.NewFeedbackRepo(data.GetDB())
+	ossRepo := data.NewOSSRepo(data.GetDB(), data.GetRedis())
+	reviewRepo := data.NewReviewRepo(data.GetDB())
+	collabRepo := data.NewCollabRepo(data.GetDB(), data.GetRedis())
 	authUsecase := controller.NewAuthUsecase(authRepo)
-	portfolioUsecase := controller.NewPortfolioUsecase(portfolioRepo)
+	portfolioUsecase := controller.NewPortfolioUsecase(portfolioRepo, oss.Default())
 	feedbackUsecase := controller.NewFeedbackUseCase(feedbackRepo)
-	ossUsecase := controller.NewOSSUsecase()
+	ossUsecase := controller.NewOSSUsecase(ossRepo, oss.Default())
+	reviewUsecase := controller.NewReviewUsecase(reviewRepo, portfolioUsecase)
+	collabUsecase := controller.NewCollabUsecase(collabRepo, portfolioUsecase)
+	go collabUsecase.Run(ctx)
+	rateLimiter := middleware.NewRateLimiter(ratelimit.WithFallback(
+		ratelimit.NewRedisLimiter(data.GetRedis()),
+		ratelimit.NewMemoryLimiter(),
+	))
 	return &http.Server{
-		Addr:    viper.GetString("server.port"),
-		Handler: api.Init(authUsecase, portfolioUsecase, feedbackUsecase, ossUsecase),
+		Addr:    conf.Get().Server.Port,
+		Handler: api.Init(authUsecase, portfolioUsecase, feedbackUsecase, ossUsecase, reviewUsecase, collabUsecase, rateLimiter),
 	}
 }
 
@@ -0,0 +1,50 @@
+package consts
+
+// 错误码，同时作为 i18n 消息目录的 key
+const (
+	ServerError = iota
+	AuthError
+	TokenExpired
+	LoginError
+	RefreshTokenError
+	RegisterError
+	TokenMissing
+	TokenFormatError
+	InvalidParams
+	TooManyRequests
+	UnsupportedContentType
+	FileTooLarge
+	ObjectAccessDenied
+	ObjectNotUploaded
+	PortfolioAccessDenied
+	ShareLinkInvalid
+	SharePasswordIncorrect
+	PortfolioNotFound
+	ReviewAccessDenied
+	ReviewInviteInvalid
+	PortfolioConflict
+)
+
+var HttpCode = map[uint]int{
+	ServerError:            502,
+	AuthError:              401,
+	TokenExpired:           401,
+	LoginError:             403,
+	RefreshTokenError:      403,
+	RegisterError:          403,
+	TokenMissing:           401,
+	TokenFormatError:       401,
+	InvalidParams:          400,
+	TooManyRequests:        429,
+	UnsupportedContentType: 415,
+	FileTooLarge:           413,
+	ObjectAccessDenied:     403,
+	ObjectNotUploaded:      404,
+	PortfolioAccessDenied:  403,
+	ShareLinkInvalid:       404,
+	SharePasswordIncorrect: 403,
+	PortfolioNotFound:      404,
+	ReviewAccessDenied:     403,
+	ReviewInviteInvalid:    404,
+	PortfolioConflict:      409,
+}
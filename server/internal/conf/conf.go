@@ -1,7 +1,18 @@
 package conf
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/go-playground/validator/v10"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 )
 
 type Option func(*conf)
@@ -18,6 +29,12 @@ var c = &conf{
 	configFilename: "config",
 }
 
+var (
+	current   atomic.Pointer[Config]
+	mu        sync.Mutex
+	listeners []func(*Config)
+)
+
 func apply(opts ...Option) *conf {
 	newConf := c
 	for _, opt := range opts {
@@ -51,8 +68,130 @@ func Init(opts ...Option) {
 	viper.AddConfigPath(cur.configDirPath)
 	viper.SetConfigName(cur.configFilename)
 	viper.AutomaticEnv()
+	for key, value := range defaults {
+		viper.SetDefault(key, value)
+	}
+	for key, env := range envBindings {
+		if err := viper.BindEnv(key, env); err != nil {
+			panic(err)
+		}
+	}
+	for _, key := range secretKeys {
+		if err := viper.BindEnv(key+"_file", envBindings[key]+"_FILE"); err != nil {
+			panic(err)
+		}
+	}
 	err := viper.ReadInConfig()
 	if err != nil {
 		panic(err)
 	}
+	cfg, err := load()
+	if err != nil {
+		panic(err)
+	}
+	current.Store(cfg)
+
+	viper.OnConfigChange(func(e fsnotify.Event) {
+		reload()
+	})
+	viper.WatchConfig()
+}
+
+// Get 返回当前配置，调用方不应修改返回值
+func Get() *Config {
+	return current.Load()
+}
+
+// OnReload 注册配置热更新后的回调
+func OnReload(fn func(*Config)) {
+	mu.Lock()
+	defer mu.Unlock()
+	listeners = append(listeners, fn)
+}
+
+func load() (*Config, error) {
+	for _, key := range secretKeys {
+		if err := readSecretFile(key); err != nil {
+			return nil, err
+		}
+	}
+	cfg := &Config{}
+	if err := viper.Unmarshal(cfg); err != nil {
+		return nil, err
+	}
+	if err := validate(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func readSecretFile(key string) error {
+	path := viper.GetString(key + "_file")
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s from %s: %w", key, path, err)
+	}
+	viper.Set(key, strings.TrimSpace(string(content)))
+	return nil
+}
+
+func validate(cfg *Config) error {
+	v := validator.New()
+	v.SetTagName("validate")
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		return f.Tag.Get("mapstructure")
+	})
+	err := v.Struct(cfg)
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		if err != nil {
+			return err
+		}
+		return validateCORS(cfg)
+	}
+	fields := []string{}
+	for _, e := range verrs {
+		key := strings.TrimPrefix(e.Namespace(), "Config.")
+		if env, ok := envBindings[key]; ok {
+			key += " (" + env + ")"
+		}
+		fields = append(fields, fmt.Sprintf("%s: %s", key, e.Tag()))
+	}
+	return fmt.Errorf("invalid config: %s", strings.Join(fields, ", "))
+}
+
+// validateCORS 拒绝 "*" 与 allow_credentials 同时出现，浏览器会拒绝这样的响应
+func validateCORS(cfg *Config) error {
+	for mode, policy := range cfg.CORS {
+		if !policy.AllowCredentials {
+			continue
+		}
+		for _, origin := range policy.AllowOrigins {
+			if origin == "*" {
+				return fmt.Errorf("invalid config: cors.%s: allow_origins \"*\" cannot be used with allow_credentials", mode)
+			}
+		}
+	}
+	return nil
+}
+
+func reload() {
+	next, err := load()
+	if err != nil {
+		zap.L().Error("config reload skipped", zap.Error(err))
+		return
+	}
+	updated := reloadSafe(Get(), next)
+	current.Store(&updated)
+	zap.L().Info("config reloaded")
+
+	mu.Lock()
+	fns := append([]func(*Config){}, listeners...)
+	mu.Unlock()
+	for _, fn := range fns {
+		fn(&updated)
+	}
 }
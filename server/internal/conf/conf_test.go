@@ -0,0 +1,131 @@
+package conf
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig 返回能够通过校验的最小配置
+func validConfig() *Config {
+	return &Config{
+		Server:  Server{Port: ":8000"},
+		Project: Project{Mode: "dev"},
+		Data: Data{
+			MySQL: MySQL{User: "root", Password: "password", Addr: "127.0.0.1:3306"},
+			Redis: Redis{Addr: "127.0.0.1:6379"},
+		},
+		Auth:   Auth{AccessSecret: "access", RefreshSecret: "refresh", AccessTTL: time.Hour, RefreshTTL: 24 * time.Hour},
+		WeChat: WeChat{AppID: "app", AppSecret: "secret"},
+		OSS: OSS{
+			AccessKeyID: "id", AccessKeySecret: "secret", RoleArn: "arn", Region: "cn-shenzhen",
+			Bucket: "springboard", STSEndpoint: "sts.cn-hangzhou.aliyuncs.com", PresignTTL: 30 * time.Minute,
+		},
+		GC: GC{MaxDeletions: 500},
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		// want 为错误信息中应包含的内容，为空表示校验通过
+		want string
+	}{
+		{name: "valid", modify: func(*Config) {}},
+		{name: "log level", modify: func(c *Config) { c.Log.Level = "info" }},
+		{name: "invalid log level", modify: func(c *Config) { c.Log.Level = "verbose" }, want: "log.level: oneof"},
+		{name: "missing port", modify: func(c *Config) { c.Server.Port = "" }, want: "server.port: required"},
+		{name: "missing secret names env", modify: func(c *Config) { c.Auth.AccessSecret = "" },
+			want: "auth.access_secret (ACCESS_SECRET): required"},
+		{name: "zero ttl", modify: func(c *Config) { c.Auth.AccessTTL = 0 }, want: "auth.access_ttl: gt"},
+		{name: "negative presign ttl", modify: func(c *Config) { c.OSS.PresignTTL = -time.Minute }, want: "oss.presign_ttl: gt"},
+		{name: "cors wildcard without credentials", modify: func(c *Config) {
+			c.CORS = map[string]CORSPolicy{"dev": {AllowOrigins: []string{"*"}}}
+		}},
+		{name: "cors credentials with origins", modify: func(c *Config) {
+			c.CORS = map[string]CORSPolicy{"prod": {AllowOrigins: []string{"https://*.example.com"}, AllowCredentials: true}}
+		}},
+		{name: "cors wildcard with credentials", modify: func(c *Config) {
+			c.CORS = map[string]CORSPolicy{"prod": {AllowOrigins: []string{"https://example.com", "*"}, AllowCredentials: true}}
+		}, want: "cors.prod"},
+		{name: "trusted proxies", modify: func(c *Config) {
+			c.Server.TrustedProxies = []string{"10.0.0.1", "172.16.0.0/12"}
+		}},
+		{name: "invalid trusted proxy", modify: func(c *Config) {
+			c.Server.TrustedProxies = []string{"gateway"}
+		}, want: "server.trusted_proxies[0]: ip|cidr"},
+		{name: "rate limit rule", modify: func(c *Config) {
+			c.RateLimit = map[string]RateLimitRule{"login": {Rate: 10, Period: time.Minute, By: "ip"}}
+		}},
+		{name: "rate limit zero period", modify: func(c *Config) {
+			c.RateLimit = map[string]RateLimitRule{"login": {Rate: 10, By: "ip"}}
+		}, want: "rate_limit[login].period: gt"},
+		{name: "rate limit identity", modify: func(c *Config) {
+			c.RateLimit = map[string]RateLimitRule{"login": {Rate: 10, Period: time.Minute, By: "user"}}
+		}, want: "rate_limit[login].by: oneof"},
+		{name: "gc enabled", modify: func(c *Config) {
+			c.GC = GC{Enabled: true, Interval: time.Hour, GracePeriod: 72 * time.Hour, MaxDeletions: 1}
+		}},
+		{name: "gc enabled without interval", modify: func(c *Config) {
+			c.GC = GC{Enabled: true, GracePeriod: 72 * time.Hour, MaxDeletions: 1}
+		}, want: "gc.interval: required_if"},
+		{name: "gc zero deletion cap", modify: func(c *Config) { c.GC.MaxDeletions = 0 }, want: "gc.max_deletions: gt"},
+		{name: "cdn base url", modify: func(c *Config) { c.OSS.CDNBaseURL = "https://cdn.example.com" }},
+		{name: "invalid cdn base url", modify: func(c *Config) { c.OSS.CDNBaseURL = "cdn.example.com" }, want: "oss.cdn_base_url: url"},
+		{name: "reports every field", modify: func(c *Config) {
+			c.Data.MySQL.Addr = ""
+			c.WeChat.AppID = ""
+		}, want: "data.mysql.addr (MYSQL_ADDR): required, wechat.app_id (APP_ID): required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			err := validate(cfg)
+			if tt.want == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReloadSafe(t *testing.T) {
+	cur := validConfig()
+	next := validConfig()
+	next.Server.Port = ":9000"
+	next.Data.MySQL.Addr = "db:3306"
+	next.Auth.AccessSecret = "rotated"
+	next.OSS.Bucket = "other"
+	next.Log.Level = "debug"
+	next.Auth.AccessTTL = 2 * time.Hour
+	next.Auth.RefreshTTL = 48 * time.Hour
+	next.OSS.PresignTTL = time.Hour
+	next.OSS.CDNBaseURL = "https://cdn.example.com"
+	next.CORS = map[string]CORSPolicy{"dev": {AllowOrigins: []string{"*"}}}
+	next.RateLimit = map[string]RateLimitRule{"login": {Rate: 10, Period: time.Minute, By: "ip"}}
+	next.Server.TrustedProxies = []string{"10.0.0.1"}
+	next.GC = GC{Enabled: true, Interval: time.Hour, GracePeriod: time.Hour, MaxDeletions: 10}
+
+	updated := reloadSafe(cur, next)
+	// 需要重启才能生效的字段保持不变
+	if updated.Server.Port != ":8000" || len(updated.Server.TrustedProxies) != 0 || updated.Data.MySQL.Addr != "127.0.0.1:3306" ||
+		updated.Auth.AccessSecret != "access" || updated.OSS.Bucket != "springboard" {
+		t.Fatalf("restart-only fields changed: %+v", updated)
+	}
+	if updated.Log.Level != "debug" || updated.Auth.AccessTTL != 2*time.Hour ||
+		updated.Auth.RefreshTTL != 48*time.Hour || updated.OSS.PresignTTL != time.Hour ||
+		updated.OSS.CDNBaseURL != "https://cdn.example.com" || len(updated.CORS) != 1 || len(updated.RateLimit) != 1 ||
+		updated.GC != next.GC {
+		t.Fatalf("reloadable fields not updated: %+v", updated)
+	}
+	if cur.Log.Level != "" || cur.Auth.AccessTTL != time.Hour {
+		t.Fatal("reloadSafe modified the current config")
+	}
+}
@@ -0,0 +1,170 @@
+package conf
+
+import "time"
+
+type Config struct {
+	Server  Server  `mapstructure:"server"`
+	Project Project `mapstructure:"project"`
+	Log     Log     `mapstructure:"log"`
+	Data    Data    `mapstructure:"data"`
+	Auth    Auth    `mapstructure:"auth"`
+	WeChat  WeChat  `mapstructure:"wechat"`
+	OSS     OSS     `mapstructure:"oss"`
+	// CORS 以 project.mode 为 key，不同环境使用不同的跨域策略
+	CORS map[string]CORSPolicy `mapstructure:"cors" validate:"dive"`
+	// RateLimit 以规则名为 key，未配置的规则不限流
+	RateLimit map[string]RateLimitRule `mapstructure:"rate_limit" validate:"dive"`
+	GC        GC                       `mapstructure:"gc"`
+}
+
+// CORSPolicy 返回当前 project.mode 对应的跨域策略，未配置时不允许任何跨域请求
+func (c *Config) CORSPolicy() CORSPolicy {
+	return c.CORS[c.Project.Mode]
+}
+
+type Server struct {
+	Port string `mapstructure:"port" validate:"required"`
+	// TrustedProxies 为可信反向代理的 IP 或 CIDR，只信任其转发的 X-Forwarded-For，为空时以连接地址作为客户端 IP
+	TrustedProxies []string `mapstructure:"trusted_proxies" validate:"dive,ip|cidr"`
+}
+
+type Project struct {
+	Mode string `mapstructure:"mode" validate:"required"`
+}
+
+func (p Project) IsDev() bool {
+	return p.Mode == "dev"
+}
+
+type Log struct {
+	// Level 为空时 dev 模式使用 debug，其余使用 error
+	Level string `mapstructure:"level" validate:"omitempty,oneof=debug info warn error"`
+}
+
+type Data struct {
+	MySQL MySQL `mapstructure:"mysql"`
+	Redis Redis `mapstructure:"redis"`
+}
+
+type MySQL struct {
+	User     string `mapstructure:"user" validate:"required"`
+	Password string `mapstructure:"password" validate:"required"`
+	Addr     string `mapstructure:"addr" validate:"required"`
+}
+
+type Redis struct {
+	Addr         string        `mapstructure:"addr" validate:"required"`
+	Password     string        `mapstructure:"password"`
+	DB           int           `mapstructure:"db"`
+	ReadTimeout  time.Duration `mapstructure:"read_timeout"`
+	WriteTimeout time.Duration `mapstructure:"write_timeout"`
+	DialTimeout  time.Duration `mapstructure:"dial_timeout"`
+}
+
+type Auth struct {
+	AccessSecret  string        `mapstructure:"access_secret" validate:"required"`
+	RefreshSecret string        `mapstructure:"refresh_secret" validate:"required"`
+	AccessTTL     time.Duration `mapstructure:"access_ttl" validate:"gt=0"`
+	RefreshTTL    time.Duration `mapstructure:"refresh_ttl" validate:"gt=0"`
+}
+
+type WeChat struct {
+	AppID     string `mapstructure:"app_id" validate:"required"`
+	AppSecret string `mapstructure:"app_secret" validate:"required"`
+}
+
+type OSS struct {
+	AccessKeyID     string        `mapstructure:"access_key_id" validate:"required"`
+	AccessKeySecret string        `mapstructure:"access_key_secret" validate:"required"`
+	RoleArn         string        `mapstructure:"role_arn" validate:"required"`
+	Region          string        `mapstructure:"region" validate:"required"`
+	Bucket          string        `mapstructure:"bucket" validate:"required"`
+	STSEndpoint     string        `mapstructure:"sts_endpoint" validate:"required"`
+	PresignTTL      time.Duration `mapstructure:"presign_ttl" validate:"gt=0"`
+	// CDNBaseURL 为模板公共资源的 CDN 域名，为空时模板资源同样使用预签名 URL
+	CDNBaseURL string `mapstructure:"cdn_base_url" validate:"omitempty,url"`
+}
+
+type CORSPolicy struct {
+	// AllowOrigins 支持 "*" 以及 https://*.example.com 形式的子域名通配
+	AllowOrigins     []string      `mapstructure:"allow_origins"`
+	AllowMethods     []string      `mapstructure:"allow_methods"`
+	AllowHeaders     []string      `mapstructure:"allow_headers"`
+	ExposeHeaders    []string      `mapstructure:"expose_headers"`
+	AllowCredentials bool          `mapstructure:"allow_credentials"`
+	MaxAge           time.Duration `mapstructure:"max_age"`
+}
+
+// RateLimitRule 表示每个身份在 Period 内最多 Rate 次请求，允许 Burst 次突发
+type RateLimitRule struct {
+	Rate   int           `mapstructure:"rate" validate:"gt=0"`
+	Period time.Duration `mapstructure:"period" validate:"gt=0"`
+	Burst  int           `mapstructure:"burst" validate:"gte=0"`
+	// By 为 openid 时按登录用户限流，未登录的请求退回按 IP 限流
+	By string `mapstructure:"by" validate:"oneof=ip openid"`
+}
+
+// GC 控制孤立上传对象的清理，DryRun 时只输出报告不删除，MaxDeletions 为每次最多删除的对象数
+type GC struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Interval     time.Duration `mapstructure:"interval" validate:"required_if=Enabled true"`
+	GracePeriod  time.Duration `mapstructure:"grace_period" validate:"required_if=Enabled true"`
+	DryRun       bool          `mapstructure:"dry_run"`
+	MaxDeletions int           `mapstructure:"max_deletions" validate:"gt=0"`
+}
+
+var defaults = map[string]any{
+	"server.port":      ":8000",
+	"project.mode":     "dev",
+	"auth.access_ttl":  2 * time.Hour,
+	"auth.refresh_ttl": 14 * 24 * time.Hour,
+	"oss.region":       "cn-shenzhen",
+	"oss.bucket":       "springboard",
+	"oss.sts_endpoint": "sts.cn-hangzhou.aliyuncs.com",
+	"oss.presign_ttl":  30 * time.Minute,
+	"gc.interval":      6 * time.Hour,
+	"gc.grace_period":  72 * time.Hour,
+	"gc.dry_run":       true,
+	"gc.max_deletions": 500,
+}
+
+// envBindings 兼容 .env 中已有的环境变量名
+var envBindings = map[string]string{
+	"data.mysql.user":       "MYSQL_USER",
+	"data.mysql.password":   "MYSQL_PASSWORD",
+	"data.mysql.addr":       "MYSQL_ADDR",
+	"data.redis.addr":       "REDIS_ADDR",
+	"data.redis.password":   "REDIS_PASSWORD",
+	"auth.access_secret":    "ACCESS_SECRET",
+	"auth.refresh_secret":   "REFRESH_SECRET",
+	"wechat.app_id":         "APP_ID",
+	"wechat.app_secret":     "APP_SECRET",
+	"oss.access_key_id":     "OSSAccessKeyId",
+	"oss.access_key_secret": "OSSAccessKeySecret",
+	"oss.role_arn":          "OSSRoleArn",
+}
+
+// secretKeys 可以通过 <key>_file 配置项或 <ENV>_FILE 环境变量从文件读取
+var secretKeys = []string{
+	"data.mysql.password",
+	"data.redis.password",
+	"auth.access_secret",
+	"auth.refresh_secret",
+	"wechat.app_secret",
+	"oss.access_key_id",
+	"oss.access_key_secret",
+}
+
+// reloadSafe 将配置文件变更后允许热更新的字段写入当前配置，其余字段需要重启生效
+func reloadSafe(cur, next *Config) Config {
+	updated := *cur
+	updated.Log = next.Log
+	updated.CORS = next.CORS
+	updated.RateLimit = next.RateLimit
+	updated.GC = next.GC
+	updated.Auth.AccessTTL = next.Auth.AccessTTL
+	updated.Auth.RefreshTTL = next.Auth.RefreshTTL
+	updated.OSS.PresignTTL = next.OSS.PresignTTL
+	updated.OSS.CDNBaseURL = next.OSS.CDNBaseURL
+	return updated
+}
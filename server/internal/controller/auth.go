@@ -9,9 +9,10 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/conf"
 	"github.com/Fl0rencess720/Springboard/internal/middleware"
 	"github.com/gin-gonic/gin"
-	"github.com/spf13/viper"
 	"github.com/thedevsaddam/gojsonq"
 )
 
@@ -24,6 +25,11 @@ type AuthUsecase struct {
 	repo AuthRepo
 }
 
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
 type AppRegisterLoginRequest struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
@@ -36,74 +42,75 @@ func NewAuthUsecase(repo AuthRepo) *AuthUsecase {
 func (s *AuthUsecase) Login(c *gin.Context) {
 	code := c.Query("code")
 	url := "https://api.weixin.qq.com/sns/jscode2session?appid=%s&secret=%s&js_code=%s&grant_type=authorization_code "
-	url = fmt.Sprintf(url, viper.GetString("APP_ID"), viper.GetString("APP_SECRET"), code)
+	wechat := conf.Get().WeChat
+	url = fmt.Sprintf(url, wechat.AppID, wechat.AppSecret, code)
 	resp, err := http.Get(url)
 	if err != nil {
-		ErrorResponse(c, LoginError, err)
+		ErrorResponse(c, consts.LoginError, err)
 		return
 	}
 	defer resp.Body.Close()
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		ErrorResponse(c, LoginError, err)
+		ErrorResponse(c, consts.LoginError, err)
 		return
 	}
 	json := gojsonq.New().FromString(string(body)).Find("openid")
 	if json == nil {
-		ErrorResponse(c, LoginError, errors.New("openid not found"))
+		ErrorResponse(c, consts.LoginError, errors.New("openid not found"))
 		return
 	}
 	openId := json.(string)
 	accessToken, refreshToken, err := middleware.GenToken(openId)
 	if err != nil {
-		ErrorResponse(c, LoginError, err)
+		ErrorResponse(c, consts.LoginError, err)
 		return
 	}
-	SuccessResponse(c, gin.H{
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
+	SuccessResponse(c, TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
 	})
 }
 
 func (s *AuthUsecase) AppRegister(c *gin.Context) {
 	var req AppRegisterLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
 	accessToken, refreshToken, err := middleware.GenToken(req.Username)
 	if err != nil {
-		ErrorResponse(c, LoginError, err)
+		ErrorResponse(c, consts.LoginError, err)
 		return
 	}
 	if err := s.repo.RegisterAppUser(req.Username, req.Password); err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
-	SuccessResponse(c, gin.H{
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
+	SuccessResponse(c, TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
 	})
 }
 
 func (s *AuthUsecase) AppLogin(c *gin.Context) {
 	var req AppRegisterLoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
 	accessToken, refreshToken, err := middleware.GenToken(req.Username)
 	if err != nil {
-		ErrorResponse(c, LoginError, err)
+		ErrorResponse(c, consts.LoginError, err)
 		return
 	}
 	if err := s.repo.VerifyLogin(req.Username, req.Password); err != nil {
-		ErrorResponse(c, LoginError, err)
+		ErrorResponse(c, consts.LoginError, err)
 		return
 	}
-	SuccessResponse(c, gin.H{
-		"access_token":  accessToken,
-		"refresh_token": refreshToken,
+	SuccessResponse(c, TokenResponse{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
 	})
 }
 
@@ -111,21 +118,21 @@ func (s *AuthUsecase) RefreshAccessToken(c *gin.Context) {
 	refreshToken := c.Query("refresh_token")
 	tokenString := c.GetHeader("Authorization")
 	if tokenString == "" {
-		ErrorResponse(c, RefreshTokenError, errors.New("miss token string"))
+		ErrorResponse(c, consts.RefreshTokenError, errors.New("miss token string"))
 		return
 	}
 	parts := strings.Split(tokenString, " ")
 	if len(parts) != 2 || parts[0] != "Bearer" {
-		ErrorResponse(c, RefreshTokenError, errors.New("wrong token format"))
+		ErrorResponse(c, consts.RefreshTokenError, errors.New("wrong token format"))
 		return
 	}
 	accessToken, err := middleware.RefreshToken(parts[1], refreshToken)
 	if err != nil {
-		ErrorResponse(c, RefreshTokenError, err)
+		ErrorResponse(c, consts.RefreshTokenError, err)
 		return
 	}
-	SuccessResponse(c, gin.H{
-		"access_token": accessToken,
+	SuccessResponse(c, TokenResponse{
+		AccessToken: accessToken,
 	})
 }
 
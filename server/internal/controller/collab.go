@@ -0,0 +1,504 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/Fl0rencess720/Springboard/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 协同消息的类型
+const (
+	collabSnapshot = "snapshot"
+	collabOp       = "op"
+	collabAck      = "ack"
+	collabError    = "error"
+	collabPresence = "presence"
+	collabPing     = "ping"
+	collabPong     = "pong"
+	collabResync   = "resync"
+)
+
+const (
+	collabWriteWait = 10 * time.Second
+	// 每个心跳周期发送一次 ping 并刷新在线状态，超过 collabPongWait 没有收到消息即断开
+	collabHeartbeat = 30 * time.Second
+	collabPongWait  = 2 * collabHeartbeat
+	collabMaxMsg    = 1 << 20
+	// 广播积压超过缓冲区的慢连接会被断开，重连后通过快照恢复
+	collabSendBuffer = 256
+)
+
+// CollabClientMessage 为客户端发送的消息，type 为 op 或 ping
+type CollabClientMessage struct {
+	Type string    `json:"type" binding:"required,oneof=op ping"`
+	OpID string    `json:"op_id" binding:"max=64" doc:"客户端生成的 ID，用于匹配 ack 与 error"`
+	Ops  []OpInput `json:"ops" binding:"max=100,dive" doc:"同一批修改在同一事务中执行"`
+}
+
+// CollabServerMessage 为服务端推送的消息，按 type 填写对应字段
+// snapshot：连接后的作品集快照，seq 为快照对应的版本号，之后只推送序号更大的 op
+// op：按序号顺序广播的修改，包括本连接提交的修改；seq 为修改后作品集的版本号，
+// 整体保存等非协同修改也会增加版本号，序号不连续时客户端应带 since 重连以获取快照
+// ack、error：对本连接提交的 op_id 的确认或拒绝
+// presence：当前在线的全部协作者
+// resync：作品集在协同编辑之外被修改（整体保存、页面操作等），或已广播的 op 未能提交；
+// seq 为当前版本号，客户端应丢弃未确认的修改并不带 since 重连以获取快照
+type CollabServerMessage struct {
+	Type      string             `json:"type"`
+	Seq       int64              `json:"seq,omitempty"`
+	OpID      string             `json:"op_id,omitempty"`
+	Author    *Collaborator      `json:"author,omitempty"`
+	Ops       []OpInput          `json:"ops,omitempty"`
+	Portfolio *PortfolioResponse `json:"portfolio,omitempty"`
+	Users     []Collaborator     `json:"users,omitempty"`
+	Code      uint               `json:"code,omitempty"`
+	Msg       string             `json:"msg,omitempty"`
+}
+
+// Collaborator 为一个协同连接，同一用户的多个连接分别列出
+type Collaborator struct {
+	ConnID   string    `json:"conn_id"`
+	UserID   string    `json:"user_id" doc:"用户的匿名标识，同一用户在不同连接中相同"`
+	Role     string    `json:"role" doc:"owner 或 reviewer"`
+	JoinedAt time.Time `json:"joined_at"`
+}
+
+type CollabRepo interface {
+	ApplyOps(context.Context, string, []data.Op, *int64, func(int64) error) (int64, error)
+	Snapshot(context.Context, string) (data.Portfolio, error)
+	CurrentRevision(context.Context, string) (int64, error)
+	AppendOpLog(context.Context, string, int64, []byte) error
+	GetOpLog(context.Context, string, int64) ([]data.OpLogEntry, error)
+	Publish(context.Context, string, []byte) error
+	Subscribe(context.Context) <-chan data.CollabEvent
+	SetPresence(context.Context, string, data.Presence) error
+	RemovePresence(context.Context, string, string) error
+	ListPresence(context.Context, string) ([]data.Presence, error)
+}
+
+// CollabUsecase 管理本副本上的协同连接，各副本通过 Redis 广播修改与在线状态
+type CollabUsecase struct {
+	repo       CollabRepo
+	portfolios *PortfolioUsecase
+	upgrader   websocket.Upgrader
+
+	mu    sync.Mutex
+	rooms map[string]map[*collabConn]struct{}
+}
+
+func NewCollabUsecase(repo CollabRepo, portfolios *PortfolioUsecase) *CollabUsecase {
+	return &CollabUsecase{
+		repo:       repo,
+		portfolios: portfolios,
+		upgrader: websocket.Upgrader{
+			// 小程序等非浏览器客户端不携带 Origin
+			CheckOrigin: func(r *http.Request) bool {
+				origin := r.Header.Get("Origin")
+				return origin == "" || middleware.OriginAllowed(origin)
+			},
+		},
+		rooms: map[string]map[*collabConn]struct{}{},
+	}
+}
+
+type collabConn struct {
+	ws        *websocket.Conn
+	portfolio data.Portfolio
+	openid    string
+	self      Collaborator
+	// send 中的消息由 writePump 按顺序写出，seq 不大于 synced 的 op 已包含在快照或补发中
+	send      chan collabOutgoing
+	synced    int64
+	done      chan struct{}
+	closeOnce sync.Once
+	// stale 在收到 resync 后设置，下次提交修改前重新读取作品集，例如更换模板后按新模板校验
+	stale atomic.Bool
+}
+
+type collabOutgoing struct {
+	seq     int64
+	payload []byte
+}
+
+func (conn *collabConn) close() {
+	conn.closeOnce.Do(func() {
+		close(conn.done)
+		conn.ws.Close()
+	})
+}
+
+// enqueue 不阻塞广播，缓冲区已满时断开连接
+func (conn *collabConn) enqueue(msg collabOutgoing) {
+	select {
+	case conn.send <- msg:
+	case <-conn.done:
+	default:
+		zap.L().Warn("collab connection too slow, closing", zap.String("conn_id", conn.self.ConnID))
+		conn.close()
+	}
+}
+
+func (conn *collabConn) reply(msg CollabServerMessage) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		zap.L().Error("marshal collab message error", zap.Error(err))
+		return
+	}
+	conn.enqueue(collabOutgoing{payload: payload})
+}
+
+func (conn *collabConn) write(payload []byte) error {
+	conn.ws.SetWriteDeadline(time.Now().Add(collabWriteWait))
+	return conn.ws.WriteMessage(websocket.TextMessage, payload)
+}
+
+// userID 为 openid 的摘要，让客户端区分用户而不暴露 openid
+func userID(openid string) string {
+	sum := sha256.Sum256([]byte(openid))
+	return hex.EncodeToString(sum[:8])
+}
+
+// collaborator 返回作品集及当前用户的身份，只有所有者与未被撤销的评审人可以加入
+func (uc *CollabUsecase) collaborator(c *gin.Context, uid string) (data.Portfolio, string, bool) {
+	openid := c.GetString("openid")
+	portfolio, err := uc.portfolios.repo.GetPortfolioByUIDFromDB(c, uid)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.ServerError, err)
+		return data.Portfolio{}, "", false
+	}
+	if err == nil && portfolio.Openid == openid {
+		return portfolio, roleOwner, true
+	}
+	if err == nil {
+		ok, err := uc.portfolios.repo.IsActiveReviewer(c, uid, openid)
+		if err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return data.Portfolio{}, "", false
+		}
+		if ok {
+			return portfolio, roleReviewer, true
+		}
+	}
+	ErrorResponse(c, consts.PortfolioAccessDenied, uid)
+	return data.Portfolio{}, "", false
+}
+
+// Connect 升级为 WebSocket 连接，since 为客户端已应用的最后序号，日志仍包含其后的全部操作时补发操作，否则发送快照
+func (uc *CollabUsecase) Connect(c *gin.Context) {
+	portfolio, role, ok := uc.collaborator(c, c.Query("uid"))
+	if !ok {
+		return
+	}
+	since, err := strconv.ParseInt(c.DefaultQuery("since", "-1"), 10, 64)
+	if err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	ws, err := uc.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		// Upgrade 已写入错误响应
+		zap.L().Warn("collab upgrade error", zap.Error(err))
+		return
+	}
+	conn := &collabConn{
+		ws:        ws,
+		portfolio: portfolio,
+		openid:    c.GetString("openid"),
+		self: Collaborator{
+			ConnID:   uuid.New().String(),
+			UserID:   userID(c.GetString("openid")),
+			Role:     role,
+			JoinedAt: time.Now(),
+		},
+		send: make(chan collabOutgoing, collabSendBuffer),
+		done: make(chan struct{}),
+	}
+	ctx := c.Request.Context()
+	// 先加入房间再读取快照，快照之后的修改都会进入 send 缓冲区
+	uc.join(conn)
+	defer uc.leave(ctx, conn)
+	if err := uc.sync(ctx, conn, since); err != nil {
+		zap.L().Error("collab sync error", zap.Error(err))
+		conn.close()
+		return
+	}
+	if err := uc.touch(ctx, conn); err != nil {
+		zap.L().Error("SetPresence error", zap.Error(err))
+	}
+	uc.broadcastPresence(ctx, portfolio.UID)
+	go uc.writePump(ctx, conn)
+	uc.readPump(ctx, conn)
+}
+
+// replayable 判断日志是否恰好包含 since 之后直到当前版本的全部修改。
+// 不连续说明中间的操作已被裁剪或作品集被整体保存过，需要改为发送快照
+func replayable(entries []data.OpLogEntry, since, current int64) bool {
+	last := since
+	for _, entry := range entries {
+		if entry.Seq != last+1 {
+			return false
+		}
+		last = entry.Seq
+	}
+	return last == current
+}
+
+// sync 向新连接补发操作或发送快照，并记录已同步到的序号
+func (uc *CollabUsecase) sync(ctx context.Context, conn *collabConn, since int64) error {
+	if since >= 0 {
+		entries, err := uc.repo.GetOpLog(ctx, conn.portfolio.UID, since)
+		if err != nil {
+			return err
+		}
+		current, err := uc.repo.CurrentRevision(ctx, conn.portfolio.UID)
+		if err != nil {
+			return err
+		}
+		if replayable(entries, since, current) {
+			conn.synced = since
+			for _, entry := range entries {
+				if err := conn.write(entry.Payload); err != nil {
+					return err
+				}
+				conn.synced = entry.Seq
+			}
+			return nil
+		}
+	}
+	portfolio, err := uc.repo.Snapshot(ctx, conn.portfolio.UID)
+	if err != nil {
+		return err
+	}
+	conn.portfolio = portfolio
+	view, err := uc.portfolios.participantView(ctx, portfolio)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(CollabServerMessage{Type: collabSnapshot, Seq: portfolio.Revision, Portfolio: &view})
+	if err != nil {
+		return err
+	}
+	conn.synced = portfolio.Revision
+	return conn.write(payload)
+}
+
+func (uc *CollabUsecase) readPump(ctx context.Context, conn *collabConn) {
+	conn.ws.SetReadLimit(collabMaxMsg)
+	conn.ws.SetReadDeadline(time.Now().Add(collabPongWait))
+	conn.ws.SetPongHandler(func(string) error {
+		return conn.ws.SetReadDeadline(time.Now().Add(collabPongWait))
+	})
+	for {
+		msg := CollabClientMessage{}
+		if err := conn.ws.ReadJSON(&msg); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				conn.reply(CollabServerMessage{Type: collabError, Code: consts.InvalidParams, Msg: err.Error()})
+				continue
+			}
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				zap.L().Warn("collab read error", zap.Error(err))
+			}
+			return
+		}
+		conn.ws.SetReadDeadline(time.Now().Add(collabPongWait))
+		if err := binding.Validator.ValidateStruct(&msg); err != nil {
+			conn.reply(CollabServerMessage{Type: collabError, OpID: msg.OpID, Code: consts.InvalidParams, Msg: err.Error()})
+			continue
+		}
+		switch msg.Type {
+		case collabPing:
+			conn.reply(CollabServerMessage{Type: collabPong})
+		case collabOp:
+			if !uc.handleOps(ctx, conn, msg) {
+				return
+			}
+		}
+	}
+}
+
+// handleOps 执行客户端提交的修改，评审被撤销时返回 false 以断开连接
+func (uc *CollabUsecase) handleOps(ctx context.Context, conn *collabConn, msg CollabClientMessage) bool {
+	if conn.self.Role == roleReviewer {
+		ok, err := uc.portfolios.repo.IsActiveReviewer(ctx, conn.portfolio.UID, conn.openid)
+		if err != nil {
+			zap.L().Error("IsActiveReviewer error", zap.Error(err))
+			conn.reply(CollabServerMessage{Type: collabError, OpID: msg.OpID, Code: consts.ServerError, Msg: err.Error()})
+			return true
+		}
+		if !ok {
+			conn.reply(CollabServerMessage{Type: collabError, OpID: msg.OpID, Code: consts.PortfolioAccessDenied})
+			return false
+		}
+	}
+	if len(msg.Ops) == 0 {
+		conn.reply(CollabServerMessage{Type: collabAck, OpID: msg.OpID})
+		return true
+	}
+	if conn.stale.Swap(false) {
+		portfolio, err := uc.portfolios.repo.GetPortfolioByUIDFromDB(ctx, conn.portfolio.UID)
+		if err != nil {
+			conn.stale.Store(true)
+			zap.L().Error("GetPortfolioByUIDFromDB error", zap.Error(err))
+			conn.reply(CollabServerMessage{Type: collabError, OpID: msg.OpID, Code: consts.ServerError, Msg: err.Error()})
+			return true
+		}
+		conn.portfolio = portfolio
+	}
+	seq, err := uc.applyOps(ctx, conn.portfolio, conn.self, msg.OpID, msg.Ops, nil)
+	if errors.Is(err, errInvalidOps) {
+		conn.reply(CollabServerMessage{Type: collabError, OpID: msg.OpID, Code: consts.InvalidParams, Msg: err.Error()})
+		return true
+	}
+	if errors.Is(err, errWorkKeyDenied) {
+		conn.reply(CollabServerMessage{Type: collabError, OpID: msg.OpID, Code: consts.ObjectAccessDenied, Msg: err.Error()})
+		return true
+	}
+	if err != nil {
+		zap.L().Error("applyOps error", zap.Error(err))
+		conn.reply(CollabServerMessage{Type: collabError, OpID: msg.OpID, Code: consts.ServerError, Msg: err.Error()})
+		return true
+	}
+	conn.reply(CollabServerMessage{Type: collabAck, OpID: msg.OpID, Seq: seq})
+	return true
+}
+
+func (uc *CollabUsecase) writePump(ctx context.Context, conn *collabConn) {
+	ticker := time.NewTicker(collabHeartbeat)
+	defer ticker.Stop()
+	defer conn.close()
+	for {
+		select {
+		case <-conn.done:
+			return
+		case msg := <-conn.send:
+			// 快照或补发已包含的操作不再重复发送
+			if msg.seq != 0 && msg.seq <= conn.synced {
+				continue
+			}
+			if err := conn.write(msg.payload); err != nil {
+				return
+			}
+			if msg.seq != 0 {
+				conn.synced = msg.seq
+			}
+		case <-ticker.C:
+			conn.ws.SetWriteDeadline(time.Now().Add(collabWriteWait))
+			if err := conn.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+			if err := uc.touch(ctx, conn); err != nil {
+				zap.L().Error("SetPresence error", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (uc *CollabUsecase) touch(ctx context.Context, conn *collabConn) error {
+	return uc.repo.SetPresence(ctx, conn.portfolio.UID, data.Presence{
+		ConnID:   conn.self.ConnID,
+		UserID:   conn.self.UserID,
+		Role:     conn.self.Role,
+		JoinedAt: conn.self.JoinedAt,
+		LastSeen: time.Now(),
+	})
+}
+
+func (uc *CollabUsecase) join(conn *collabConn) {
+	uc.mu.Lock()
+	defer uc.mu.Unlock()
+	room, ok := uc.rooms[conn.portfolio.UID]
+	if !ok {
+		room = map[*collabConn]struct{}{}
+		uc.rooms[conn.portfolio.UID] = room
+	}
+	room[conn] = struct{}{}
+}
+
+func (uc *CollabUsecase) leave(ctx context.Context, conn *collabConn) {
+	conn.close()
+	uc.mu.Lock()
+	room := uc.rooms[conn.portfolio.UID]
+	delete(room, conn)
+	if len(room) == 0 {
+		delete(uc.rooms, conn.portfolio.UID)
+	}
+	uc.mu.Unlock()
+	// 请求的 ctx 可能已结束，离开时使用独立的超时
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), collabWriteWait)
+	defer cancel()
+	if err := uc.repo.RemovePresence(ctx, conn.portfolio.UID, conn.self.ConnID); err != nil {
+		zap.L().Error("RemovePresence error", zap.Error(err))
+	}
+	uc.broadcastPresence(ctx, conn.portfolio.UID)
+}
+
+// broadcastPresence 向所有副本广播当前在线的协作者
+func (uc *CollabUsecase) broadcastPresence(ctx context.Context, portfolioUID string) {
+	presences, err := uc.repo.ListPresence(ctx, portfolioUID)
+	if err != nil {
+		zap.L().Error("ListPresence error", zap.Error(err))
+		return
+	}
+	users := make([]Collaborator, 0, len(presences))
+	for _, presence := range presences {
+		users = append(users, Collaborator{
+			ConnID:   presence.ConnID,
+			UserID:   presence.UserID,
+			Role:     presence.Role,
+			JoinedAt: presence.JoinedAt,
+		})
+	}
+	payload, err := json.Marshal(CollabServerMessage{Type: collabPresence, Users: users})
+	if err != nil {
+		zap.L().Error("marshal presence error", zap.Error(err))
+		return
+	}
+	if err := uc.repo.Publish(ctx, portfolioUID, payload); err != nil {
+		zap.L().Error("Publish presence error", zap.Error(err))
+	}
+}
+
+// Run 将 Redis 中的协同消息分发给本副本上的连接，直到 ctx 结束
+func (uc *CollabUsecase) Run(ctx context.Context) {
+	for event := range uc.repo.Subscribe(ctx) {
+		header := struct {
+			Type string `json:"type"`
+			Seq  int64  `json:"seq"`
+		}{}
+		if err := json.Unmarshal(event.Payload, &header); err != nil {
+			zap.L().Error("unmarshal collab event error", zap.Error(err))
+			continue
+		}
+		msg := collabOutgoing{payload: event.Payload}
+		if header.Type == collabOp {
+			msg.seq = header.Seq
+		}
+		uc.mu.Lock()
+		for conn := range uc.rooms[event.PortfolioUID] {
+			if header.Type == collabResync {
+				conn.stale.Store(true)
+			}
+			conn.enqueue(msg)
+		}
+		uc.mu.Unlock()
+	}
+}
@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Fl0rencess720/Springboard/internal/data"
+)
+
+func TestReplayable(t *testing.T) {
+	entries := func(seqs ...int64) []data.OpLogEntry {
+		out := []data.OpLogEntry{}
+		for _, seq := range seqs {
+			out = append(out, data.OpLogEntry{Seq: seq})
+		}
+		return out
+	}
+	tests := []struct {
+		name    string
+		entries []data.OpLogEntry
+		since   int64
+		current int64
+		want    bool
+	}{
+		{name: "up to date", since: 5, current: 5, want: true},
+		{name: "contiguous", entries: entries(6, 7, 8), since: 5, current: 8, want: true},
+		{name: "trimmed", entries: entries(7, 8), since: 5, current: 8},
+		{name: "gap", entries: entries(6, 8), since: 5, current: 8},
+		{name: "missing tail", entries: entries(6, 7), since: 5, current: 8},
+		// 整体保存增加了版本号但没有写入日志
+		{name: "no entries behind", since: 5, current: 6},
+		{name: "ahead of current", entries: entries(6), since: 5, current: 5},
+		{name: "client ahead", since: 9, current: 8},
+	}
+	for _, tt := range tests {
+		if got := replayable(tt.entries, tt.since, tt.current); got != tt.want {
+			t.Errorf("%s: replayable = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
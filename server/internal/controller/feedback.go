@@ -3,8 +3,10 @@ package controller
 import (
 	"context"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/Fl0rencess720/Springboard/consts"
 	"github.com/Fl0rencess720/Springboard/internal/data"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -12,12 +14,35 @@ import (
 )
 
 type UpdateStatusRequest struct {
-	UID    string `json:"uid"`
-	Status int    `json:"status"`
+	UID    string `json:"uid" binding:"required"`
+	Status int    `json:"status" binding:"oneof=0 1 2"`
 }
 
 type AddFeedbackRequest struct {
-	Content string `json:"content"`
+	Content     string   `json:"content" binding:"required"`
+	Attachments []string `json:"attachments" doc:"通过 purpose=feedback 上传的截图 oss key"`
+}
+
+type FeedbackResponse struct {
+	UID         string              `json:"uid"`
+	Content     string              `json:"content"`
+	Attachments []string            `json:"attachments"`
+	Timestamp   time.Time           `json:"timestamp"`
+	Status      data.FeedbackStatus `json:"status" doc:"0 待处理，1 已采纳，2 已拒绝"`
+}
+
+func toFeedbackResponses(feedbacks []data.Feedback) []FeedbackResponse {
+	resp := make([]FeedbackResponse, 0, len(feedbacks))
+	for _, feedback := range feedbacks {
+		resp = append(resp, FeedbackResponse{
+			UID:         feedback.UID,
+			Content:     feedback.Content,
+			Attachments: feedback.Attachments,
+			Timestamp:   feedback.Timestamp,
+			Status:      feedback.Status,
+		})
+	}
+	return resp
 }
 
 type FeedbackRepo interface {
@@ -41,12 +66,21 @@ func (sc *FeedbackUseCase) AddFeedback(c *gin.Context) {
 	req := AddFeedbackRequest{}
 	feedback := data.Feedback{}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
 	feedback.UID = uuid.New().String()
 	feedback.Timestamp = time.Now()
 	feedback.Content = req.Content
+	// 只接受当前用户上传的反馈截图
+	prefix := "uploads/" + PurposeFeedback + "/" + MD5(c.GetString("openid")) + "/"
+	for _, key := range req.Attachments {
+		if !strings.HasPrefix(key, prefix) {
+			ErrorResponse(c, consts.ObjectAccessDenied, key)
+			return
+		}
+	}
+	feedback.Attachments = req.Attachments
 	if err := sc.repo.AddFeedbackToDB(c, feedback); err != nil {
 		zap.L().Error("SaveFeedback error", zap.Error(err))
 	}
@@ -56,7 +90,7 @@ func (sc *FeedbackUseCase) AddFeedback(c *gin.Context) {
 func (sc *FeedbackUseCase) GetAllFeedbacks(c *gin.Context) {
 	feedbacks, err := sc.repo.GetAllFeedbacksFromDB(c)
 	if err == nil {
-		SuccessResponse(c, feedbacks)
+		SuccessResponse(c, toFeedbackResponses(feedbacks))
 		return
 	}
 	zap.L().Error("GetAllFeedbacksFromDB error", zap.Error(err))
@@ -65,12 +99,12 @@ func (sc *FeedbackUseCase) GetAllFeedbacks(c *gin.Context) {
 func (sc *FeedbackUseCase) GetFeedbacksByStatus(c *gin.Context) {
 	statusInt, err := strconv.Atoi(c.DefaultQuery("status", "0"))
 	if err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 	}
 	status := data.FeedbackStatus(statusInt)
 	feedbacks, err := sc.repo.GetFeedbacksByStatusFromDB(status, c)
 	if err == nil {
-		SuccessResponse(c, feedbacks)
+		SuccessResponse(c, toFeedbackResponses(feedbacks))
 		return
 	}
 	zap.L().Error("GetFeedbacksByStatusFromDB error", zap.Error(err))
@@ -79,12 +113,12 @@ func (sc *FeedbackUseCase) GetFeedbacksByStatus(c *gin.Context) {
 func (sc *FeedbackUseCase) UpdateFeedbacksStatus(c *gin.Context) {
 	req := UpdateStatusRequest{}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
 	status := data.FeedbackStatus(req.Status)
 	if err := sc.repo.UpdateFeedbacksStatus(req.UID, status, c); err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
 	SuccessResponse(c, nil)
@@ -0,0 +1,118 @@
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/Fl0rencess720/Springboard/pkgs/imaging"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 图片变体，thumb 用于列表，preview 用于编辑器预览
+const (
+	VariantOriginal = "original"
+	VariantPreview  = "preview"
+	VariantThumb    = "thumb"
+)
+
+var renditions = []imaging.Rendition{
+	{Name: VariantThumb, MaxSide: 320, Quality: 80},
+	{Name: VariantPreview, MaxSide: 1600, Quality: 85},
+}
+
+const (
+	// 同时处理的图片数量，解码大图占用内存较多
+	maxConcurrentProcessing = 4
+	processTimeout          = 2 * time.Minute
+	// 处理超时后 ctx 已失效，失败状态使用单独的超时写入
+	statusTimeout = 5 * time.Second
+)
+
+// renditionKey 缩略图与原图放在同一目录，例如 uploads/work/x/uuid_thumb.jpg
+func renditionKey(ossKey, variant string) string {
+	if i := strings.LastIndex(ossKey, "."); i > strings.LastIndex(ossKey, "/") {
+		ossKey = ossKey[:i]
+	}
+	return ossKey + "_" + variant + ".jpg"
+}
+
+// processAsync 在后台处理图片，不阻塞确认上传的请求
+func (uc *OSSUsecase) processAsync(upload data.Upload) {
+	go func() {
+		uc.processing <- struct{}{}
+		defer func() { <-uc.processing }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), processTimeout)
+		defer cancel()
+		if err := uc.processImage(ctx, upload); err != nil {
+			zap.L().Error("processImage error", zap.String("oss_key", upload.OSSKey), zap.Error(err))
+			statusCtx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+			defer cancel()
+			if err := uc.repo.SetProcessStatus(statusCtx, upload.OSSKey, data.ProcessFailed); err != nil {
+				zap.L().Error("SetProcessStatus error", zap.Error(err))
+			}
+		}
+	}()
+}
+
+func (uc *OSSUsecase) processImage(ctx context.Context, upload data.Upload) error {
+	policy := UploadPolicies[upload.Purpose]
+	raw, err := uc.storage.GetObject(ctx, upload.OSSKey, policy.MaxSize)
+	if err != nil {
+		return err
+	}
+	// 按原始内容计算哈希，重复上传无需再次处理
+	sum := sha256.Sum256(raw)
+	digest := hex.EncodeToString(sum[:])
+	if policy.Dedup {
+		asset, err := uc.repo.GetAssetBySHA256(ctx, digest)
+		if err == nil {
+			return uc.repo.LinkAsset(ctx, upload.OSSKey, asset)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+	}
+	result, err := imaging.Process(raw, renditions)
+	if err != nil {
+		return err
+	}
+
+	meta := data.ImageMeta{
+		Size:       int64(len(raw)),
+		Width:      result.Width,
+		Height:     result.Height,
+		DPI:        result.DPI,
+		ThumbKey:   renditionKey(upload.OSSKey, VariantThumb),
+		PreviewKey: renditionKey(upload.OSSKey, VariantPreview),
+	}
+	for name, body := range result.Renditions {
+		if err := uc.storage.PutObject(ctx, renditionKey(upload.OSSKey, name), "image/jpeg", body); err != nil {
+			return err
+		}
+	}
+	// 原图中含有 EXIF、GPS 或方向需要校正时覆盖原对象
+	if result.Original != nil {
+		if err := uc.storage.PutObject(ctx, upload.OSSKey, upload.ContentType, result.Original); err != nil {
+			return err
+		}
+		meta.Size = int64(len(result.Original))
+	}
+	if err := uc.repo.SaveImageMeta(ctx, upload.OSSKey, meta); err != nil {
+		return err
+	}
+	if policy.Dedup {
+		upload.Size, upload.Width, upload.Height, upload.DPI = meta.Size, meta.Width, meta.Height, meta.DPI
+		upload.ThumbKey, upload.PreviewKey = meta.ThumbKey, meta.PreviewKey
+		if _, err := uc.repo.CreateAsset(ctx, upload, digest); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,280 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/Fl0rencess720/Springboard/pkgs/layout"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errNoContentPage = errors.New("template has no content page with image slots")
+
+// LayoutOptions 为自动排版参数，作品按顺序填满一页的图片空位后换下一页，依次使用模板的内容页
+type LayoutOptions struct {
+	Policy    layout.Policy `json:"policy" binding:"omitempty,oneof=fit fill cover" doc:"为空时使用空位的默认方式"`
+	StartPage *int          `json:"start_page" binding:"omitempty,min=0" doc:"第一张作品的页码，为空时从第一张内容页开始；封面与分隔页会被跳过"`
+}
+
+type LayoutRequest struct {
+	TemplateUID  string        `json:"template_uid" binding:"required"`
+	PortfolioUID string        `json:"portfolio_uid" doc:"不为空时按该作品集已有页面的类型跳过封面与分隔页"`
+	Options      LayoutOptions `json:"options"`
+	Works        []WorkInput   `json:"works" binding:"required,max=500,dive"`
+}
+
+type LayoutWork struct {
+	WorkInput
+	PageUID string `json:"page_uid" doc:"使用的模板内容页"`
+}
+
+type LayoutResponse struct {
+	Works []LayoutWork `json:"works"`
+	// Unsized 为尚未处理完成或不属于当前用户的图片，只分配页码与空位，保持原有位置
+	Unsized []string `json:"unsized"`
+}
+
+// Layout 计算作品在模板内容页上的位置，不保存
+func (uc *PortfolioUsecase) Layout(c *gin.Context) {
+	req := LayoutRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	for i, work := range req.Works {
+		if err := work.Transform.validate(true); err != nil {
+			ErrorResponse(c, consts.InvalidParams, fmt.Errorf("works[%d].transform: %w", i, err))
+			return
+		}
+	}
+	template, err := uc.repo.GetTemplateByUIDFromDB(c, req.TemplateUID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.InvalidParams, "template not found: "+req.TemplateUID)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	var portfolioPages []data.PortfolioPage
+	if req.PortfolioUID != "" {
+		portfolio, ok := uc.ownedPortfolio(c, req.PortfolioUID)
+		if !ok {
+			return
+		}
+		portfolioPages = portfolio.Pages
+	}
+	works := make([]*WorkInput, 0, len(req.Works))
+	for i := range req.Works {
+		works = append(works, &req.Works[i])
+	}
+	pages, unsized, err := uc.layoutWorks(c, c.GetString("openid"), template, portfolioPages, works, req.Options)
+	if errors.Is(err, errNoContentPage) {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	resp := LayoutResponse{Works: make([]LayoutWork, 0, len(req.Works)), Unsized: unsized}
+	for i, work := range req.Works {
+		resp.Works = append(resp.Works, LayoutWork{WorkInput: work, PageUID: pages[i]})
+	}
+	SuccessResponse(c, resp)
+}
+
+// layoutWorks 按顺序为作品分配页码与空位并写入几何信息，返回每个作品使用的内容页 UID
+// 尺寸未知的作品同样占用空位，避免后续作品的位置随处理进度变化；
+// portfolioPages 为作品集已有的页面，其中的封面与分隔页不放入作品
+func (uc *PortfolioUsecase) layoutWorks(ctx context.Context, openid string, template data.Template,
+	portfolioPages []data.PortfolioPage, works []*WorkInput, opts LayoutOptions) ([]string, []string, error) {
+	type pageSlots struct {
+		uid   string
+		slots []data.PageSlot
+		rects []layout.Rect
+	}
+	pages := []pageSlots{}
+	for _, page := range template.Pages {
+		if !page.IsContentPage {
+			continue
+		}
+		p := pageSlots{uid: page.UID}
+		for _, slot := range page.Slots {
+			if slot.Type != data.SlotImage {
+				continue
+			}
+			rect, err := parseSlot(slot)
+			if err != nil {
+				return nil, nil, fmt.Errorf("slot %s: %w", slot.UID, err)
+			}
+			// 尺寸为 0 的空位无法放入图片
+			if rect.Width <= 0 || rect.Height <= 0 {
+				continue
+			}
+			p.slots = append(p.slots, slot)
+			p.rects = append(p.rects, rect)
+		}
+		if len(p.slots) > 0 {
+			pages = append(pages, p)
+		}
+	}
+	if len(pages) == 0 {
+		return nil, nil, errNoContentPage
+	}
+
+	keys := make([]string, 0, len(works))
+	for _, work := range works {
+		keys = append(keys, work.OSSKey)
+	}
+	uploads, err := uc.repo.GetImageSizes(ctx, openid, dedupe(keys))
+	if err != nil {
+		return nil, nil, err
+	}
+	sizes := make(map[string]data.Upload, len(uploads))
+	for _, upload := range uploads {
+		sizes[upload.OSSKey] = upload
+	}
+
+	skipped := map[int]struct{}{}
+	for _, page := range portfolioPages {
+		if page.Kind != data.PageContent {
+			skipped[page.Number] = struct{}{}
+		}
+	}
+	nextContentPage := func(number int) int {
+		for {
+			if _, ok := skipped[number]; !ok {
+				return number
+			}
+			number++
+		}
+	}
+	pageNum := 0
+	if opts.StartPage != nil {
+		pageNum = *opts.StartPage
+	}
+	pageNum = nextContentPage(pageNum)
+
+	pageUIDs := make([]string, len(works))
+	unsized := []string{}
+	pageIndex, slotIndex := 0, 0
+	for i, work := range works {
+		if slotIndex == len(pages[pageIndex].slots) {
+			pageNum = nextContentPage(pageNum + 1)
+			pageIndex = (pageIndex + 1) % len(pages)
+			slotIndex = 0
+		}
+		page := pages[pageIndex]
+		slot, rect := page.slots[slotIndex], page.rects[slotIndex]
+		slotIndex++
+
+		work.PageNum = pageNum
+		work.SlotUID = slot.UID
+		pageUIDs[i] = page.uid
+		size, ok := sizes[work.OSSKey]
+		if !ok || size.Width <= 0 || size.Height <= 0 {
+			unsized = append(unsized, work.OSSKey)
+			continue
+		}
+		policy := opts.Policy
+		if policy == "" {
+			policy = layout.Policy(slot.Policy)
+		}
+		placement, err := layout.Place(rect, size.Width, size.Height, policy)
+		if err != nil {
+			return nil, nil, err
+		}
+		work.Size, work.MarginLeft, work.MarginTop = boxGeometry(placement.Box)
+		work.Scale = 1
+		work.Transform.Crop = nil
+		if placement.Crop != nil {
+			crop := CropRect(*placement.Crop)
+			work.Transform.Crop = &crop
+		}
+	}
+	return pageUIDs, unsized, nil
+}
+
+// validateSlots 检查作品与文本引用的空位属于模板且类型匹配，同一页的同一空位只能放一个作品
+func validateSlots(template data.Template, projects []ProjectInput) error {
+	slots := map[string]data.PageSlot{}
+	for _, page := range template.Pages {
+		for _, slot := range page.Slots {
+			slots[slot.UID] = slot
+		}
+	}
+	type position struct {
+		page int
+		slot string
+	}
+	occupied := map[position]struct{}{}
+	for i, project := range projects {
+		for j, work := range project.Works {
+			if work.SlotUID == "" {
+				continue
+			}
+			slot, ok := slots[work.SlotUID]
+			if !ok || slot.Type != data.SlotImage {
+				return fmt.Errorf("projects[%d].works[%d]: %s is not an image slot of the template", i, j, work.SlotUID)
+			}
+			pos := position{page: work.PageNum, slot: work.SlotUID}
+			if _, ok := occupied[pos]; ok {
+				return fmt.Errorf("projects[%d].works[%d]: slot %s on page %d is already taken", i, j, work.SlotUID, work.PageNum)
+			}
+			occupied[pos] = struct{}{}
+		}
+		for j, text := range project.Texts {
+			if text.SlotUID == "" {
+				continue
+			}
+			slot, ok := slots[text.SlotUID]
+			if !ok || (slot.Type != data.SlotText && slot.Type != data.SlotCaption) {
+				return fmt.Errorf("projects[%d].texts[%d]: %s is not a text slot of the template", i, j, text.SlotUID)
+			}
+			if slot.MaxLength > 0 && utf8.RuneCountInString(text.toModel("").Content) > slot.MaxLength {
+				return fmt.Errorf("projects[%d].texts[%d]: text exceeds %d characters", i, j, slot.MaxLength)
+			}
+		}
+	}
+	return nil
+}
+
+// parseSlot 解析空位的位置与大小，Size 格式为 axb
+func parseSlot(slot data.PageSlot) (layout.Rect, error) {
+	w, h, ok := strings.Cut(slot.Size, "x")
+	if !ok {
+		return layout.Rect{}, fmt.Errorf("invalid slot size %q", slot.Size)
+	}
+	values := []string{slot.MarginLeft, slot.MarginTop, w, h}
+	parsed := make([]float64, len(values))
+	for i, v := range values {
+		v = strings.TrimSuffix(strings.TrimSpace(v), "px")
+		if v == "" {
+			continue
+		}
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return layout.Rect{}, fmt.Errorf("invalid slot value %q", values[i])
+		}
+		parsed[i] = n
+	}
+	return layout.Rect{X: parsed[0], Y: parsed[1], Width: parsed[2], Height: parsed[3]}, nil
+}
+
+// boxGeometry 将矩形转换为作品的 Size、MarginLeft、MarginTop
+func boxGeometry(box layout.Rect) (string, string, string) {
+	return formatLength(box.Width) + "x" + formatLength(box.Height), formatLength(box.X), formatLength(box.Y)
+}
+
+func formatLength(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/Fl0rencess720/Springboard/pkgs/oss"
+	"github.com/gin-gonic/gin"
+)
+
+// 默认分片大小，文件过大导致分片数超过上限时按上限均分
+const defaultPartSize = 5 << 20
+
+type InitiateMultipartRequest struct {
+	Purpose     string `json:"purpose" doc:"上传用途，默认为 work"`
+	ContentType string `json:"content_type" binding:"required"`
+	Size        int64  `json:"size" binding:"required,min=1" doc:"文件总字节数"`
+}
+
+type InitiateMultipartResponse struct {
+	OSSKey    string `json:"oss_key"`
+	PartSize  int64  `json:"part_size"`
+	PartCount int    `json:"part_count"`
+}
+
+type MultipartRequest struct {
+	OSSKey string `json:"oss_key" binding:"required"`
+}
+
+type UploadPartUrlResponse struct {
+	UploadUrl  string `json:"upload_url"`
+	PartNumber int32  `json:"part_number"`
+}
+
+type ListPartsResponse struct {
+	OSSKey    string         `json:"oss_key"`
+	PartSize  int64          `json:"part_size"`
+	PartCount int            `json:"part_count"`
+	Parts     []oss.PartMeta `json:"parts"`
+}
+
+func partLayout(size int64) (int64, int) {
+	partSize := max(int64(defaultPartSize), (size+oss.MaxParts-1)/oss.MaxParts)
+	return partSize, int((size + partSize - 1) / partSize)
+}
+
+// InitiateMultipartUpload 大文件分片上传，服务端决定分片大小，客户端按分片依次申请上传 URL
+func (uc *OSSUsecase) InitiateMultipartUpload(c *gin.Context) {
+	req := InitiateMultipartRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	if req.Purpose == "" {
+		req.Purpose = PurposeWork
+	}
+	ext, ok := checkUploadPolicy(c, req.Purpose, req.ContentType, req.Size)
+	if !ok {
+		return
+	}
+	objectkey := oss.GenerateUniqueKey(req.Purpose, MD5(c.GetString("openid")), ext)
+	uploadID, err := uc.storage.InitiateMultipartUpload(c, objectkey, req.ContentType)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if err := uc.repo.CreateUpload(c, data.Upload{
+		OSSKey:      objectkey,
+		Openid:      c.GetString("openid"),
+		Purpose:     req.Purpose,
+		ContentType: req.ContentType,
+		Size:        req.Size,
+		Status:      data.UploadPending,
+		MultipartID: uploadID,
+	}); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	partSize, partCount := partLayout(req.Size)
+	SuccessResponse(c, InitiateMultipartResponse{
+		OSSKey:    objectkey,
+		PartSize:  partSize,
+		PartCount: partCount,
+	})
+}
+
+// pendingMultipart 查询当前用户尚未完成的分片上传
+func (uc *OSSUsecase) pendingMultipart(c *gin.Context, ossKey string) (data.Upload, bool) {
+	upload, ok := uc.ownedUpload(c, ossKey)
+	if !ok {
+		return data.Upload{}, false
+	}
+	if upload.MultipartID == "" || upload.Status != data.UploadPending {
+		ErrorResponse(c, consts.InvalidParams, "not a pending multipart upload "+ossKey)
+		return data.Upload{}, false
+	}
+	return upload, true
+}
+
+func (uc *OSSUsecase) GetUploadPartUrl(c *gin.Context) {
+	upload, ok := uc.pendingMultipart(c, c.Query("ossKey"))
+	if !ok {
+		return
+	}
+	partNumber, err := strconv.ParseInt(c.Query("partNumber"), 10, 32)
+	if _, partCount := partLayout(upload.Size); err != nil || partNumber < 1 || partNumber > int64(partCount) {
+		ErrorResponse(c, consts.InvalidParams, "invalid part number "+c.Query("partNumber"))
+		return
+	}
+	uploadUrl, err := uc.storage.PresignUploadPart(upload.OSSKey, upload.MultipartID, int32(partNumber))
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, UploadPartUrlResponse{
+		UploadUrl:  uploadUrl,
+		PartNumber: int32(partNumber),
+	})
+}
+
+// ListUploadedParts 断点续传时查询已上传的分片
+func (uc *OSSUsecase) ListUploadedParts(c *gin.Context) {
+	upload, ok := uc.pendingMultipart(c, c.Query("ossKey"))
+	if !ok {
+		return
+	}
+	parts, err := uc.storage.ListParts(c, upload.OSSKey, upload.MultipartID)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	partSize, partCount := partLayout(upload.Size)
+	SuccessResponse(c, ListPartsResponse{
+		OSSKey:    upload.OSSKey,
+		PartSize:  partSize,
+		PartCount: partCount,
+		Parts:     parts,
+	})
+}
+
+// CompleteMultipartUpload 合并分片后按普通上传的确认流程检查对象
+func (uc *OSSUsecase) CompleteMultipartUpload(c *gin.Context) {
+	req := MultipartRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	upload, ok := uc.ownedUpload(c, req.OSSKey)
+	if !ok {
+		return
+	}
+	// 重复调用时对象已合并，直接返回确认结果
+	if upload.Status == data.UploadPending {
+		if upload.MultipartID == "" {
+			ErrorResponse(c, consts.InvalidParams, "not a multipart upload "+req.OSSKey)
+			return
+		}
+		if err := uc.storage.CompleteMultipartUpload(c, upload.OSSKey, upload.MultipartID); err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+	}
+	uc.confirm(c, upload)
+}
+
+func (uc *OSSUsecase) AbortMultipartUpload(c *gin.Context) {
+	req := MultipartRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	upload, ok := uc.pendingMultipart(c, req.OSSKey)
+	if !ok {
+		return
+	}
+	if err := uc.storage.AbortMultipartUpload(c, upload.OSSKey, upload.MultipartID); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if err := uc.repo.DeleteUploads(c, []string{upload.OSSKey}); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, nil)
+}
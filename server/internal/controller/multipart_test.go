@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/Fl0rencess720/Springboard/pkgs/oss"
+)
+
+func TestPartLayout(t *testing.T) {
+	const mib = 1 << 20
+	tests := []struct {
+		size      int64
+		partSize  int64
+		partCount int
+	}{
+		{size: 1, partSize: defaultPartSize, partCount: 1},
+		{size: defaultPartSize, partSize: defaultPartSize, partCount: 1},
+		{size: defaultPartSize + 1, partSize: defaultPartSize, partCount: 2},
+		{size: 12 * mib, partSize: defaultPartSize, partCount: 3},
+		{size: oss.MaxParts * defaultPartSize, partSize: defaultPartSize, partCount: oss.MaxParts},
+		// 超过默认分片大小能容纳的上限后，按上限均分
+		{size: oss.MaxParts*defaultPartSize + 1, partSize: defaultPartSize + 1, partCount: oss.MaxParts},
+		{size: oss.MaxParts * 8 * mib, partSize: 8 * mib, partCount: oss.MaxParts},
+	}
+	for _, tt := range tests {
+		partSize, partCount := partLayout(tt.size)
+		if partSize != tt.partSize || partCount != tt.partCount {
+			t.Errorf("partLayout(%d) = %d, %d; want %d, %d", tt.size, partSize, partCount, tt.partSize, tt.partCount)
+		}
+	}
+}
+
+// 任意大小下分片都能完整覆盖文件，且最后一片不为空
+func TestPartLayoutCoversFile(t *testing.T) {
+	for _, size := range []int64{1, 4<<20 + 7, 5<<30 + 3, 48<<30 + 1, 1 << 40} {
+		partSize, partCount := partLayout(size)
+		if partCount < 1 || partCount > oss.MaxParts {
+			t.Errorf("size %d: part count %d out of range", size, partCount)
+		}
+		if partSize*int64(partCount) < size || partSize*int64(partCount-1) >= size {
+			t.Errorf("size %d: %d parts of %d bytes do not fit", size, partCount, partSize)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// OpInput 为一次细粒度修改，按 type 填写对应字段
+type OpInput struct {
+	Type       data.OpType    `json:"type" binding:"required,oneof=move_work update_text move_page add_work move_project delete_page" doc:"move_work、update_text、move_page、add_work、move_project 或 delete_page"`
+	WorkUID    string         `json:"work_uid,omitempty" doc:"move_work 的作品；add_work 时由服务端生成"`
+	Work       *WorkPlacement `json:"work,omitempty" doc:"move_work 后或 add_work 新作品的位置与变换"`
+	OSSKey     string         `json:"oss_key,omitempty" doc:"add_work 的图片"`
+	TextUID    string         `json:"text_uid,omitempty" doc:"update_text 的文本"`
+	Text       *TextInput     `json:"text,omitempty" doc:"update_text 后文本的完整内容与样式，uid 被忽略"`
+	PageUID    string         `json:"page_uid,omitempty" doc:"move_page、delete_page 的页面"`
+	PageNum    int            `json:"page_num,omitempty" binding:"min=0" doc:"move_page 的目标位置，超出末尾时移到最后"`
+	ProjectUID string         `json:"project_uid,omitempty" doc:"add_work 所属的项目，move_project 的项目"`
+	Position   int            `json:"position,omitempty" binding:"min=0" doc:"move_project 的目标位置，超出末尾时移到最后"`
+}
+
+// WorkPlacement 为作品可以在协同编辑中修改的位置与变换
+type WorkPlacement struct {
+	Size       string         `json:"size"`
+	MarginTop  string         `json:"margin_top"`
+	MarginLeft string         `json:"margin_left"`
+	Scale      float64        `json:"scale" doc:"1.0 表示不缩放"`
+	PageNum    int            `json:"page_num" binding:"min=0"`
+	SlotUID    string         `json:"slot_uid" doc:"模板中的图片空位，为空表示自由摆放"`
+	Transform  TransformInput `json:"transform"`
+}
+
+// errInvalidOps 为客户端提交的修改无法执行，与服务端错误区分
+var errInvalidOps = errors.New("invalid ops")
+
+// validateOps 检查无法用 binding 表达的规则，字体与空位必须属于作品集所用的模板
+func validateOps(template data.Template, ops []OpInput) error {
+	families := map[string]struct{}{}
+	for _, font := range template.Fonts {
+		families[font.Family] = struct{}{}
+	}
+	slots := map[string]data.PageSlot{}
+	for _, page := range template.Pages {
+		for _, slot := range page.Slots {
+			slots[slot.UID] = slot
+		}
+	}
+	for i, op := range ops {
+		switch op.Type {
+		case data.OpMoveWork, data.OpAddWork:
+			if op.Type == data.OpMoveWork && (op.WorkUID == "" || op.Work == nil) {
+				return fmt.Errorf("ops[%d]: work_uid and work are required", i)
+			}
+			if op.Type == data.OpAddWork && (op.ProjectUID == "" || op.OSSKey == "" || op.Work == nil) {
+				return fmt.Errorf("ops[%d]: project_uid, oss_key and work are required", i)
+			}
+			if err := op.Work.Transform.validate(true); err != nil {
+				return fmt.Errorf("ops[%d].work.transform: %w", i, err)
+			}
+			if op.Work.SlotUID != "" {
+				if slot, ok := slots[op.Work.SlotUID]; !ok || slot.Type != data.SlotImage {
+					return fmt.Errorf("ops[%d]: %s is not an image slot of the template", i, op.Work.SlotUID)
+				}
+			}
+		case data.OpUpdateText:
+			if op.TextUID == "" || op.Text == nil {
+				return fmt.Errorf("ops[%d]: text_uid and text are required", i)
+			}
+			if err := op.Text.Transform.validate(false); err != nil {
+				return fmt.Errorf("ops[%d].text.transform: %w", i, err)
+			}
+			for _, family := range op.Text.fontFamilies() {
+				if _, ok := families[family]; !ok {
+					return fmt.Errorf("ops[%d]: font family not provided by template: %s", i, family)
+				}
+			}
+			if op.Text.SlotUID != "" {
+				slot, ok := slots[op.Text.SlotUID]
+				if !ok || (slot.Type != data.SlotText && slot.Type != data.SlotCaption) {
+					return fmt.Errorf("ops[%d]: %s is not a text slot of the template", i, op.Text.SlotUID)
+				}
+				if slot.MaxLength > 0 && utf8.RuneCountInString(op.Text.toModel("").Content) > slot.MaxLength {
+					return fmt.Errorf("ops[%d]: text exceeds %d characters", i, slot.MaxLength)
+				}
+			}
+		case data.OpMovePage, data.OpDeletePage:
+			if op.PageUID == "" {
+				return fmt.Errorf("ops[%d]: page_uid is required", i)
+			}
+		case data.OpMoveProject:
+			if op.ProjectUID == "" {
+				return fmt.Errorf("ops[%d]: project_uid is required", i)
+			}
+		}
+	}
+	return nil
+}
+
+func (in OpInput) toModel() data.Op {
+	switch in.Type {
+	case data.OpMoveWork, data.OpAddWork:
+		return data.Op{
+			Type: in.Type,
+			UID:  in.WorkUID,
+			Work: data.Work{
+				OSSKey:     in.OSSKey,
+				ProjectUID: in.ProjectUID,
+				Size:       in.Work.Size,
+				MarginTop:  in.Work.MarginTop,
+				MarginLeft: in.Work.MarginLeft,
+				Scale:      in.Work.Scale,
+				PageNum:    in.Work.PageNum,
+				SlotUID:    in.Work.SlotUID,
+				Transform:  in.Work.Transform.toModel(),
+			},
+		}
+	case data.OpUpdateText:
+		return data.Op{Type: in.Type, UID: in.TextUID, Text: in.Text.toModel("")}
+	case data.OpMoveProject:
+		return data.Op{Type: in.Type, UID: in.ProjectUID, To: in.Position}
+	}
+	return data.Op{Type: in.Type, UID: in.PageUID, To: in.PageNum}
+}
+
+// applyOps 校验并按服务端顺序执行一批修改，成功后广播给所有副本并写入操作日志，返回分配的序号。
+// 批内的修改在同一事务中执行，任一失败时整批不生效；add_work 的 work_uid 由服务端生成并写回 ops，
+// 且只能使用作品集所有者可以使用的对象，评审人提交时也是如此，否则作品无法按所有者签发 URL。
+// ifRevision 不为空且与当前版本不一致时返回 data.ErrRevisionConflict
+func (uc *CollabUsecase) applyOps(ctx context.Context, portfolio data.Portfolio, author Collaborator, opID string,
+	ops []OpInput, ifRevision *int64) (int64, error) {
+	template, err := uc.portfolios.repo.GetTemplateByUIDFromDB(ctx, portfolio.TemplateUID)
+	if err != nil {
+		return 0, err
+	}
+	if err := validateOps(template, ops); err != nil {
+		return 0, fmt.Errorf("%w: %v", errInvalidOps, err)
+	}
+	ossKeys := []string{}
+	for _, op := range ops {
+		if op.Type == data.OpAddWork {
+			ossKeys = append(ossKeys, op.OSSKey)
+		}
+	}
+	if err := uc.portfolios.checkWorkKeys(ctx, portfolio.Openid, ossKeys); err != nil {
+		return 0, err
+	}
+	models := make([]data.Op, 0, len(ops))
+	for i := range ops {
+		if ops[i].Type == data.OpAddWork {
+			ops[i].WorkUID = uuid.New().String()
+		}
+		models = append(models, ops[i].toModel())
+	}
+	var published []byte
+	seq, err := uc.repo.ApplyOps(ctx, portfolio.UID, models, ifRevision, func(seq int64) error {
+		payload, err := json.Marshal(CollabServerMessage{
+			Type:   collabOp,
+			Seq:    seq,
+			OpID:   opID,
+			Author: &author,
+			Ops:    ops,
+		})
+		if err != nil {
+			return err
+		}
+		if err := uc.repo.Publish(ctx, portfolio.UID, payload); err != nil {
+			return err
+		}
+		published = payload
+		return nil
+	})
+	if err != nil && published != nil {
+		// 修改已广播但未能提交，通知客户端丢弃并以快照为准
+		uc.portfolios.broadcastResync(ctx, portfolio.UID, seq-1)
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("%w: target not found", errInvalidOps)
+	}
+	if err != nil {
+		return 0, err
+	}
+	// 日志只用于补发，只记录已提交的修改；写入失败时重连的客户端会改为接收快照
+	if err := uc.repo.AppendOpLog(ctx, portfolio.UID, seq, published); err != nil {
+		zap.L().Error("AppendOpLog error", zap.Error(err))
+	}
+	if err := uc.portfolios.repo.DeletePortfoliosFromRedis(ctx, portfolio.Openid); err != nil {
+		zap.L().Error("DeletePortfoliosFromRedis error", zap.Error(err))
+	}
+	return seq, nil
+}
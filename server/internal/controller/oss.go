@@ -2,25 +2,103 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/data"
 	"github.com/Fl0rencess720/Springboard/pkgs/oss"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
+type PreviewSignedUrlResponse struct {
+	PreviewUrl string    `json:"previewUrl"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
+
+type UploadSignedUrlResponse struct {
+	UploadUrl string `json:"uploadUrl"`
+	OSSKey    string `json:"ossKey"`
+	// Headers 为上传时必须原样携带的请求头，包含 Content-Type 与 Content-Length
+	Headers map[string]string `json:"headers"`
+}
+
+type ConfirmUploadRequest struct {
+	OSSKey string `json:"oss_key" binding:"required"`
+}
+
+type UploadResponse struct {
+	OSSKey        string             `json:"oss_key"`
+	Purpose       string             `json:"purpose"`
+	ContentType   string             `json:"content_type"`
+	Size          int64              `json:"size"`
+	Status        data.UploadStatus  `json:"status" doc:"0 待确认，1 已确认"`
+	ProcessStatus data.ProcessStatus `json:"process_status" doc:"0 未处理，1 处理中，2 已完成，3 失败"`
+	// Width、Height 为校正方向后的像素尺寸，图片处理完成后才有值
+	Width       int        `json:"width"`
+	Height      int        `json:"height"`
+	DPI         float64    `json:"dpi"`
+	ThumbKey    string     `json:"thumb_key"`
+	PreviewKey  string     `json:"preview_key"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ConfirmedAt *time.Time `json:"confirmed_at"`
+}
+
+func toUploadResponse(upload data.Upload) UploadResponse {
+	return UploadResponse{
+		OSSKey:        upload.OSSKey,
+		Purpose:       upload.Purpose,
+		ContentType:   upload.ContentType,
+		Size:          upload.Size,
+		Status:        upload.Status,
+		ProcessStatus: upload.ProcessStatus,
+		Width:         upload.Width,
+		Height:        upload.Height,
+		DPI:           upload.DPI,
+		ThumbKey:      upload.ThumbKey,
+		PreviewKey:    upload.PreviewKey,
+		CreatedAt:     upload.CreatedAt,
+		ConfirmedAt:   upload.ConfirmedAt,
+	}
+}
+
 type OSSRepo interface {
+	CreateUpload(context.Context, data.Upload) error
+	GetUploadByKey(context.Context, string) (data.Upload, error)
+	ConfirmUpload(context.Context, string, int64) error
+	StartProcessing(context.Context, string) (bool, error)
+	SaveImageMeta(context.Context, string, data.ImageMeta) error
+	SetProcessStatus(context.Context, string, data.ProcessStatus) error
+	DeleteUploads(context.Context, []string) error
+	GetAssetBySHA256(context.Context, string) (data.Asset, error)
+	LinkAsset(context.Context, string, data.Asset) error
+	CreateAsset(context.Context, data.Upload, string) (data.Asset, error)
+	GetUploadsByKeys(context.Context, []string) ([]data.Upload, error)
+	FilterOwnedKeys(context.Context, string, []string) ([]string, error)
+	FilterPortfolioKeys(context.Context, string, []string) ([]string, error)
+	FilterTemplateAssetKeys(context.Context, []string) ([]string, error)
 }
 
 type OSSUsecase struct {
+	repo       OSSRepo
+	storage    Storage
+	processing chan struct{}
 }
 
-func NewOSSUsecase() *OSSUsecase {
-	return &OSSUsecase{}
+func NewOSSUsecase(repo OSSRepo, storage Storage) *OSSUsecase {
+	return &OSSUsecase{repo: repo, storage: storage, processing: make(chan struct{}, maxConcurrentProcessing)}
 }
 
 func (uc *OSSUsecase) GetCredentials(c *gin.Context) {
 	credentials, err := oss.GenerateAssumeRoleCredential(context.TODO())
 	if err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
 	SuccessResponse(c, credentials)
@@ -28,27 +106,194 @@ func (uc *OSSUsecase) GetCredentials(c *gin.Context) {
 
 func (uc *OSSUsecase) GetPreviewSignedUrl(c *gin.Context) {
 	ossKey := c.Query("ossKey")
-	previewUrl, err := oss.PresignPreviewUrl(ossKey)
+	variant := c.DefaultQuery("variant", VariantOriginal)
+	if !validVariant(variant) {
+		ErrorResponse(c, consts.InvalidParams, "unknown variant "+variant)
+		return
+	}
+	urls, denied, err := uc.presignPreviews(c, c.GetString("openid"), []string{ossKey}, variant)
 	if err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
-	SuccessResponse(c, gin.H{
-		"previewUrl": previewUrl,
+	if len(denied) > 0 {
+		ErrorResponse(c, consts.ObjectAccessDenied, ossKey)
+		return
+	}
+	SuccessResponse(c, PreviewSignedUrlResponse{
+		PreviewUrl: urls[0].Url,
+		ExpiresAt:  urls[0].ExpiresAt,
 	})
 }
 
 func (uc *OSSUsecase) GetUploadSignedUrl(c *gin.Context) {
-	filename := c.Query("filename")
-	contentType := c.DefaultQuery("contentType", "application/octet-stream")
-	objectkey := oss.GenerateUniqueKey(filename)
-	uploadUrl, err := oss.PresignUploadUrl(objectkey, contentType)
+	purpose := c.DefaultQuery("purpose", PurposeWork)
+	contentType := c.Query("contentType")
+	size, err := strconv.ParseInt(c.Query("size"), 10, 64)
+	if err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	ext, ok := checkUploadPolicy(c, purpose, contentType, size)
+	if !ok {
+		return
+	}
+	objectkey := oss.GenerateUniqueKey(purpose, MD5(c.GetString("openid")), ext)
+	uploadUrl, headers, err := uc.storage.PresignUploadUrl(objectkey, contentType, size)
 	if err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
-	SuccessResponse(c, gin.H{
-		"uploadUrl": uploadUrl,
-		"ossKey":    objectkey,
+	if err := uc.repo.CreateUpload(c, data.Upload{
+		OSSKey:      objectkey,
+		Openid:      c.GetString("openid"),
+		Purpose:     purpose,
+		ContentType: contentType,
+		Size:        size,
+		Status:      data.UploadPending,
+	}); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, UploadSignedUrlResponse{
+		UploadUrl: uploadUrl,
+		OSSKey:    objectkey,
+		Headers:   headers,
 	})
 }
+
+// checkUploadPolicy 校验上传用途、文件类型与大小，返回对象扩展名
+func checkUploadPolicy(c *gin.Context, purpose, contentType string, size int64) (string, bool) {
+	policy, ok := UploadPolicies[purpose]
+	if !ok {
+		ErrorResponse(c, consts.InvalidParams, "unknown purpose "+purpose)
+		return "", false
+	}
+	ext, ok := policy.ContentTypes[contentType]
+	if !ok {
+		ErrorResponse(c, consts.UnsupportedContentType, contentType)
+		return "", false
+	}
+	if size <= 0 || size > policy.MaxSize {
+		ErrorResponse(c, consts.FileTooLarge, size)
+		return "", false
+	}
+	return ext, true
+}
+
+// ownedUpload 查询当前用户的上传记录，不存在或不属于当前用户时直接写入错误响应
+func (uc *OSSUsecase) ownedUpload(c *gin.Context, ossKey string) (data.Upload, bool) {
+	upload, err := uc.repo.GetUploadByKey(c, ossKey)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && upload.Openid != c.GetString("openid")) {
+		ErrorResponse(c, consts.ObjectAccessDenied, ossKey)
+		return data.Upload{}, false
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return data.Upload{}, false
+	}
+	return upload, true
+}
+
+// ConfirmUpload 客户端上传完成后调用，通过 HEAD 确认对象已存在且符合上传策略
+func (uc *OSSUsecase) ConfirmUpload(c *gin.Context) {
+	req := ConfirmUploadRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	upload, ok := uc.ownedUpload(c, req.OSSKey)
+	if !ok {
+		return
+	}
+	uc.confirm(c, upload)
+}
+
+// confirm 确认对象已存在且符合上传策略，随后开始图片处理
+func (uc *OSSUsecase) confirm(c *gin.Context, upload data.Upload) {
+	if upload.Status == data.UploadConfirmed {
+		// 处理失败时允许客户端再次确认以重试
+		if upload.ProcessStatus == data.ProcessFailed {
+			uc.startProcessing(c, &upload)
+		}
+		SuccessResponse(c, toUploadResponse(upload))
+		return
+	}
+	meta, err := uc.storage.HeadObject(c, upload.OSSKey)
+	if errors.Is(err, oss.ErrObjectNotFound) {
+		ErrorResponse(c, consts.ObjectNotUploaded, upload.OSSKey)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if meta.Size > UploadPolicies[upload.Purpose].MaxSize {
+		ErrorResponse(c, consts.FileTooLarge, meta.Size)
+		return
+	}
+	// 对象必须与申请上传时声明的大小和类型一致，分片上传合并后大小不一致说明分片被替换或缺失。
+	// 不一致时删除对象与上传记录，分片上传已合并无法继续，客户端需要重新申请上传
+	if err := matchDeclared(upload, meta); err != nil {
+		if derr := uc.discardUpload(c, upload.OSSKey); derr != nil {
+			ErrorResponse(c, consts.ServerError, derr)
+			return
+		}
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	if err := uc.repo.ConfirmUpload(c, upload.OSSKey, meta.Size); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	now := time.Now()
+	upload.Status = data.UploadConfirmed
+	upload.Size = meta.Size
+	upload.ConfirmedAt = &now
+	uc.startProcessing(c, &upload)
+	SuccessResponse(c, toUploadResponse(upload))
+}
+
+// matchDeclared 检查对象的实际大小与类型是否与上传记录一致
+func matchDeclared(upload data.Upload, meta oss.ObjectMeta) error {
+	if meta.Size != upload.Size {
+		return fmt.Errorf("object size %d does not match declared size %d", meta.Size, upload.Size)
+	}
+	actual, _, err := mime.ParseMediaType(meta.ContentType)
+	if err != nil || !strings.EqualFold(actual, upload.ContentType) {
+		return fmt.Errorf("object content type %q does not match declared type %q", meta.ContentType, upload.ContentType)
+	}
+	return nil
+}
+
+// discardUpload 删除与声明不符的对象及其上传记录
+func (uc *OSSUsecase) discardUpload(ctx context.Context, ossKey string) error {
+	if err := uc.storage.DeleteObjects(ctx, []string{ossKey}); err != nil {
+		return err
+	}
+	return uc.repo.DeleteUploads(ctx, []string{ossKey})
+}
+
+// GetUpload 查询上传记录，客户端据此轮询图片处理结果与真实尺寸
+func (uc *OSSUsecase) GetUpload(c *gin.Context) {
+	upload, ok := uc.ownedUpload(c, c.Query("ossKey"))
+	if !ok {
+		return
+	}
+	SuccessResponse(c, toUploadResponse(upload))
+}
+
+func (uc *OSSUsecase) startProcessing(ctx context.Context, upload *data.Upload) {
+	if !UploadPolicies[upload.Purpose].Process {
+		return
+	}
+	started, err := uc.repo.StartProcessing(ctx, upload.OSSKey)
+	if err != nil {
+		zap.L().Error("StartProcessing error", zap.Error(err))
+		return
+	}
+	if started {
+		upload.ProcessStatus = data.ProcessRunning
+		uc.processAsync(*upload)
+	}
+}
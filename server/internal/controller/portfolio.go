@@ -2,18 +2,34 @@ package controller
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"sort"
 
+	"github.com/Fl0rencess720/Springboard/consts"
 	"github.com/Fl0rencess720/Springboard/internal/data"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 type SavePortfolioRequest struct {
 	UID         string         `json:"uid"`
 	Title       string         `json:"title"`
-	TemplateUID string         `json:"template_uid"`
-	Projects    []data.Project `json:"projects"`
+	TemplateUID string         `json:"template_uid" binding:"required"`
+	Projects    []ProjectInput `json:"projects" binding:"dive"`
+	// Layout 不为空时忽略作品的位置与页码，由服务端按项目顺序自动排版
+	Layout *LayoutOptions `json:"layout"`
+	// Revision 为客户端基于的版本号，与服务端不一致时返回冲突；同时设置 If-Match 头时以 If-Match 为准
+	Revision *int64 `json:"revision" doc:"为空时不检查版本，直接覆盖"`
+}
+
+type SavePortfolioResponse struct {
+	UID      string            `json:"uid"`
+	Revision int64             `json:"revision"`
+	Projects []ProjectResponse `json:"projects"`
+	Template TemplateResponse  `json:"template"`
 }
 
 // type GetAllTemplatesResponse struct {
@@ -36,28 +52,47 @@ type PortfolioRepo interface {
 	GetPortfoliosFromRedis(context.Context, string) ([]data.Portfolio, error)
 	GetPortfolioByUIDFromDB(context.Context, string) (data.Portfolio, error)
 	SavePortfoliosToRedis(context.Context, []data.Portfolio, string) error
-	SavePortfolioToDB(context.Context, data.Portfolio) error
+	SavePortfolioToDB(context.Context, *data.Portfolio, map[int]string, *int64) (int64, error)
+	PatchPortfolioToDB(context.Context, string, data.PortfolioDiff, *int64) (int64, error)
+	DeletePortfoliosFromRedis(context.Context, string) error
+	PublishCollabEvent(context.Context, string, []byte) error
+	GetAssetsByIDs(context.Context, []uint) ([]data.Asset, error)
+	GetImageSizes(context.Context, string, []string) ([]data.Upload, error)
+	FilterUsableWorkKeys(context.Context, string, []string) ([]string, error)
+	IsActiveReviewer(context.Context, string, string) (bool, error)
+
+	InsertPortfolioPage(context.Context, data.PortfolioPage, *int64) ([]data.PortfolioPage, int64, error)
+	DuplicatePortfolioPage(context.Context, string, string, *int64) ([]data.PortfolioPage, int64, error)
+	MovePortfolioPage(context.Context, string, string, int, *int64) ([]data.PortfolioPage, int64, error)
+	DeletePortfolioPage(context.Context, string, string, *int64) ([]data.PortfolioPage, int64, error)
+
+	CreateShareLink(context.Context, data.ShareLink) error
+	ListShareLinks(context.Context, string) ([]data.ShareLink, error)
+	GetShareLinkByToken(context.Context, string) (data.ShareLink, error)
+	RevokeShareLink(context.Context, string, string) error
+	RecordShareView(context.Context, string) error
 }
 
 type PortfolioUsecase struct {
-	repo PortfolioRepo
+	repo    PortfolioRepo
+	storage Storage
 }
 
-func NewPortfolioUsecase(repo PortfolioRepo) *PortfolioUsecase {
-	return &PortfolioUsecase{repo: repo}
+func NewPortfolioUsecase(repo PortfolioRepo, storage Storage) *PortfolioUsecase {
+	return &PortfolioUsecase{repo: repo, storage: storage}
 }
 
 func (uc *PortfolioUsecase) GetAllTemplates(c *gin.Context) {
 	templates, err := uc.repo.GetAllTemplatesFromRedis(c)
 	if err == nil {
-		SuccessResponse(c, templates)
+		uc.respondTemplates(c, templates)
 		return
 	}
 	zap.L().Error("GetAllTemplatesFromRedis error", zap.Error(err))
 
 	templates, err = uc.repo.GetAllTemplatesFromDB(c)
 	if err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
 	if err := uc.repo.SaveAllTemplatesToRedis(c, templates); err != nil {
@@ -71,17 +106,17 @@ func (uc *PortfolioUsecase) GetAllTemplates(c *gin.Context) {
 	// 		OSSKey: template.OSSKey,
 	// 	})
 	// }
-	SuccessResponse(c, templates)
+	uc.respondTemplates(c, templates)
 }
 
 func (uc *PortfolioUsecase) GetTemplateByUID(c *gin.Context) {
 	uid := c.Query("uid")
 	template, err := uc.repo.GetTemplateByUIDFromDB(c, uid)
 	if err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
-	SuccessResponse(c, template)
+	uc.respondTemplate(c, template)
 }
 
 func (uc *PortfolioUsecase) GetHotTemplates(c *gin.Context) {
@@ -93,59 +128,152 @@ func (uc *PortfolioUsecase) GetHotTemplates(c *gin.Context) {
 		}
 		templatesWithMeta, err := uc.repo.GetTemplatesFromDB(context.Background(), uids)
 		if err != nil {
-			ErrorResponse(c, ServerError, err)
+			ErrorResponse(c, consts.ServerError, err)
 			return
 		}
-		SuccessResponse(c, templatesWithMeta)
+		uc.respondTemplates(c, templatesWithMeta)
 		return
 	}
 	zap.L().Error("GetHotTemplatesFromRedis error", zap.Error(err))
 	templates, err = uc.repo.GetHotTemplatesFromDB(c)
 	if err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
-	SuccessResponse(c, templates)
+	uc.respondTemplates(c, templates)
 }
 
 func (uc *PortfolioUsecase) SavePortfolio(c *gin.Context) {
 	req := SavePortfolioRequest{}
 	if err := c.ShouldBindJSON(&req); err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	ifRevision, err := ifMatchRevision(c)
+	if err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
 		return
 	}
+	if ifRevision == nil {
+		ifRevision = req.Revision
+	}
 	flag := false
 	if req.UID == "" {
 		req.UID = uuid.New().String()
 		flag = true
 	}
-	for i := 0; i < len(req.Projects); i++ {
-		if req.Projects[i].UID == "" {
-			req.Projects[i].UID = uuid.New().String()
-			req.Projects[i].PortfolioUID = req.UID
+	templates, err := uc.repo.GetTemplateByUIDFromDB(c, req.TemplateUID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.InvalidParams, "template not found: "+req.TemplateUID)
+		return
+	}
+	if err != nil {
+		zap.L().Error("GetTemplateByUIDFromDB error", zap.Error(err))
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if err := validateFonts(templates, req.Projects); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	// pageTemplates 为自动排版选定的模板内容页，key 为页码，与作品集一同保存
+	var pageTemplates map[int]string
+	if req.Layout != nil {
+		projects := make([]*ProjectInput, 0, len(req.Projects))
+		for i := range req.Projects {
+			projects = append(projects, &req.Projects[i])
+		}
+		sort.SliceStable(projects, func(i, j int) bool { return projects[i].Order < projects[j].Order })
+		works := []*WorkInput{}
+		for _, project := range projects {
+			for i := range project.Works {
+				works = append(works, &project.Works[i])
+			}
+		}
+		var pages []data.PortfolioPage
+		if !flag {
+			existing, err := uc.repo.GetPortfolioByUIDFromDB(c, req.UID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				ErrorResponse(c, consts.ServerError, err)
+				return
+			}
+			if existing.Openid == c.GetString("openid") {
+				pages = existing.Pages
+			}
+		}
+		pageUIDs, _, err := uc.layoutWorks(c, c.GetString("openid"), templates, pages, works, *req.Layout)
+		if errors.Is(err, errNoContentPage) {
+			ErrorResponse(c, consts.InvalidParams, err)
+			return
+		}
+		if err != nil {
+			zap.L().Error("layoutWorks error", zap.Error(err))
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+		pageTemplates = make(map[int]string, len(works))
+		for i, work := range works {
+			pageTemplates[work.PageNum] = pageUIDs[i]
+		}
+	}
+	if err := validateSlots(templates, req.Projects); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	ossKeys := []string{}
+	for _, project := range req.Projects {
+		for _, work := range project.Works {
+			ossKeys = append(ossKeys, work.OSSKey)
 		}
 	}
-	if err := uc.repo.SavePortfolioToDB(c, data.Portfolio{UID: req.UID, Title: req.Title,
-		TemplateUID: req.TemplateUID,
-		Projects:    req.Projects, Openid: c.GetString("openid")}); err != nil {
-		ErrorResponse(c, ServerError, err)
+	if err := uc.checkWorkKeys(c, c.GetString("openid"), ossKeys); err != nil {
+		respondWorkKeyError(c, err)
+		return
+	}
+	portfolio := req.toModel(c.GetString("openid"))
+	// 项目、作品与文本的 uid 由保存时确定，不属于该作品集的 uid 会重新生成
+	portfolio.Revision, err = uc.repo.SavePortfolioToDB(c, &portfolio, pageTemplates, ifRevision)
+	if errors.Is(err, data.ErrPortfolioNotOwned) {
+		ErrorResponse(c, consts.PortfolioAccessDenied, req.UID)
 		return
 	}
+	if errors.Is(err, data.ErrRevisionConflict) {
+		uc.respondConflict(c, req.UID)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if err := uc.repo.DeletePortfoliosFromRedis(c, c.GetString("openid")); err != nil {
+		zap.L().Error("DeletePortfoliosFromRedis error", zap.Error(err))
+	}
+	if !flag {
+		uc.broadcastResync(c, req.UID, portfolio.Revision)
+	}
 	if flag {
 		if err := uc.repo.IncreTemplateScore(c, req.TemplateUID); err != nil {
 			zap.L().Error("IncreTemplateScore error", zap.Error(err))
 		}
 	}
-	templates, err := uc.repo.GetTemplateByUIDFromDB(c, req.TemplateUID)
-	if err != nil {
-		zap.L().Error("GetTemplateByUIDFromDB error", zap.Error(err))
-		ErrorResponse(c, ServerError, err)
-		return
+	portfolio.Template = templates
+	saved := []PortfolioResponse{toPortfolioResponse(portfolio)}
+	if resolveURLs(c) {
+		if err := uc.resolvePortfolios(c, saved, true); err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
 	}
-	SuccessResponse(c, gin.H{
-		"uid":      req.UID,
-		"projects": req.Projects,
-		"template": templates,
+	setRevisionETag(c, portfolio.Revision)
+	SuccessResponse(c, SavePortfolioResponse{
+		UID:      req.UID,
+		Revision: portfolio.Revision,
+		Projects: saved[0].Projects,
+		Template: saved[0].Template,
 	})
 }
 
@@ -153,29 +281,49 @@ func (uc *PortfolioUsecase) GetMyPortfolios(c *gin.Context) {
 	openid := c.GetString("openid")
 	portfolios, err := uc.repo.GetPortfoliosFromRedis(c, openid)
 	if err == nil {
-		SuccessResponse(c, portfolios)
+		uc.respondPortfolios(c, portfolios)
 		return
 	}
 	zap.L().Error("GetPortfolioFromRedis error", zap.Error(err))
 	portfolios, err = uc.repo.GetPortfoliosFromDB(c, openid)
 	if err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
 	if err := uc.repo.SavePortfoliosToRedis(c, portfolios, openid); err != nil {
 		zap.L().Error("SavePortfoliosToRedis error", zap.Error(err))
 	}
-	SuccessResponse(c, portfolios)
+	uc.respondPortfolios(c, portfolios)
 }
 
+// GetPortfolioByUID 只有所有者与未被撤销的评审人可以读取，他人只能通过有效的分享链接查看
 func (uc *PortfolioUsecase) GetPortfolioByUID(c *gin.Context) {
 	uid := c.Query("uid")
 	portfolio, err := uc.repo.GetPortfolioByUIDFromDB(c, uid)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.PortfolioNotFound, uid)
+		return
+	}
 	if err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if openid := c.GetString("openid"); portfolio.Openid != openid {
+		ok, err := uc.repo.IsActiveReviewer(c, uid, openid)
+		if err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+		if !ok {
+			ErrorResponse(c, consts.PortfolioAccessDenied, uid)
+			return
+		}
+	}
+	setRevisionETag(c, portfolio.Revision)
+	if notModified(c, portfolio.Revision) {
 		return
 	}
-	SuccessResponse(c, portfolio)
+	uc.respondPortfolio(c, portfolio, true)
 }
 
 func (uc *PortfolioUsecase) GetHistoricalUsageTemplates(c *gin.Context) {
@@ -190,13 +338,13 @@ func (uc *PortfolioUsecase) GetHistoricalUsageTemplates(c *gin.Context) {
 				seen[p.TemplateUID] = struct{}{}
 			}
 		}
-		SuccessResponse(c, templates)
+		uc.respondTemplates(c, templates)
 		return
 	}
 	zap.L().Error("GetPortfolioFromRedis error", zap.Error(err))
 	portfolios, err = uc.repo.GetPortfoliosFromDB(c, openid)
 	if err != nil {
-		ErrorResponse(c, ServerError, err)
+		ErrorResponse(c, consts.ServerError, err)
 		return
 	}
 	if err := uc.repo.SavePortfoliosToRedis(c, portfolios, openid); err != nil {
@@ -209,5 +357,39 @@ func (uc *PortfolioUsecase) GetHistoricalUsageTemplates(c *gin.Context) {
 			seen[p.TemplateUID] = struct{}{}
 		}
 	}
-	SuccessResponse(c, templates)
+	uc.respondTemplates(c, templates)
+}
+
+// errWorkKeyDenied 为作品引用了当前用户无权使用的对象
+var errWorkKeyDenied = errors.New("oss key not accessible")
+
+// checkWorkKeys 作品只能引用自己上传的对象或模板公共资源。
+// 作品集引用的对象对所有者可见，放入他人的对象即可绕过预览签名的权限检查
+func (uc *PortfolioUsecase) checkWorkKeys(ctx context.Context, openid string, ossKeys []string) error {
+	ossKeys = dedupe(ossKeys)
+	if len(ossKeys) == 0 {
+		return nil
+	}
+	keys, err := uc.repo.FilterUsableWorkKeys(ctx, openid, ossKeys)
+	if err != nil {
+		return err
+	}
+	allowed := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		allowed[key] = struct{}{}
+	}
+	for _, key := range ossKeys {
+		if _, ok := allowed[key]; !ok {
+			return fmt.Errorf("%w: %s", errWorkKeyDenied, key)
+		}
+	}
+	return nil
+}
+
+func respondWorkKeyError(c *gin.Context, err error) {
+	if errors.Is(err, errWorkKeyDenied) {
+		ErrorResponse(c, consts.ObjectAccessDenied, err)
+		return
+	}
+	ErrorResponse(c, consts.ServerError, err)
 }
@@ -0,0 +1,392 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/Fl0rencess720/Springboard/pkgs/layout"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type DuplicatePortfolioRequest struct {
+	UID   string `json:"uid" binding:"required"`
+	Title string `json:"title" doc:"为空时沿用原标题"`
+}
+
+type RetemplateRequest struct {
+	UID         string `json:"uid" binding:"required"`
+	TemplateUID string `json:"template_uid" binding:"required"`
+	Copy        bool   `json:"copy" doc:"为 true 时在副本上更换模板，原作品集不变"`
+	Title       string `json:"title" doc:"副本标题，为空时沿用原标题"`
+}
+
+// UnplacedItem 为更换模板时无法放入新模板空位或被调整的内容
+type UnplacedItem struct {
+	Kind    string `json:"kind" doc:"work、text 或 page"`
+	UID     string `json:"uid"`
+	PageNum int    `json:"page_num"`
+	Reason  string `json:"reason"`
+}
+
+type RetemplateResponse struct {
+	Portfolio PortfolioResponse `json:"portfolio"`
+	// Unplaced 中的作品与文本保留原位置但不再属于任何空位
+	Unplaced []UnplacedItem `json:"unplaced"`
+}
+
+// clonePortfolio 深拷贝作品集，项目、作品、文本与页面使用新的 UID，作品仍引用原有资源
+func clonePortfolio(portfolio data.Portfolio, title string) data.Portfolio {
+	clone := data.Portfolio{
+		UID:         uuid.New().String(),
+		Openid:      portfolio.Openid,
+		Title:       portfolio.Title,
+		TemplateUID: portfolio.TemplateUID,
+	}
+	if title != "" {
+		clone.Title = title
+	}
+	projectUIDs := map[string]string{}
+	for _, project := range portfolio.Projects {
+		projectUIDs[project.UID] = uuid.New().String()
+	}
+	for _, project := range portfolio.Projects {
+		p := data.Project{
+			UID:          projectUIDs[project.UID],
+			Name:         project.Name,
+			Order:        project.Order,
+			PortfolioUID: clone.UID,
+		}
+		for _, work := range project.Works {
+			work.ID, work.UID, work.ProjectUID = 0, uuid.New().String(), p.UID
+			work.CreatedAt, work.UpdatedAt = time.Time{}, time.Time{}
+			p.Works = append(p.Works, work)
+		}
+		for _, text := range project.Texts {
+			text.ID, text.UID, text.ProjectUID = 0, uuid.New().String(), p.UID
+			p.Texts = append(p.Texts, text)
+		}
+		clone.Projects = append(clone.Projects, p)
+	}
+	for _, page := range portfolio.Pages {
+		page.ID, page.UID, page.PortfolioUID = 0, uuid.New().String(), clone.UID
+		page.ProjectUID = projectUIDs[page.ProjectUID]
+		page.CreatedAt, page.UpdatedAt = time.Time{}, time.Time{}
+		clone.Pages = append(clone.Pages, page)
+	}
+	return clone
+}
+
+// saveAndRespond 保存作品集后重新读取，保证返回的是数据库中的最新状态
+// 在原作品集上修改时，读取之后其他设备的保存会导致冲突
+func (uc *PortfolioUsecase) saveAndRespond(c *gin.Context, portfolio data.Portfolio, unplaced []UnplacedItem) {
+	portfolio.Template = data.Template{}
+	_, err := uc.repo.SavePortfolioToDB(c, &portfolio, nil, &portfolio.Revision)
+	if errors.Is(err, data.ErrPortfolioNotOwned) {
+		ErrorResponse(c, consts.PortfolioAccessDenied, portfolio.UID)
+		return
+	}
+	if errors.Is(err, data.ErrRevisionConflict) {
+		uc.respondConflict(c, portfolio.UID)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if err := uc.repo.DeletePortfoliosFromRedis(c, c.GetString("openid")); err != nil {
+		zap.L().Error("DeletePortfoliosFromRedis error", zap.Error(err))
+	}
+	saved, err := uc.repo.GetPortfolioByUIDFromDB(c, portfolio.UID)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	// 复制出的作品集没有协同连接，广播只对更换模板有意义
+	uc.broadcastResync(c, saved.UID, saved.Revision)
+	setRevisionETag(c, saved.Revision)
+	if unplaced == nil {
+		uc.respondPortfolio(c, saved, true)
+		return
+	}
+	resp := []PortfolioResponse{toPortfolioResponse(saved)}
+	if resolveURLs(c) {
+		if err := uc.resolvePortfolios(c, resp, true); err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+	}
+	SuccessResponse(c, RetemplateResponse{Portfolio: resp[0], Unplaced: unplaced})
+}
+
+// DuplicatePortfolio 复制作品集，用于针对不同学校制作多个版本
+func (uc *PortfolioUsecase) DuplicatePortfolio(c *gin.Context) {
+	req := DuplicatePortfolioRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	portfolio, ok := uc.ownedPortfolio(c, req.UID)
+	if !ok {
+		return
+	}
+	uc.saveAndRespond(c, clonePortfolio(portfolio, req.Title), nil)
+}
+
+// Retemplate 将作品集的内容按页映射到另一个模板的空位上，返回无法放置的内容
+func (uc *PortfolioUsecase) Retemplate(c *gin.Context) {
+	req := RetemplateRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	portfolio, ok := uc.ownedPortfolio(c, req.UID)
+	if !ok {
+		return
+	}
+	template, err := uc.repo.GetTemplateByUIDFromDB(c, req.TemplateUID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.InvalidParams, "template not found: "+req.TemplateUID)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	// 旧模板仅用于确定原有空位的顺序与类型，已被删除时按无空位处理
+	previous, err := uc.repo.GetTemplateByUIDFromDB(c, portfolio.TemplateUID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if req.Copy {
+		portfolio = clonePortfolio(portfolio, req.Title)
+	}
+	unplaced, err := uc.retemplate(c, c.GetString("openid"), &portfolio, previous, template)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if portfolio.TemplateUID != template.UID {
+		portfolio.TemplateUID = template.UID
+		if err := uc.repo.IncreTemplateScore(c, template.UID); err != nil {
+			zap.L().Error("IncreTemplateScore error", zap.Error(err))
+		}
+	}
+	uc.saveAndRespond(c, portfolio, unplaced)
+}
+
+// templateSlots 为模板页面及其各类型空位，空位按顺序排列
+type templateSlots struct {
+	page  data.Page
+	slots map[data.SlotType][]data.PageSlot
+}
+
+func newTemplateSlots(page data.Page) templateSlots {
+	t := templateSlots{page: page, slots: map[data.SlotType][]data.PageSlot{}}
+	for _, slot := range page.Slots {
+		t.slots[slot.Type] = append(t.slots[slot.Type], slot)
+	}
+	return t
+}
+
+// take 取出下一个指定类型的空位
+func (t *templateSlots) take(typ data.SlotType) (data.PageSlot, bool) {
+	if len(t.slots[typ]) == 0 {
+		return data.PageSlot{}, false
+	}
+	slot := t.slots[typ][0]
+	t.slots[typ] = t.slots[typ][1:]
+	return slot, true
+}
+
+// retemplate 将作品集映射到新模板：内容页依次使用新模板的内容页，封面与分隔页依次使用其余页面，
+// 页面中占用空位的作品与文本按原空位顺序放入新页面同类型的空位，自由摆放的内容保持不变
+func (uc *PortfolioUsecase) retemplate(ctx context.Context, openid string, portfolio *data.Portfolio,
+	previous, template data.Template) ([]UnplacedItem, error) {
+	unplaced := []UnplacedItem{}
+
+	contentPages, otherPages := []data.Page{}, []data.Page{}
+	for _, page := range template.Pages {
+		if page.IsContentPage {
+			contentPages = append(contentPages, page)
+		} else {
+			otherPages = append(otherPages, page)
+		}
+	}
+	// 尚未登记页面的旧作品集按内容页补全，与保存时补全的规则一致
+	last := len(portfolio.Pages) - 1
+	for _, project := range portfolio.Projects {
+		for _, work := range project.Works {
+			last = max(last, work.PageNum)
+		}
+		for _, text := range project.Texts {
+			last = max(last, text.PageNum)
+		}
+	}
+	for number := len(portfolio.Pages); number <= last; number++ {
+		portfolio.Pages = append(portfolio.Pages, data.PortfolioPage{
+			UID:          uuid.New().String(),
+			PortfolioUID: portfolio.UID,
+			Number:       number,
+			Kind:         data.PageContent,
+		})
+	}
+	targets := map[int]*templateSlots{}
+	contentIndex, otherIndex := 0, 0
+	for i := range portfolio.Pages {
+		page := &portfolio.Pages[i]
+		candidates, index := contentPages, &contentIndex
+		if page.Kind != data.PageContent {
+			candidates, index = otherPages, &otherIndex
+		}
+		if len(candidates) == 0 {
+			page.TemplatePageUID = ""
+			unplaced = append(unplaced, UnplacedItem{Kind: "page", UID: page.UID, PageNum: page.Number,
+				Reason: "template has no matching " + string(page.Kind) + " page"})
+			continue
+		}
+		target := newTemplateSlots(candidates[*index%len(candidates)])
+		*index++
+		page.TemplatePageUID = target.page.UID
+		targets[page.Number] = &target
+	}
+
+	previousSlots := map[string]data.PageSlot{}
+	for _, page := range previous.Pages {
+		for _, slot := range page.Slots {
+			previousSlots[slot.UID] = slot
+		}
+	}
+	families := map[string]struct{}{}
+	for _, font := range template.Fonts {
+		families[font.Family] = struct{}{}
+	}
+
+	works, texts := []*data.Work{}, []*data.Text{}
+	keys := []string{}
+	for i := range portfolio.Projects {
+		project := &portfolio.Projects[i]
+		for j := range project.Works {
+			if project.Works[j].SlotUID != "" {
+				works = append(works, &project.Works[j])
+				keys = append(keys, project.Works[j].OSSKey)
+			}
+		}
+		for j := range project.Texts {
+			texts = append(texts, &project.Texts[j])
+		}
+	}
+	uploads, err := uc.repo.GetImageSizes(ctx, openid, dedupe(keys))
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]data.Upload, len(uploads))
+	for _, upload := range uploads {
+		sizes[upload.OSSKey] = upload
+	}
+	// 同一页内按原空位顺序分配，保证两栏、网格等布局中的相对位置不变
+	slotOrder := func(uid string) int {
+		if slot, ok := previousSlots[uid]; ok {
+			return slot.Order
+		}
+		return 0
+	}
+	sort.SliceStable(works, func(i, j int) bool {
+		if works[i].PageNum != works[j].PageNum {
+			return works[i].PageNum < works[j].PageNum
+		}
+		return slotOrder(works[i].SlotUID) < slotOrder(works[j].SlotUID)
+	})
+	sort.SliceStable(texts, func(i, j int) bool {
+		if texts[i].PageNum != texts[j].PageNum {
+			return texts[i].PageNum < texts[j].PageNum
+		}
+		return slotOrder(texts[i].SlotUID) < slotOrder(texts[j].SlotUID)
+	})
+
+	for _, work := range works {
+		target, ok := targets[work.PageNum]
+		var slot data.PageSlot
+		if ok {
+			slot, ok = target.take(data.SlotImage)
+		}
+		if !ok {
+			work.SlotUID = ""
+			unplaced = append(unplaced, UnplacedItem{Kind: "work", UID: work.UID, PageNum: work.PageNum,
+				Reason: "no free image slot on the page"})
+			continue
+		}
+		work.SlotUID = slot.UID
+		rect, err := parseSlot(slot)
+		if err != nil {
+			return nil, err
+		}
+		size, ok := sizes[work.OSSKey]
+		if !ok || size.Width <= 0 || size.Height <= 0 {
+			// 尺寸未知时先放满空位，图片处理完成后可重新排版
+			size.Width, size.Height = int(math.Max(rect.Width, 1)), int(math.Max(rect.Height, 1))
+		}
+		placement, err := layout.Place(rect, size.Width, size.Height, layout.Policy(slot.Policy))
+		if err != nil {
+			return nil, err
+		}
+		work.Size, work.MarginLeft, work.MarginTop = boxGeometry(placement.Box)
+		work.Scale = 1
+		work.Transform.Crop = nil
+		if placement.Crop != nil {
+			crop := data.CropRect(*placement.Crop)
+			work.Transform.Crop = &crop
+		}
+	}
+
+	for _, text := range texts {
+		if text.FontFamily != "" {
+			if _, ok := families[text.FontFamily]; !ok {
+				unplaced = append(unplaced, UnplacedItem{Kind: "text", UID: text.UID, PageNum: text.PageNum,
+					Reason: "font family " + text.FontFamily + " is not provided by the template, reset to default"})
+				text.FontFamily = ""
+			}
+		}
+		for i := range text.Runs {
+			if _, ok := families[text.Runs[i].FontFamily]; text.Runs[i].FontFamily != "" && !ok {
+				text.Runs[i].FontFamily = ""
+			}
+		}
+		if text.SlotUID == "" {
+			continue
+		}
+		typ := previousSlots[text.SlotUID].Type
+		if typ != data.SlotCaption {
+			typ = data.SlotText
+		}
+		target, ok := targets[text.PageNum]
+		var slot data.PageSlot
+		if ok {
+			slot, ok = target.take(typ)
+		}
+		if !ok {
+			text.SlotUID = ""
+			unplaced = append(unplaced, UnplacedItem{Kind: "text", UID: text.UID, PageNum: text.PageNum,
+				Reason: "no free " + string(typ) + " slot on the page"})
+			continue
+		}
+		if slot.MaxLength > 0 && utf8.RuneCountInString(text.Content) > slot.MaxLength {
+			text.SlotUID = ""
+			unplaced = append(unplaced, UnplacedItem{Kind: "text", UID: text.UID, PageNum: text.PageNum,
+				Reason: "text is longer than the slot allows"})
+			continue
+		}
+		text.SlotUID = slot.UID
+		text.Size, text.MarginLeft, text.MarginTop = slot.Size, slot.MarginLeft, slot.MarginTop
+	}
+	return unplaced, nil
+}
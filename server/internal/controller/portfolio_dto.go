@@ -0,0 +1,542 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Fl0rencess720/Springboard/internal/data"
+)
+
+// 请求类型只包含客户端可以设置的字段，openid、project 的 portfolio_uid 等由服务端填写
+
+type ProjectInput struct {
+	UID   string      `json:"uid" doc:"为空时创建新项目"`
+	Name  string      `json:"name"`
+	Order int         `json:"order"`
+	Works []WorkInput `json:"works" binding:"dive"`
+	Texts []TextInput `json:"texts" binding:"dive"`
+}
+
+type WorkInput struct {
+	UID    string `json:"uid" doc:"为空时创建新作品"`
+	OSSKey string `json:"oss_key" binding:"required"`
+	// Size 格式为 axb 例如 1920x1080
+	Size       string         `json:"size"`
+	MarginTop  string         `json:"margin_top"`
+	MarginLeft string         `json:"margin_left"`
+	Scale      float64        `json:"scale" doc:"1.0 表示不缩放"`
+	PageNum    int            `json:"page_num"`
+	SlotUID    string         `json:"slot_uid" doc:"模板中的图片空位，为空表示自由摆放"`
+	Transform  TransformInput `json:"transform"`
+}
+
+// TransformInput 按裁剪、翻转、旋转的顺序应用，ZIndex 决定同一页内作品与文本的层叠顺序
+type TransformInput struct {
+	Crop     *CropRect `json:"crop" doc:"为空表示不裁剪，文本不支持裁剪"`
+	Rotation float64   `json:"rotation" binding:"min=-360,max=360" doc:"顺时针角度"`
+	FlipX    bool      `json:"flip_x"`
+	FlipY    bool      `json:"flip_y"`
+	ZIndex   int       `json:"z_index" binding:"min=-1000,max=1000"`
+	Opacity  *float64  `json:"opacity" binding:"omitempty,min=0,max=1" doc:"为空时为 1"`
+}
+
+// CropRect 的坐标与宽高均为相对原图宽高的比例
+type CropRect struct {
+	X      float64 `json:"x" binding:"min=0,max=1"`
+	Y      float64 `json:"y" binding:"min=0,max=1"`
+	Width  float64 `json:"width" binding:"required,gt=0,max=1"`
+	Height float64 `json:"height" binding:"required,gt=0,max=1"`
+}
+
+type TransformResponse struct {
+	Crop     *CropRect `json:"crop"`
+	Rotation float64   `json:"rotation"`
+	FlipX    bool      `json:"flip_x"`
+	FlipY    bool      `json:"flip_y"`
+	ZIndex   int       `json:"z_index"`
+	Opacity  float64   `json:"opacity"`
+}
+
+type TextInput struct {
+	UID     string `json:"uid" doc:"为空时创建新文本框"`
+	Content string `json:"content" binding:"max=20000" doc:"存在 runs 时以 runs 为准"`
+	// Runs 为富文本片段，按顺序拼接成完整文本
+	Runs       []TextRun `json:"runs" binding:"max=500,dive"`
+	FontSize   string    `json:"font_size"`
+	FontColor  string    `json:"font_color" binding:"omitempty,len=6,hexadecimal" doc:"RRGGBB，不带 #"`
+	FontFamily string    `json:"font_family" doc:"必须是模板提供的字体，为空时使用默认字体"`
+	FontWeight int       `json:"font_weight" binding:"omitempty,oneof=100 200 300 400 500 600 700 800 900"`
+	Align      string    `json:"align" binding:"omitempty,oneof=left center right justify"`
+	LineHeight float64   `json:"line_height" binding:"omitempty,min=0.5,max=5" doc:"字号的倍数，默认 1.2"`
+	// LetterSpacing 单位为像素
+	LetterSpacing float64        `json:"letter_spacing" binding:"min=-50,max=200"`
+	Size          string         `json:"size"`
+	MarginTop     string         `json:"margin_top"`
+	MarginLeft    string         `json:"margin_left"`
+	PageNum       int            `json:"page_num"`
+	SlotUID       string         `json:"slot_uid" doc:"模板中的文本或说明空位，为空表示自由摆放"`
+	Transform     TransformInput `json:"transform"`
+}
+
+// TextRun 为富文本中样式相同的一段文字，未设置的样式继承文本框
+type TextRun struct {
+	Text          string `json:"text" binding:"required"`
+	FontFamily    string `json:"font_family,omitempty"`
+	FontSize      string `json:"font_size,omitempty"`
+	FontColor     string `json:"font_color,omitempty" binding:"omitempty,len=6,hexadecimal"`
+	FontWeight    int    `json:"font_weight,omitempty" binding:"omitempty,oneof=100 200 300 400 500 600 700 800 900"`
+	Italic        bool   `json:"italic,omitempty"`
+	Underline     bool   `json:"underline,omitempty"`
+	Strikethrough bool   `json:"strikethrough,omitempty"`
+}
+
+type TemplateResponse struct {
+	UID        string                 `json:"uid"`
+	Name       string                 `json:"name"`
+	FontOSSKey string                 `json:"font_oss_key"`
+	FontURL    string                 `json:"font_url,omitempty"`
+	Pages      []PageResponse         `json:"pages"`
+	Fonts      []TemplateFontResponse `json:"fonts"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+type TemplateFontResponse struct {
+	UID    string `json:"uid"`
+	Family string `json:"family"`
+	OSSKey string `json:"oss_key"`
+	URL    string `json:"url,omitempty"`
+}
+
+type PageResponse struct {
+	UID           string `json:"uid"`
+	OSSKey        string `json:"oss_key"`
+	PreviewOSSKey string `json:"preview_oss_key"`
+	// Bleed 为出血线，4 个值分别代表 svg 的 x、y、width、height
+	Bleed         []string `json:"bleed"`
+	MarginTop     string   `json:"margin_top"`
+	MarginLeft    string   `json:"margin_left"`
+	Size          string   `json:"size"`
+	BkgSize       string   `json:"bkg_size"`
+	IsContentPage bool     `json:"is_content_page"`
+	// Slots 为页面中按顺序排列的空位，取代 MarginTop、MarginLeft、Size
+	Slots      []SlotResponse `json:"slots"`
+	URL        string         `json:"url,omitempty"`
+	PreviewURL string         `json:"preview_url,omitempty"`
+}
+
+type SlotResponse struct {
+	UID        string        `json:"uid"`
+	Order      int           `json:"order"`
+	Type       data.SlotType `json:"type" doc:"image、text 或 caption"`
+	MarginTop  string        `json:"margin_top"`
+	MarginLeft string        `json:"margin_left"`
+	Size       string        `json:"size"`
+	Policy     string        `json:"policy,omitempty" doc:"图片空位的默认排版方式"`
+	MaxLength  int           `json:"max_length,omitempty" doc:"文本与说明空位的最大字数"`
+}
+
+type PortfolioResponse struct {
+	UID         string            `json:"uid"`
+	Title       string            `json:"title"`
+	TemplateUID string            `json:"template_uid"`
+	Template    TemplateResponse  `json:"template"`
+	Projects    []ProjectResponse `json:"projects"`
+	// Pages 按页码排序，作品与文本的 page_num 对应页面的 page_num
+	Pages []PortfolioPageResponse `json:"pages"`
+	// Revision 为版本号，保存时传回以检测其他设备的修改
+	Revision  int64     `json:"revision"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	// openid 为作品集所有者，签发作品 URL 时只签发其可以使用的对象
+	openid string
+}
+
+type ProjectResponse struct {
+	UID       string         `json:"uid"`
+	Name      string         `json:"name"`
+	Order     int            `json:"order"`
+	Works     []WorkResponse `json:"works"`
+	Texts     []TextResponse `json:"texts"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+}
+
+type WorkResponse struct {
+	UID        string            `json:"uid"`
+	OSSKey     string            `json:"oss_key"`
+	Size       string            `json:"size"`
+	MarginTop  string            `json:"margin_top"`
+	MarginLeft string            `json:"margin_left"`
+	Scale      float64           `json:"scale"`
+	PageNum    int               `json:"page_num"`
+	SlotUID    string            `json:"slot_uid"`
+	Transform  TransformResponse `json:"transform"`
+	// URL、PreviewURL 仅在请求 resolve=urls 时返回
+	URL        string    `json:"url,omitempty"`
+	PreviewURL string    `json:"preview_url,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+
+	assetID *uint
+}
+
+type TextResponse struct {
+	UID           string            `json:"uid"`
+	Content       string            `json:"content"`
+	Runs          []TextRun         `json:"runs"`
+	FontSize      string            `json:"font_size"`
+	FontColor     string            `json:"font_color"`
+	FontFamily    string            `json:"font_family"`
+	FontWeight    int               `json:"font_weight"`
+	Align         string            `json:"align"`
+	LineHeight    float64           `json:"line_height"`
+	LetterSpacing float64           `json:"letter_spacing"`
+	Size          string            `json:"size"`
+	MarginTop     string            `json:"margin_top"`
+	MarginLeft    string            `json:"margin_left"`
+	PageNum       int               `json:"page_num"`
+	SlotUID       string            `json:"slot_uid"`
+	Transform     TransformResponse `json:"transform"`
+}
+
+func (req SavePortfolioRequest) toModel(openid string) data.Portfolio {
+	projects := make([]data.Project, 0, len(req.Projects))
+	for _, project := range req.Projects {
+		projects = append(projects, project.toModel(req.UID))
+	}
+	return data.Portfolio{
+		UID:         req.UID,
+		Openid:      openid,
+		Title:       req.Title,
+		TemplateUID: req.TemplateUID,
+		Projects:    projects,
+	}
+}
+
+func (in ProjectInput) toModel(portfolioUID string) data.Project {
+	works := make([]data.Work, 0, len(in.Works))
+	for _, work := range in.Works {
+		works = append(works, data.Work{
+			UID:        work.UID,
+			OSSKey:     work.OSSKey,
+			ProjectUID: in.UID,
+			Size:       work.Size,
+			MarginTop:  work.MarginTop,
+			MarginLeft: work.MarginLeft,
+			Scale:      work.Scale,
+			PageNum:    work.PageNum,
+			SlotUID:    work.SlotUID,
+			Transform:  work.Transform.toModel(),
+		})
+	}
+	texts := make([]data.Text, 0, len(in.Texts))
+	for _, text := range in.Texts {
+		texts = append(texts, text.toModel(in.UID))
+	}
+	return data.Project{
+		UID:          in.UID,
+		Name:         in.Name,
+		Order:        in.Order,
+		PortfolioUID: portfolioUID,
+		Works:        works,
+		Texts:        texts,
+	}
+}
+
+// toModel 补全未设置的样式，存在富文本片段时由片段生成纯文本
+func (in TextInput) toModel(projectUID string) data.Text {
+	text := data.Text{
+		UID:           in.UID,
+		ProjectUID:    projectUID,
+		Content:       in.Content,
+		FontSize:      in.FontSize,
+		FontColor:     in.FontColor,
+		FontFamily:    in.FontFamily,
+		FontWeight:    in.FontWeight,
+		Align:         in.Align,
+		LineHeight:    in.LineHeight,
+		LetterSpacing: in.LetterSpacing,
+		Transform:     in.Transform.toModel(),
+		Size:          in.Size,
+		MarginTop:     in.MarginTop,
+		MarginLeft:    in.MarginLeft,
+		PageNum:       in.PageNum,
+		SlotUID:       in.SlotUID,
+	}
+	if text.FontColor == "" {
+		text.FontColor = "000000"
+	}
+	if text.FontWeight == 0 {
+		text.FontWeight = 400
+	}
+	if text.Align == "" {
+		text.Align = "left"
+	}
+	if text.LineHeight == 0 {
+		text.LineHeight = 1.2
+	}
+	if len(in.Runs) > 0 {
+		content := strings.Builder{}
+		text.Runs = make([]data.TextRun, 0, len(in.Runs))
+		for _, run := range in.Runs {
+			content.WriteString(run.Text)
+			text.Runs = append(text.Runs, data.TextRun(run))
+		}
+		text.Content = content.String()
+	}
+	return text
+}
+
+func (in TransformInput) toModel() data.Transform {
+	transform := data.Transform{
+		Rotation: in.Rotation,
+		FlipX:    in.FlipX,
+		FlipY:    in.FlipY,
+		ZIndex:   in.ZIndex,
+		Opacity:  1,
+	}
+	if in.Crop != nil {
+		crop := data.CropRect(*in.Crop)
+		transform.Crop = &crop
+	}
+	if in.Opacity != nil {
+		transform.Opacity = *in.Opacity
+	}
+	return transform
+}
+
+// validate 检查裁剪框不超出原图，allowCrop 为 false 时不允许裁剪
+func (in TransformInput) validate(allowCrop bool) error {
+	if in.Crop == nil {
+		return nil
+	}
+	if !allowCrop {
+		return errors.New("crop is not supported")
+	}
+	if !in.Crop.within() {
+		return errors.New("crop rect must lie within the image")
+	}
+	return nil
+}
+
+// within 判断矩形是否非空且完全落在 [0, 1] 范围内
+func (r CropRect) within() bool {
+	return r.Width > 0 && r.Height > 0 && r.X >= 0 && r.Y >= 0 && r.X+r.Width <= 1 && r.Y+r.Height <= 1
+}
+
+func toTransformResponse(transform data.Transform) TransformResponse {
+	resp := TransformResponse{
+		Rotation: transform.Rotation,
+		FlipX:    transform.FlipX,
+		FlipY:    transform.FlipY,
+		ZIndex:   transform.ZIndex,
+		Opacity:  transform.Opacity,
+	}
+	if transform.Crop != nil {
+		crop := CropRect(*transform.Crop)
+		resp.Crop = &crop
+	}
+	return resp
+}
+
+// validate 检查请求中无法用 binding 表达的规则
+func (req SavePortfolioRequest) validate() error {
+	for i, project := range req.Projects {
+		for j, work := range project.Works {
+			if err := work.Transform.validate(true); err != nil {
+				return fmt.Errorf("projects[%d].works[%d].transform: %w", i, j, err)
+			}
+		}
+		for j, text := range project.Texts {
+			if err := text.Transform.validate(false); err != nil {
+				return fmt.Errorf("projects[%d].texts[%d].transform: %w", i, j, err)
+			}
+		}
+	}
+	return nil
+}
+
+// fontFamilies 返回文本及其富文本片段使用的全部字体
+func (in TextInput) fontFamilies() []string {
+	families := []string{}
+	if in.FontFamily != "" {
+		families = append(families, in.FontFamily)
+	}
+	for _, run := range in.Runs {
+		if run.FontFamily != "" {
+			families = append(families, run.FontFamily)
+		}
+	}
+	return families
+}
+
+// validateFonts 检查文本只使用模板提供的字体
+func validateFonts(template data.Template, projects []ProjectInput) error {
+	families := map[string]struct{}{}
+	for _, font := range template.Fonts {
+		families[font.Family] = struct{}{}
+	}
+	for _, project := range projects {
+		for _, text := range project.Texts {
+			for _, family := range text.fontFamilies() {
+				if _, ok := families[family]; !ok {
+					return errors.New("font family not provided by template: " + family)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func toTemplateResponses(templates []data.Template) []TemplateResponse {
+	resp := make([]TemplateResponse, 0, len(templates))
+	for _, template := range templates {
+		resp = append(resp, toTemplateResponse(template))
+	}
+	return resp
+}
+
+func toTemplateResponse(template data.Template) TemplateResponse {
+	pages := make([]PageResponse, 0, len(template.Pages))
+	for _, page := range template.Pages {
+		slots := make([]SlotResponse, 0, len(page.Slots))
+		for _, slot := range page.Slots {
+			slots = append(slots, SlotResponse{
+				UID:        slot.UID,
+				Order:      slot.Order,
+				Type:       slot.Type,
+				MarginTop:  slot.MarginTop,
+				MarginLeft: slot.MarginLeft,
+				Size:       slot.Size,
+				Policy:     slot.Policy,
+				MaxLength:  slot.MaxLength,
+			})
+		}
+		pages = append(pages, PageResponse{
+			UID:           page.UID,
+			OSSKey:        page.OSSKey,
+			PreviewOSSKey: page.PreviewOSSKey,
+			Bleed:         page.Bleed,
+			MarginTop:     page.MarginTop,
+			MarginLeft:    page.MarginLeft,
+			Size:          page.Size,
+			BkgSize:       page.BkgSize,
+			IsContentPage: page.IsContentPage,
+			Slots:         slots,
+		})
+	}
+	fonts := make([]TemplateFontResponse, 0, len(template.Fonts))
+	for _, font := range template.Fonts {
+		fonts = append(fonts, TemplateFontResponse{
+			UID:    font.UID,
+			Family: font.Family,
+			OSSKey: font.OSSKey,
+		})
+	}
+	return TemplateResponse{
+		UID:        template.UID,
+		Name:       template.Name,
+		FontOSSKey: template.FontOSSKey,
+		Pages:      pages,
+		Fonts:      fonts,
+		CreatedAt:  template.CreatedAt,
+	}
+}
+
+func toPortfolioResponses(portfolios []data.Portfolio) []PortfolioResponse {
+	resp := make([]PortfolioResponse, 0, len(portfolios))
+	for _, portfolio := range portfolios {
+		resp = append(resp, toPortfolioResponse(portfolio))
+	}
+	return resp
+}
+
+func toPortfolioResponse(portfolio data.Portfolio) PortfolioResponse {
+	return PortfolioResponse{
+		UID:         portfolio.UID,
+		Title:       portfolio.Title,
+		TemplateUID: portfolio.TemplateUID,
+		Template:    toTemplateResponse(portfolio.Template),
+		Projects:    toProjectResponses(portfolio.Projects),
+		Pages:       toPortfolioPageResponses(portfolio.Pages),
+		Revision:    portfolio.Revision,
+		CreatedAt:   portfolio.CreatedAt,
+		UpdatedAt:   portfolio.UpdatedAt,
+		openid:      portfolio.Openid,
+	}
+}
+
+func toPortfolioPageResponses(pages []data.PortfolioPage) []PortfolioPageResponse {
+	resp := make([]PortfolioPageResponse, 0, len(pages))
+	for _, page := range pages {
+		resp = append(resp, PortfolioPageResponse{
+			UID:             page.UID,
+			PageNum:         page.Number,
+			Kind:            page.Kind,
+			TemplatePageUID: page.TemplatePageUID,
+			ProjectUID:      page.ProjectUID,
+		})
+	}
+	return resp
+}
+
+func toProjectResponses(projects []data.Project) []ProjectResponse {
+	resp := make([]ProjectResponse, 0, len(projects))
+	for _, project := range projects {
+		works := make([]WorkResponse, 0, len(project.Works))
+		for _, work := range project.Works {
+			works = append(works, WorkResponse{
+				UID:        work.UID,
+				OSSKey:     work.OSSKey,
+				Size:       work.Size,
+				MarginTop:  work.MarginTop,
+				MarginLeft: work.MarginLeft,
+				Scale:      work.Scale,
+				PageNum:    work.PageNum,
+				SlotUID:    work.SlotUID,
+				Transform:  toTransformResponse(work.Transform),
+				CreatedAt:  work.CreatedAt,
+				UpdatedAt:  work.UpdatedAt,
+				assetID:    work.AssetID,
+			})
+		}
+		texts := make([]TextResponse, 0, len(project.Texts))
+		for _, text := range project.Texts {
+			runs := make([]TextRun, 0, len(text.Runs))
+			for _, run := range text.Runs {
+				runs = append(runs, TextRun(run))
+			}
+			texts = append(texts, TextResponse{
+				UID:           text.UID,
+				Content:       text.Content,
+				Runs:          runs,
+				FontSize:      text.FontSize,
+				FontColor:     text.FontColor,
+				FontFamily:    text.FontFamily,
+				FontWeight:    text.FontWeight,
+				Align:         text.Align,
+				LineHeight:    text.LineHeight,
+				LetterSpacing: text.LetterSpacing,
+				SlotUID:       text.SlotUID,
+				Transform:     toTransformResponse(text.Transform),
+				Size:          text.Size,
+				MarginTop:     text.MarginTop,
+				MarginLeft:    text.MarginLeft,
+				PageNum:       text.PageNum,
+			})
+		}
+		resp = append(resp, ProjectResponse{
+			UID:       project.UID,
+			Name:      project.Name,
+			Order:     project.Order,
+			Works:     works,
+			Texts:     texts,
+			CreatedAt: project.CreatedAt,
+			UpdatedAt: project.UpdatedAt,
+		})
+	}
+	return resp
+}
@@ -0,0 +1,178 @@
+package controller
+
+import (
+	"errors"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+type PortfolioPageResponse struct {
+	UID             string        `json:"uid"`
+	PageNum         int           `json:"page_num"`
+	Kind            data.PageKind `json:"kind" doc:"cover、divider 或 content"`
+	TemplatePageUID string        `json:"template_page_uid"`
+	ProjectUID      string        `json:"project_uid"`
+}
+
+type InsertPageRequest struct {
+	PortfolioUID    string        `json:"portfolio_uid" binding:"required"`
+	PageNum         int           `json:"page_num" binding:"min=0" doc:"插入位置，超出末尾时追加"`
+	Kind            data.PageKind `json:"kind" binding:"omitempty,oneof=cover divider content" doc:"默认 content"`
+	TemplatePageUID string        `json:"template_page_uid" doc:"必须是作品集所用模板的页面"`
+	ProjectUID      string        `json:"project_uid" doc:"必须是作品集中的项目"`
+}
+
+type PageRequest struct {
+	PortfolioUID string `json:"portfolio_uid" binding:"required"`
+	PageUID      string `json:"page_uid" binding:"required"`
+}
+
+type MovePageRequest struct {
+	PortfolioUID string `json:"portfolio_uid" binding:"required"`
+	PageUID      string `json:"page_uid" binding:"required"`
+	PageNum      int    `json:"page_num" binding:"min=0" doc:"目标位置，超出末尾时移到最后"`
+}
+
+// ownedPortfolio 返回当前用户的作品集，不存在或不属于当前用户时写入错误响应
+func (uc *PortfolioUsecase) ownedPortfolio(c *gin.Context, uid string) (data.Portfolio, bool) {
+	portfolio, err := uc.repo.GetPortfolioByUIDFromDB(c, uid)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && portfolio.Openid != c.GetString("openid")) {
+		ErrorResponse(c, consts.PortfolioAccessDenied, uid)
+		return data.Portfolio{}, false
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return data.Portfolio{}, false
+	}
+	return portfolio, true
+}
+
+// editablePortfolio 返回当前用户的作品集与 If-Match 头中客户端所基于的版本，失败时写入错误响应
+func (uc *PortfolioUsecase) editablePortfolio(c *gin.Context, uid string) (data.Portfolio, *int64, bool) {
+	ifRevision, err := ifMatchRevision(c)
+	if err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return data.Portfolio{}, nil, false
+	}
+	portfolio, ok := uc.ownedPortfolio(c, uid)
+	return portfolio, ifRevision, ok
+}
+
+// respondPages 页面变更后清除缓存并返回重新编号后的全部页面，作品集的新版本号通过 ETag 返回
+func (uc *PortfolioUsecase) respondPages(c *gin.Context, portfolioUID string, pages []data.PortfolioPage, revision int64, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.InvalidParams, "page not found")
+		return
+	}
+	if errors.Is(err, data.ErrRevisionConflict) {
+		uc.respondConflict(c, portfolioUID)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if err := uc.repo.DeletePortfoliosFromRedis(c, c.GetString("openid")); err != nil {
+		zap.L().Error("DeletePortfoliosFromRedis error", zap.Error(err))
+	}
+	uc.broadcastResync(c, portfolioUID, revision)
+	setRevisionETag(c, revision)
+	SuccessResponse(c, toPortfolioPageResponses(pages))
+}
+
+func (uc *PortfolioUsecase) InsertPage(c *gin.Context) {
+	req := InsertPageRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	portfolio, ifRevision, ok := uc.editablePortfolio(c, req.PortfolioUID)
+	if !ok {
+		return
+	}
+	if req.ProjectUID != "" {
+		found := false
+		for _, project := range portfolio.Projects {
+			found = found || project.UID == req.ProjectUID
+		}
+		if !found {
+			ErrorResponse(c, consts.InvalidParams, "project not in portfolio: "+req.ProjectUID)
+			return
+		}
+	}
+	if req.TemplatePageUID != "" {
+		template, err := uc.repo.GetTemplateByUIDFromDB(c, portfolio.TemplateUID)
+		if err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+		found := false
+		for _, page := range template.Pages {
+			found = found || page.UID == req.TemplatePageUID
+		}
+		if !found {
+			ErrorResponse(c, consts.InvalidParams, "page not in template: "+req.TemplatePageUID)
+			return
+		}
+	}
+	if req.Kind == "" {
+		req.Kind = data.PageContent
+	}
+	pages, revision, err := uc.repo.InsertPortfolioPage(c, data.PortfolioPage{
+		UID:             uuid.New().String(),
+		PortfolioUID:    portfolio.UID,
+		Number:          req.PageNum,
+		Kind:            req.Kind,
+		TemplatePageUID: req.TemplatePageUID,
+		ProjectUID:      req.ProjectUID,
+	}, ifRevision)
+	uc.respondPages(c, portfolio.UID, pages, revision, err)
+}
+
+func (uc *PortfolioUsecase) DuplicatePage(c *gin.Context) {
+	req := PageRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	_, ifRevision, ok := uc.editablePortfolio(c, req.PortfolioUID)
+	if !ok {
+		return
+	}
+	pages, revision, err := uc.repo.DuplicatePortfolioPage(c, req.PortfolioUID, req.PageUID, ifRevision)
+	uc.respondPages(c, req.PortfolioUID, pages, revision, err)
+}
+
+func (uc *PortfolioUsecase) MovePage(c *gin.Context) {
+	req := MovePageRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	_, ifRevision, ok := uc.editablePortfolio(c, req.PortfolioUID)
+	if !ok {
+		return
+	}
+	pages, revision, err := uc.repo.MovePortfolioPage(c, req.PortfolioUID, req.PageUID, req.PageNum, ifRevision)
+	uc.respondPages(c, req.PortfolioUID, pages, revision, err)
+}
+
+// DeletePage 删除页面及页面上的作品与文本
+func (uc *PortfolioUsecase) DeletePage(c *gin.Context) {
+	req := PageRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	_, ifRevision, ok := uc.editablePortfolio(c, req.PortfolioUID)
+	if !ok {
+		return
+	}
+	pages, revision, err := uc.repo.DeletePortfolioPage(c, req.PortfolioUID, req.PageUID, ifRevision)
+	uc.respondPages(c, req.PortfolioUID, pages, revision, err)
+}
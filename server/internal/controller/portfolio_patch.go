@@ -0,0 +1,346 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/Fl0rencess720/Springboard/pkgs/jsonpatch"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// PatchPortfolioRequest 为增量保存，ops 与 patch 只能设置其中一个
+type PatchPortfolioRequest struct {
+	// Revision 为客户端基于的版本号，同时设置 If-Match 头时以 If-Match 为准
+	Revision *int64                `json:"revision" doc:"为空时不检查版本"`
+	Ops      []OpInput             `json:"ops" binding:"max=100,dive" doc:"与协同编辑相同的修改，执行后广播给协同连接"`
+	Patch    []jsonpatch.Operation `json:"patch" binding:"max=500,dive" doc:"RFC 6902 JSON Patch，作用于 PortfolioDocument"`
+}
+
+type PatchPortfolioResponse struct {
+	Revision int64 `json:"revision"`
+	// Ops 为执行的修改，add_work 的 work_uid 由服务端生成
+	Ops []OpInput `json:"ops,omitempty"`
+}
+
+// PortfolioDocument 为 JSON Patch 作用的文档，路径形如 /projects/0/works/1/page_num。
+// 新增或复制出的项目、作品与文本由服务端生成 uid
+type PortfolioDocument struct {
+	Title    string         `json:"title"`
+	Projects []ProjectInput `json:"projects" binding:"dive"`
+}
+
+// PatchPortfolio 增量保存作品集，只写入变化的行，避免自动保存时上传整个作品集
+func (uc *CollabUsecase) PatchPortfolio(c *gin.Context) {
+	req := PatchPortfolioRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	if (len(req.Ops) == 0) == (len(req.Patch) == 0) {
+		ErrorResponse(c, consts.InvalidParams, "exactly one of ops and patch is required")
+		return
+	}
+	ifRevision, err := ifMatchRevision(c)
+	if err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	if ifRevision == nil {
+		ifRevision = req.Revision
+	}
+	portfolio, ok := uc.portfolios.ownedPortfolio(c, c.Query("uid"))
+	if !ok {
+		return
+	}
+	var revision int64
+	if len(req.Ops) > 0 {
+		author := Collaborator{UserID: userID(portfolio.Openid), Role: roleOwner, JoinedAt: time.Now()}
+		revision, err = uc.applyOps(c, portfolio, author, "", req.Ops, ifRevision)
+	} else {
+		revision, err = uc.portfolios.patchDocument(c, portfolio, req.Patch, ifRevision)
+	}
+	if errors.Is(err, data.ErrRevisionConflict) {
+		uc.portfolios.respondConflict(c, portfolio.UID)
+		return
+	}
+	if errors.Is(err, errInvalidOps) {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	if errors.Is(err, errWorkKeyDenied) {
+		ErrorResponse(c, consts.ObjectAccessDenied, err)
+		return
+	}
+	if err != nil {
+		zap.L().Error("PatchPortfolio error", zap.Error(err))
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	setRevisionETag(c, revision)
+	SuccessResponse(c, PatchPortfolioResponse{Revision: revision, Ops: req.Ops})
+}
+
+// maxPatchAttempts 为未指定版本时 JSON Patch 因并发修改冲突的最多尝试次数
+const maxPatchAttempts = 3
+
+// patchDocument 将 JSON Patch 应用到作品集文档，成功后通知协同连接重新同步。
+// 补丁作用于读取到的文档，因此总是以读取时的版本号写入；客户端未指定版本时，
+// 期间其他修改提交导致的冲突会重新读取作品集后再次应用，多次冲突后返回 data.ErrRevisionConflict
+func (uc *PortfolioUsecase) patchDocument(ctx context.Context, portfolio data.Portfolio, patch []jsonpatch.Operation, ifRevision *int64) (int64, error) {
+	for attempt := 1; ; attempt++ {
+		expected := ifRevision
+		if expected == nil {
+			expected = &portfolio.Revision
+		}
+		revision, err := uc.applyPatch(ctx, portfolio, patch, expected)
+		if err == nil {
+			uc.broadcastResync(ctx, portfolio.UID, revision)
+			return revision, nil
+		}
+		if !errors.Is(err, data.ErrRevisionConflict) || ifRevision != nil || attempt == maxPatchAttempts {
+			return 0, err
+		}
+		if portfolio, err = uc.repo.GetPortfolioByUIDFromDB(ctx, portfolio.UID); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// applyPatch 按整体保存的规则校验补丁后的文档，只写入变化的项目、作品与文本
+func (uc *PortfolioUsecase) applyPatch(ctx context.Context, portfolio data.Portfolio, patch []jsonpatch.Operation, ifRevision *int64) (int64, error) {
+	raw, err := json.Marshal(toPortfolioDocument(portfolio))
+	if err != nil {
+		return 0, err
+	}
+	// before 与 after 经过相同的编解码，比较时不会因 nil 与空切片等表示差异误判为修改
+	before := PortfolioDocument{}
+	if err := json.Unmarshal(raw, &before); err != nil {
+		return 0, err
+	}
+	var doc any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return 0, err
+	}
+	if doc, err = jsonpatch.Apply(doc, patch); err != nil {
+		return 0, fmt.Errorf("%w: %v", errInvalidOps, err)
+	}
+	if raw, err = json.Marshal(doc); err != nil {
+		return 0, err
+	}
+	after := PortfolioDocument{}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&after); err != nil {
+		return 0, fmt.Errorf("%w: %v", errInvalidOps, err)
+	}
+	if err := binding.Validator.ValidateStruct(after); err != nil {
+		return 0, fmt.Errorf("%w: %v", errInvalidOps, err)
+	}
+	assignDocumentUIDs(before, &after)
+
+	template, err := uc.repo.GetTemplateByUIDFromDB(ctx, portfolio.TemplateUID)
+	if err != nil {
+		return 0, err
+	}
+	req := SavePortfolioRequest{UID: portfolio.UID, TemplateUID: portfolio.TemplateUID, Title: after.Title, Projects: after.Projects}
+	for _, validate := range []func() error{
+		req.validate,
+		func() error { return validateFonts(template, after.Projects) },
+		func() error { return validateSlots(template, after.Projects) },
+	} {
+		if err := validate(); err != nil {
+			return 0, fmt.Errorf("%w: %v", errInvalidOps, err)
+		}
+	}
+
+	diff := diffDocument(portfolio.UID, before, after)
+	ossKeys := make([]string, 0, len(diff.Works))
+	for _, work := range diff.Works {
+		ossKeys = append(ossKeys, work.OSSKey)
+	}
+	if err := uc.checkWorkKeys(ctx, portfolio.Openid, ossKeys); err != nil {
+		return 0, err
+	}
+	revision, err := uc.repo.PatchPortfolioToDB(ctx, portfolio.UID, diff, ifRevision)
+	if err != nil {
+		return 0, err
+	}
+	if err := uc.repo.DeletePortfoliosFromRedis(ctx, portfolio.Openid); err != nil {
+		zap.L().Error("DeletePortfoliosFromRedis error", zap.Error(err))
+	}
+	return revision, nil
+}
+
+func toPortfolioDocument(portfolio data.Portfolio) PortfolioDocument {
+	doc := PortfolioDocument{Title: portfolio.Title, Projects: make([]ProjectInput, 0, len(portfolio.Projects))}
+	for _, project := range portfolio.Projects {
+		in := ProjectInput{
+			UID:   project.UID,
+			Name:  project.Name,
+			Order: project.Order,
+			Works: make([]WorkInput, 0, len(project.Works)),
+			Texts: make([]TextInput, 0, len(project.Texts)),
+		}
+		for _, work := range project.Works {
+			in.Works = append(in.Works, WorkInput{
+				UID:        work.UID,
+				OSSKey:     work.OSSKey,
+				Size:       work.Size,
+				MarginTop:  work.MarginTop,
+				MarginLeft: work.MarginLeft,
+				Scale:      work.Scale,
+				PageNum:    work.PageNum,
+				SlotUID:    work.SlotUID,
+				Transform:  toTransformInput(work.Transform),
+			})
+		}
+		for _, text := range project.Texts {
+			runs := make([]TextRun, 0, len(text.Runs))
+			for _, run := range text.Runs {
+				runs = append(runs, TextRun(run))
+			}
+			in.Texts = append(in.Texts, TextInput{
+				UID:           text.UID,
+				Content:       text.Content,
+				Runs:          runs,
+				FontSize:      text.FontSize,
+				FontColor:     text.FontColor,
+				FontFamily:    text.FontFamily,
+				FontWeight:    text.FontWeight,
+				Align:         text.Align,
+				LineHeight:    text.LineHeight,
+				LetterSpacing: text.LetterSpacing,
+				Size:          text.Size,
+				MarginTop:     text.MarginTop,
+				MarginLeft:    text.MarginLeft,
+				PageNum:       text.PageNum,
+				SlotUID:       text.SlotUID,
+				Transform:     toTransformInput(text.Transform),
+			})
+		}
+		doc.Projects = append(doc.Projects, in)
+	}
+	return doc
+}
+
+func toTransformInput(transform data.Transform) TransformInput {
+	opacity := transform.Opacity
+	in := TransformInput{
+		Rotation: transform.Rotation,
+		FlipX:    transform.FlipX,
+		FlipY:    transform.FlipY,
+		ZIndex:   transform.ZIndex,
+		Opacity:  &opacity,
+	}
+	if transform.Crop != nil {
+		crop := CropRect(*transform.Crop)
+		in.Crop = &crop
+	}
+	return in
+}
+
+// assignDocumentUIDs 为新增的项目、作品与文本生成 uid。
+// 不在原文档中或在文档中重复出现的 uid 都视为新增，客户端不能借此覆盖其他作品集的数据
+func assignDocumentUIDs(before PortfolioDocument, after *PortfolioDocument) {
+	known := map[string]struct{}{}
+	for _, project := range before.Projects {
+		known[project.UID] = struct{}{}
+		for _, work := range project.Works {
+			known[work.UID] = struct{}{}
+		}
+		for _, text := range project.Texts {
+			known[text.UID] = struct{}{}
+		}
+	}
+	seen := map[string]struct{}{}
+	assign := func(uid *string) {
+		_, ok := known[*uid]
+		if _, dup := seen[*uid]; !ok || dup {
+			*uid = uuid.New().String()
+		}
+		seen[*uid] = struct{}{}
+	}
+	for i := range after.Projects {
+		project := &after.Projects[i]
+		assign(&project.UID)
+		for j := range project.Works {
+			assign(&project.Works[j].UID)
+		}
+		for j := range project.Texts {
+			assign(&project.Texts[j].UID)
+		}
+	}
+}
+
+// diffDocument 比较修改前后的文档，返回需要写入与删除的行
+func diffDocument(portfolioUID string, before, after PortfolioDocument) data.PortfolioDiff {
+	type placedWork struct {
+		projectUID string
+		work       WorkInput
+	}
+	type placedText struct {
+		projectUID string
+		text       TextInput
+	}
+	projects := map[string]ProjectInput{}
+	works := map[string]placedWork{}
+	texts := map[string]placedText{}
+	for _, project := range before.Projects {
+		projects[project.UID] = project
+		for _, work := range project.Works {
+			works[work.UID] = placedWork{project.UID, work}
+		}
+		for _, text := range project.Texts {
+			texts[text.UID] = placedText{project.UID, text}
+		}
+	}
+
+	diff := data.PortfolioDiff{}
+	if after.Title != before.Title {
+		diff.Title = &after.Title
+	}
+	for _, project := range after.Projects {
+		model := project.toModel(portfolioUID)
+		if old, ok := projects[project.UID]; !ok || old.Name != project.Name || old.Order != project.Order {
+			diff.Projects = append(diff.Projects, data.Project{
+				UID:          model.UID,
+				Name:         model.Name,
+				Order:        model.Order,
+				PortfolioUID: portfolioUID,
+			})
+		}
+		delete(projects, project.UID)
+		for i, work := range project.Works {
+			if old, ok := works[work.UID]; !ok || old.projectUID != project.UID || !reflect.DeepEqual(old.work, work) {
+				diff.Works = append(diff.Works, model.Works[i])
+			}
+			delete(works, work.UID)
+		}
+		for i, text := range project.Texts {
+			if old, ok := texts[text.UID]; !ok || old.projectUID != project.UID || !reflect.DeepEqual(old.text, text) {
+				diff.Texts = append(diff.Texts, model.Texts[i])
+			}
+			delete(texts, text.UID)
+		}
+	}
+	for uid := range projects {
+		diff.DeletedProjects = append(diff.DeletedProjects, uid)
+	}
+	for uid := range works {
+		diff.DeletedWorks = append(diff.DeletedWorks, uid)
+	}
+	for uid := range texts {
+		diff.DeletedTexts = append(diff.DeletedTexts, uid)
+	}
+	return diff
+}
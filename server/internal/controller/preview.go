@@ -0,0 +1,142 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/gin-gonic/gin"
+)
+
+type BatchPreviewRequest struct {
+	OSSKeys []string `json:"oss_keys" binding:"required,min=1,max=200"`
+	Variant string   `json:"variant" doc:"图片变体 original、preview、thumb，默认为 original"`
+}
+
+type PreviewUrl struct {
+	OSSKey    string    `json:"oss_key"`
+	Url       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type BatchPreviewResponse struct {
+	Urls []PreviewUrl `json:"urls"`
+	// Denied 为无权访问的 key，不影响其余 key 的签名
+	Denied []string `json:"denied"`
+}
+
+func validVariant(variant string) bool {
+	return variant == VariantOriginal || variant == VariantPreview || variant == VariantThumb
+}
+
+// BatchGetPreviewSignedUrl 打开作品集时一次性获取全部图片的预览 URL
+func (uc *OSSUsecase) BatchGetPreviewSignedUrl(c *gin.Context) {
+	req := BatchPreviewRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	if req.Variant == "" {
+		req.Variant = VariantOriginal
+	}
+	if !validVariant(req.Variant) {
+		ErrorResponse(c, consts.InvalidParams, "unknown variant "+req.Variant)
+		return
+	}
+	urls, denied, err := uc.presignPreviews(c, c.GetString("openid"), req.OSSKeys, req.Variant)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, BatchPreviewResponse{Urls: urls, Denied: denied})
+}
+
+// presignPreviews 按原图检查权限后签发 URL，缩略图尚未生成时回退到原图
+func (uc *OSSUsecase) presignPreviews(ctx context.Context, openid string, ossKeys []string, variant string) ([]PreviewUrl, []string, error) {
+	ossKeys = dedupe(ossKeys)
+	allowed, err := uc.accessibleKeys(ctx, openid, ossKeys)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	renditions := map[string]data.Upload{}
+	if variant != VariantOriginal {
+		uploads, err := uc.repo.GetUploadsByKeys(ctx, ossKeys)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, upload := range uploads {
+			if upload.ProcessStatus == data.ProcessDone {
+				renditions[upload.OSSKey] = upload
+			}
+		}
+	}
+
+	urls := make([]PreviewUrl, 0, len(ossKeys))
+	denied := []string{}
+	for _, ossKey := range ossKeys {
+		if _, ok := allowed[ossKey]; !ok {
+			denied = append(denied, ossKey)
+			continue
+		}
+		// 重复内容的上传复用资源的缩略图，不一定与原图同目录
+		target := ossKey
+		if upload, ok := renditions[ossKey]; ok {
+			target = upload.ThumbKey
+			if variant == VariantPreview {
+				target = upload.PreviewKey
+			}
+		}
+		presigned, err := uc.storage.PresignPreviewUrl(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		urls = append(urls, PreviewUrl{OSSKey: ossKey, Url: presigned.URL, ExpiresAt: presigned.Expiration})
+	}
+	return urls, denied, nil
+}
+
+// accessibleKeys 允许访问自己上传的对象、自己作品集引用的对象以及模板公共资源，
+// 每一类检查只针对前面未通过的 key
+func (uc *OSSUsecase) accessibleKeys(ctx context.Context, openid string, ossKeys []string) (map[string]struct{}, error) {
+	checks := []func([]string) ([]string, error){
+		func(keys []string) ([]string, error) { return uc.repo.FilterOwnedKeys(ctx, openid, keys) },
+		func(keys []string) ([]string, error) { return uc.repo.FilterPortfolioKeys(ctx, openid, keys) },
+		func(keys []string) ([]string, error) { return uc.repo.FilterTemplateAssetKeys(ctx, keys) },
+	}
+	allowed := map[string]struct{}{}
+	remaining := ossKeys
+	for _, check := range checks {
+		if len(remaining) == 0 {
+			break
+		}
+		keys, err := check(remaining)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			allowed[key] = struct{}{}
+		}
+		next := remaining[:0:0]
+		for _, key := range remaining {
+			if _, ok := allowed[key]; !ok {
+				next = append(next, key)
+			}
+		}
+		remaining = next
+	}
+	return allowed, nil
+}
+
+func dedupe(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	result := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			result = append(result, key)
+		}
+	}
+	return result
+}
@@ -0,0 +1,184 @@
+package controller
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/conf"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/gin-gonic/gin"
+)
+
+// resolveURLs 判断请求是否要求在响应中附带资源 URL（?resolve=urls）
+func resolveURLs(c *gin.Context) bool {
+	return c.Query("resolve") == "urls"
+}
+
+// templateAssetURL 模板资源是公共资源，配置了 CDN 时直接拼接长期有效的 CDN 地址
+func (uc *PortfolioUsecase) templateAssetURL(ossKey string) (string, error) {
+	if ossKey == "" {
+		return "", nil
+	}
+	if cdn := conf.Get().OSS.CDNBaseURL; cdn != "" {
+		return url.JoinPath(cdn, ossKey)
+	}
+	presigned, err := uc.storage.PresignPreviewUrl(ossKey)
+	return presigned.URL, err
+}
+
+func (uc *PortfolioUsecase) resolveTemplates(templates []TemplateResponse) error {
+	for i := range templates {
+		if err := uc.resolveTemplate(&templates[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (uc *PortfolioUsecase) resolveTemplate(template *TemplateResponse) error {
+	var err error
+	if template.FontURL, err = uc.templateAssetURL(template.FontOSSKey); err != nil {
+		return err
+	}
+	for i := range template.Fonts {
+		if template.Fonts[i].URL, err = uc.templateAssetURL(template.Fonts[i].OSSKey); err != nil {
+			return err
+		}
+	}
+	for i := range template.Pages {
+		page := &template.Pages[i]
+		if page.URL, err = uc.templateAssetURL(page.OSSKey); err != nil {
+			return err
+		}
+		if page.PreviewURL, err = uc.templateAssetURL(page.PreviewOSSKey); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolvePortfolios 作品为私有资源，只有 owned 为 true（作品集属于当前用户）时才签发作品 URL，
+// 且只签发作品集所有者可以使用的对象，preview_url 使用资源的预览图
+func (uc *PortfolioUsecase) resolvePortfolios(ctx context.Context, portfolios []PortfolioResponse, owned bool) error {
+	for i := range portfolios {
+		if err := uc.resolveTemplate(&portfolios[i].Template); err != nil {
+			return err
+		}
+	}
+	if !owned {
+		return nil
+	}
+
+	assetIDs := []uint{}
+	ownerKeys := map[string][]string{}
+	for _, portfolio := range portfolios {
+		for _, project := range portfolio.Projects {
+			for _, work := range project.Works {
+				ownerKeys[portfolio.openid] = append(ownerKeys[portfolio.openid], work.OSSKey)
+				if work.assetID != nil {
+					assetIDs = append(assetIDs, *work.assetID)
+				}
+			}
+		}
+	}
+	// 作品的 oss_key 可能来自保存校验之前的旧数据，不能为任意对象签名
+	usable := map[string]map[string]struct{}{}
+	for openid, keys := range ownerKeys {
+		keys, err := uc.repo.FilterUsableWorkKeys(ctx, openid, dedupe(keys))
+		if err != nil {
+			return err
+		}
+		usable[openid] = make(map[string]struct{}, len(keys))
+		for _, key := range keys {
+			usable[openid][key] = struct{}{}
+		}
+	}
+	assets, err := uc.repo.GetAssetsByIDs(ctx, assetIDs)
+	if err != nil {
+		return err
+	}
+	previews := make(map[uint]string, len(assets))
+	for _, asset := range assets {
+		previews[asset.ID] = asset.PreviewKey
+	}
+
+	for i := range portfolios {
+		for j := range portfolios[i].Projects {
+			works := portfolios[i].Projects[j].Works
+			for k := range works {
+				if _, ok := usable[portfolios[i].openid][works[k].OSSKey]; !ok {
+					continue
+				}
+				presigned, err := uc.storage.PresignPreviewUrl(works[k].OSSKey)
+				if err != nil {
+					return err
+				}
+				works[k].URL = presigned.URL
+				if works[k].assetID == nil || previews[*works[k].assetID] == "" {
+					continue
+				}
+				if presigned, err = uc.storage.PresignPreviewUrl(previews[*works[k].assetID]); err != nil {
+					return err
+				}
+				works[k].PreviewURL = presigned.URL
+			}
+		}
+	}
+	return nil
+}
+
+// participantView 将作品集转换为响应并签发作品 URL，用于评审与协同编辑。
+// 评审人受邀查看作品集，与学生本人一样可以查看原图
+func (uc *PortfolioUsecase) participantView(ctx context.Context, portfolio data.Portfolio) (PortfolioResponse, error) {
+	resp := []PortfolioResponse{toPortfolioResponse(portfolio)}
+	if err := uc.resolvePortfolios(ctx, resp, true); err != nil {
+		return PortfolioResponse{}, err
+	}
+	return resp[0], nil
+}
+
+// 以下 respond 系列将模型转换为响应类型，请求 resolve=urls 时附带资源 URL，缓存中的数据不包含 URL
+func (uc *PortfolioUsecase) respondTemplates(c *gin.Context, templates []data.Template) {
+	resp := toTemplateResponses(templates)
+	if resolveURLs(c) {
+		if err := uc.resolveTemplates(resp); err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+	}
+	SuccessResponse(c, resp)
+}
+
+func (uc *PortfolioUsecase) respondTemplate(c *gin.Context, template data.Template) {
+	resp := toTemplateResponse(template)
+	if resolveURLs(c) {
+		if err := uc.resolveTemplate(&resp); err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+	}
+	SuccessResponse(c, resp)
+}
+
+func (uc *PortfolioUsecase) respondPortfolios(c *gin.Context, portfolios []data.Portfolio) {
+	resp := toPortfolioResponses(portfolios)
+	if resolveURLs(c) {
+		if err := uc.resolvePortfolios(c, resp, true); err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+	}
+	SuccessResponse(c, resp)
+}
+
+func (uc *PortfolioUsecase) respondPortfolio(c *gin.Context, portfolio data.Portfolio, owned bool) {
+	resp := []PortfolioResponse{toPortfolioResponse(portfolio)}
+	if resolveURLs(c) {
+		if err := uc.resolvePortfolios(c, resp, owned); err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+	}
+	SuccessResponse(c, resp[0])
+}
@@ -1,37 +1,12 @@
 package controller
 
 import (
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/pkgs/i18n"
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
-const (
-	ServerError = iota
-	AuthError
-	TokenExpired
-	LoginError
-	RefreshTokenError
-	RegisterError
-)
-
-var HttpCode = map[uint]int{
-	ServerError:       502,
-	AuthError:         401,
-	TokenExpired:      401,
-	LoginError:        403,
-	RefreshTokenError: 403,
-	RegisterError:     403,
-}
-
-var Message = map[uint]string{
-	ServerError:       "服务器错误",
-	AuthError:         "无权访问",
-	TokenExpired:      "Token过期",
-	LoginError:        "登录失败",
-	RefreshTokenError: "刷新Token失败",
-	RegisterError:     "注册失败",
-}
-
 func SuccessResponse(c *gin.Context, data any) {
 	c.JSON(200, gin.H{
 		"msg":  "success",
@@ -40,19 +15,32 @@ func SuccessResponse(c *gin.Context, data any) {
 	})
 }
 
+// InvalidParamsResponse 用于请求未通过 OpenAPI 文档校验时
+func InvalidParamsResponse(c *gin.Context, err error) {
+	ErrorResponse(c, consts.InvalidParams, err)
+	c.Abort()
+}
+
 func ErrorResponse(c *gin.Context, code uint, data ...any) {
-	httpStatus, ok := HttpCode[code]
+	errorResponse(c, code, nil, data)
+}
+
+// ErrorDataResponse 在错误响应的 data 中返回客户端处理错误所需的内容，例如冲突时的当前版本
+func ErrorDataResponse(c *gin.Context, code uint, data any) {
+	errorResponse(c, code, data, nil)
+}
+
+func errorResponse(c *gin.Context, code uint, data any, detail []any) {
+	httpStatus, ok := consts.HttpCode[code]
 	if !ok {
 		httpStatus = 403
 	}
-	msg, ok := Message[code]
-	if !ok {
-		msg = "未知错误"
-	}
-	zap.L().Error("error response", zap.Uint("code", code), zap.String("openid", c.GetString("openid")), zap.Any(msg, data))
+	msg := i18n.Localize(c, code)
+	zap.L().Error("error response", zap.Uint("code", code), zap.String("openid", c.GetString("openid")), zap.Any(msg, detail))
 
 	c.JSON(httpStatus, gin.H{
 		"code": code,
 		"msg":  msg,
+		"data": data,
 	})
 }
@@ -0,0 +1,489 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+const (
+	roleOwner    = "owner"
+	roleReviewer = "reviewer"
+)
+
+type InviteReviewRequest struct {
+	PortfolioUID string `json:"portfolio_uid" binding:"required"`
+	Note         string `json:"note" binding:"max=1000" doc:"给评审人的说明"`
+}
+
+type AcceptReviewRequest struct {
+	Token string `json:"token" binding:"required" doc:"学生发来的邀请 token"`
+}
+
+type ReviewRequest struct {
+	ReviewUID string `json:"review_uid" binding:"required"`
+}
+
+type ReviewDecisionRequest struct {
+	ReviewUID string            `json:"review_uid" binding:"required"`
+	Status    data.ReviewStatus `json:"status" binding:"required,oneof=approved changes_requested"`
+	Verdict   string            `json:"verdict" binding:"max=5000"`
+}
+
+// AnchorInput 为评论的锚点，region 需要同时指定 page_uid
+type AnchorInput struct {
+	Type    data.AnchorType `json:"type" binding:"omitempty,oneof=portfolio page work region" doc:"默认 portfolio"`
+	PageUID string          `json:"page_uid"`
+	WorkUID string          `json:"work_uid"`
+	Region  *CropRect       `json:"region" doc:"坐标为相对页面宽高的比例"`
+}
+
+type AddCommentRequest struct {
+	ReviewUID string      `json:"review_uid" binding:"required"`
+	ParentUID string      `json:"parent_uid" doc:"回复某条讨论时填写，回复沿用该讨论的锚点"`
+	Body      string      `json:"body" binding:"required,max=5000"`
+	Anchor    AnchorInput `json:"anchor"`
+}
+
+type ResolveCommentRequest struct {
+	CommentUID string `json:"comment_uid" binding:"required"`
+	Resolved   bool   `json:"resolved" doc:"false 表示重新打开"`
+}
+
+type ReviewResponse struct {
+	UID          string            `json:"uid"`
+	PortfolioUID string            `json:"portfolio_uid"`
+	Role         string            `json:"role" doc:"当前用户在评审中的身份，owner 或 reviewer"`
+	Status       data.ReviewStatus `json:"status" doc:"invited、in_progress、approved、changes_requested 或 revoked"`
+	Note         string            `json:"note"`
+	Verdict      string            `json:"verdict"`
+	InviteToken  string            `json:"invite_token,omitempty" doc:"仅学生可见，邀请被接受后失效"`
+	AcceptedAt   *time.Time        `json:"accepted_at"`
+	DecidedAt    *time.Time        `json:"decided_at"`
+	CreatedAt    time.Time         `json:"created_at"`
+	UpdatedAt    time.Time         `json:"updated_at"`
+}
+
+type CommentResponse struct {
+	UID        string            `json:"uid"`
+	AuthorRole string            `json:"author_role"`
+	Body       string            `json:"body"`
+	AnchorType data.AnchorType   `json:"anchor_type"`
+	PageUID    string            `json:"page_uid"`
+	WorkUID    string            `json:"work_uid"`
+	Region     *CropRect         `json:"region"`
+	Resolved   bool              `json:"resolved"`
+	ResolvedAt *time.Time        `json:"resolved_at"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Replies    []CommentResponse `json:"replies"`
+}
+
+type ReviewDetailResponse struct {
+	Review    ReviewResponse    `json:"review"`
+	Portfolio PortfolioResponse `json:"portfolio"`
+	// Threads 为按时间排序的讨论，回复位于首条评论的 replies 中
+	Threads []CommentResponse `json:"threads"`
+}
+
+type ReviewRepo interface {
+	CreateReview(context.Context, data.Review) error
+	GetReviewByUID(context.Context, string) (data.Review, error)
+	AcceptReview(context.Context, string, string) (data.Review, error)
+	ListReviewsByPortfolio(context.Context, string) ([]data.Review, error)
+	ListReviewsByReviewer(context.Context, string) ([]data.Review, error)
+	UpdateReviewStatus(context.Context, string, data.ReviewStatus, map[string]any) error
+	CreateReviewComment(context.Context, data.ReviewComment) error
+	GetReviewComment(context.Context, string) (data.ReviewComment, error)
+	ListReviewComments(context.Context, string) ([]data.ReviewComment, error)
+	SetCommentResolved(context.Context, string, bool) error
+}
+
+// ReviewUsecase 作品集的读取与资源 URL 复用 PortfolioUsecase
+type ReviewUsecase struct {
+	repo       ReviewRepo
+	portfolios *PortfolioUsecase
+}
+
+func NewReviewUsecase(repo ReviewRepo, portfolios *PortfolioUsecase) *ReviewUsecase {
+	return &ReviewUsecase{repo: repo, portfolios: portfolios}
+}
+
+func roleOf(review data.Review, openid string) string {
+	switch {
+	case review.OwnerOpenid == openid:
+		return roleOwner
+	case review.ReviewerOpenid == openid && review.Status != data.ReviewRevoked:
+		return roleReviewer
+	}
+	return ""
+}
+
+func toReviewResponse(review data.Review, role string) ReviewResponse {
+	resp := ReviewResponse{
+		UID:          review.UID,
+		PortfolioUID: review.PortfolioUID,
+		Role:         role,
+		Status:       review.Status,
+		Note:         review.Note,
+		Verdict:      review.Verdict,
+		AcceptedAt:   review.AcceptedAt,
+		DecidedAt:    review.DecidedAt,
+		CreatedAt:    review.CreatedAt,
+		UpdatedAt:    review.UpdatedAt,
+	}
+	if role == roleOwner && review.Status == data.ReviewInvited {
+		resp.InviteToken = review.InviteToken
+	}
+	return resp
+}
+
+// participant 返回当前用户参与的评审及其身份，非参与者写入错误响应
+func (uc *ReviewUsecase) participant(c *gin.Context, uid string) (data.Review, string, bool) {
+	review, err := uc.repo.GetReviewByUID(c, uid)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.ServerError, err)
+		return data.Review{}, "", false
+	}
+	role := roleOf(review, c.GetString("openid"))
+	if err != nil || role == "" {
+		ErrorResponse(c, consts.ReviewAccessDenied, uid)
+		return data.Review{}, "", false
+	}
+	return review, role, true
+}
+
+func (uc *ReviewUsecase) Invite(c *gin.Context) {
+	req := InviteReviewRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	if _, ok := uc.portfolios.ownedPortfolio(c, req.PortfolioUID); !ok {
+		return
+	}
+	token, err := newShareToken()
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	now := time.Now()
+	review := data.Review{
+		UID:          uuid.New().String(),
+		PortfolioUID: req.PortfolioUID,
+		OwnerOpenid:  c.GetString("openid"),
+		InviteToken:  token,
+		Status:       data.ReviewInvited,
+		Note:         req.Note,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	if err := uc.repo.CreateReview(c, review); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, toReviewResponse(review, roleOwner))
+}
+
+// Accept 评审人通过邀请 token 加入评审，学生本人不能接受自己的邀请
+func (uc *ReviewUsecase) Accept(c *gin.Context) {
+	req := AcceptReviewRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	review, err := uc.repo.AcceptReview(c, req.Token, c.GetString("openid"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.ReviewInviteInvalid)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, toReviewResponse(review, roleReviewer))
+}
+
+func (uc *ReviewUsecase) ListByPortfolio(c *gin.Context) {
+	portfolioUID := c.Query("portfolio_uid")
+	if _, ok := uc.portfolios.ownedPortfolio(c, portfolioUID); !ok {
+		return
+	}
+	reviews, err := uc.repo.ListReviewsByPortfolio(c, portfolioUID)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	resp := make([]ReviewResponse, 0, len(reviews))
+	for _, review := range reviews {
+		resp = append(resp, toReviewResponse(review, roleOwner))
+	}
+	SuccessResponse(c, resp)
+}
+
+// ListMine 返回当前用户作为评审人参与的评审
+func (uc *ReviewUsecase) ListMine(c *gin.Context) {
+	reviews, err := uc.repo.ListReviewsByReviewer(c, c.GetString("openid"))
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	resp := make([]ReviewResponse, 0, len(reviews))
+	for _, review := range reviews {
+		resp = append(resp, toReviewResponse(review, roleReviewer))
+	}
+	SuccessResponse(c, resp)
+}
+
+// Get 返回评审、附带资源 URL 的作品集以及全部讨论
+func (uc *ReviewUsecase) Get(c *gin.Context) {
+	review, role, ok := uc.participant(c, c.Query("uid"))
+	if !ok {
+		return
+	}
+	portfolio, err := uc.portfolios.repo.GetPortfolioByUIDFromDB(c, review.PortfolioUID)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	comments, err := uc.repo.ListReviewComments(c, review.UID)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	view, err := uc.portfolios.participantView(c, portfolio)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, ReviewDetailResponse{
+		Review:    toReviewResponse(review, role),
+		Portfolio: view,
+		Threads:   toCommentThreads(review, comments),
+	})
+}
+
+func toCommentThreads(review data.Review, comments []data.ReviewComment) []CommentResponse {
+	threads := []CommentResponse{}
+	index := map[string]int{}
+	for _, comment := range comments {
+		role := roleReviewer
+		if comment.AuthorOpenid == review.OwnerOpenid {
+			role = roleOwner
+		}
+		resp := CommentResponse{
+			UID:        comment.UID,
+			AuthorRole: role,
+			Body:       comment.Body,
+			AnchorType: comment.AnchorType,
+			PageUID:    comment.PageUID,
+			WorkUID:    comment.WorkUID,
+			Resolved:   comment.ResolvedAt != nil,
+			ResolvedAt: comment.ResolvedAt,
+			CreatedAt:  comment.CreatedAt,
+			Replies:    []CommentResponse{},
+		}
+		if comment.Region != nil {
+			region := CropRect(*comment.Region)
+			resp.Region = &region
+		}
+		if i, ok := index[comment.ParentUID]; ok {
+			threads[i].Replies = append(threads[i].Replies, resp)
+			continue
+		}
+		index[comment.UID] = len(threads)
+		threads = append(threads, resp)
+	}
+	return threads
+}
+
+// validate 检查锚点指向的页面与作品属于该作品集
+func (in AnchorInput) validate(portfolio data.Portfolio) error {
+	hasPage := func(uid string) bool {
+		for _, page := range portfolio.Pages {
+			if page.UID == uid {
+				return true
+			}
+		}
+		return false
+	}
+	switch in.Type {
+	case data.AnchorPage:
+		if !hasPage(in.PageUID) {
+			return errors.New("page not in portfolio: " + in.PageUID)
+		}
+	case data.AnchorRegion:
+		if !hasPage(in.PageUID) {
+			return errors.New("page not in portfolio: " + in.PageUID)
+		}
+		if in.Region == nil || !in.Region.within() {
+			return errors.New("region must lie within the page")
+		}
+	case data.AnchorWork:
+		for _, project := range portfolio.Projects {
+			for _, work := range project.Works {
+				if work.UID == in.WorkUID {
+					return nil
+				}
+			}
+		}
+		return errors.New("work not in portfolio: " + in.WorkUID)
+	}
+	return nil
+}
+
+// AddComment 学生与评审人都可以发起讨论或回复
+func (uc *ReviewUsecase) AddComment(c *gin.Context) {
+	req := AddCommentRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	review, _, ok := uc.participant(c, req.ReviewUID)
+	if !ok {
+		return
+	}
+	if review.Status == data.ReviewRevoked {
+		ErrorResponse(c, consts.ReviewAccessDenied, review.UID)
+		return
+	}
+	comment := data.ReviewComment{
+		UID:          uuid.New().String(),
+		ReviewUID:    review.UID,
+		ParentUID:    req.ParentUID,
+		AuthorOpenid: c.GetString("openid"),
+		Body:         req.Body,
+		CreatedAt:    time.Now(),
+	}
+	if req.ParentUID != "" {
+		parent, err := uc.repo.GetReviewComment(c, req.ParentUID)
+		if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && (parent.ReviewUID != review.UID || parent.ParentUID != "")) {
+			ErrorResponse(c, consts.InvalidParams, "parent is not a thread of this review: "+req.ParentUID)
+			return
+		}
+		if err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+		comment.AnchorType, comment.PageUID, comment.WorkUID, comment.Region =
+			parent.AnchorType, parent.PageUID, parent.WorkUID, parent.Region
+	} else {
+		anchor := req.Anchor
+		if anchor.Type == "" {
+			anchor.Type = data.AnchorPortfolio
+		}
+		portfolio, err := uc.portfolios.repo.GetPortfolioByUIDFromDB(c, review.PortfolioUID)
+		if err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+		if err := anchor.validate(portfolio); err != nil {
+			ErrorResponse(c, consts.InvalidParams, err)
+			return
+		}
+		comment.AnchorType = anchor.Type
+		switch anchor.Type {
+		case data.AnchorPage:
+			comment.PageUID = anchor.PageUID
+		case data.AnchorRegion:
+			region := data.CropRect(*anchor.Region)
+			comment.PageUID, comment.Region = anchor.PageUID, &region
+		case data.AnchorWork:
+			comment.WorkUID = anchor.WorkUID
+		}
+	}
+	if err := uc.repo.CreateReviewComment(c, comment); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, toCommentThreads(review, []data.ReviewComment{comment})[0])
+}
+
+// ResolveComment 标记讨论为已解决或重新打开，只能作用于讨论的首条评论
+func (uc *ReviewUsecase) ResolveComment(c *gin.Context) {
+	req := ResolveCommentRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	comment, err := uc.repo.GetReviewComment(c, req.CommentUID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.InvalidParams, "comment not found: "+req.CommentUID)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	review, _, ok := uc.participant(c, comment.ReviewUID)
+	if !ok {
+		return
+	}
+	if review.Status == data.ReviewRevoked {
+		ErrorResponse(c, consts.ReviewAccessDenied, review.UID)
+		return
+	}
+	if comment.ParentUID != "" {
+		ErrorResponse(c, consts.InvalidParams, "only the first comment of a thread can be resolved")
+		return
+	}
+	if err := uc.repo.SetCommentResolved(c, comment.UID, req.Resolved); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, nil)
+}
+
+// Decide 评审人给出通过或需要修改的结论，结论可以修改
+func (uc *ReviewUsecase) Decide(c *gin.Context) {
+	req := ReviewDecisionRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	review, role, ok := uc.participant(c, req.ReviewUID)
+	if !ok {
+		return
+	}
+	if role != roleReviewer {
+		ErrorResponse(c, consts.ReviewAccessDenied, review.UID)
+		return
+	}
+	now := time.Now()
+	if err := uc.repo.UpdateReviewStatus(c, review.UID, req.Status, map[string]any{
+		"verdict":    req.Verdict,
+		"decided_at": now,
+	}); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	review.Status, review.Verdict, review.DecidedAt, review.UpdatedAt = req.Status, req.Verdict, &now, now
+	SuccessResponse(c, toReviewResponse(review, role))
+}
+
+// Revoke 学生撤销评审，评审人随即失去访问权限，未接受的邀请同时失效
+func (uc *ReviewUsecase) Revoke(c *gin.Context) {
+	req := ReviewRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	review, role, ok := uc.participant(c, req.ReviewUID)
+	if !ok {
+		return
+	}
+	if role != roleOwner {
+		ErrorResponse(c, consts.ReviewAccessDenied, review.UID)
+		return
+	}
+	if err := uc.repo.UpdateReviewStatus(c, review.UID, data.ReviewRevoked, nil); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, nil)
+}
@@ -0,0 +1,108 @@
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// 作品集的 ETag 由版本号生成。resolve=urls 时响应中的签名 URL 每次不同，因此使用弱 ETag，
+// If-Match 只比较版本号
+func revisionETag(revision int64) string {
+	return `W/"` + strconv.FormatInt(revision, 10) + `"`
+}
+
+func setRevisionETag(c *gin.Context, revision int64) {
+	c.Header("ETag", revisionETag(revision))
+}
+
+// parseETagRevision 解析 "3" 或 W/"3" 形式的 ETag
+func parseETagRevision(tag string) (int64, error) {
+	tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, errors.New("invalid etag: " + tag)
+	}
+	return strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+}
+
+// ifMatchRevision 返回 If-Match 头中的版本号，未设置或为 * 时返回 nil
+func ifMatchRevision(c *gin.Context) (*int64, error) {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+	if strings.Contains(header, ",") {
+		return nil, errors.New("If-Match accepts a single etag")
+	}
+	revision, err := parseETagRevision(header)
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// notModified 在 If-None-Match 包含当前版本时返回 304。
+// 附带资源 URL 的响应中签名会过期，客户端缓存的内容不能复用，因此始终返回完整响应
+func notModified(c *gin.Context, revision int64) bool {
+	header := c.GetHeader("If-None-Match")
+	if header == "" || resolveURLs(c) {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		if strings.TrimSpace(tag) == "*" {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+		if r, err := parseETagRevision(tag); err == nil && r == revision {
+			c.Status(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// respondConflict 返回作品集的当前版本，客户端合并后以新的版本号重新保存；
+// 作品集不属于当前用户时不返回其内容
+func (uc *PortfolioUsecase) respondConflict(c *gin.Context, uid string) {
+	portfolio, err := uc.repo.GetPortfolioByUIDFromDB(c, uid)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	owned := portfolio.Openid == c.GetString("openid")
+	if !owned {
+		ErrorResponse(c, consts.PortfolioAccessDenied, uid)
+		return
+	}
+	resp := []PortfolioResponse{toPortfolioResponse(portfolio)}
+	if resolveURLs(c) {
+		if err := uc.resolvePortfolios(c, resp, owned); err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+	}
+	setRevisionETag(c, portfolio.Revision)
+	ErrorDataResponse(c, consts.PortfolioConflict, resp[0])
+}
+
+// broadcastResync 在协同编辑之外增加版本号的修改提交后调用，通知协同连接重新获取快照。
+// 请求的 ctx 可能已结束，广播使用独立的超时
+func (uc *PortfolioUsecase) broadcastResync(ctx context.Context, portfolioUID string, revision int64) {
+	payload, err := json.Marshal(CollabServerMessage{Type: collabResync, Seq: revision})
+	if err != nil {
+		zap.L().Error("marshal resync error", zap.Error(err))
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), collabWriteWait)
+	defer cancel()
+	if err := uc.repo.PublishCollabEvent(ctx, portfolioUID, payload); err != nil {
+		zap.L().Error("PublishCollabEvent error", zap.Error(err))
+	}
+}
@@ -0,0 +1,114 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParseETagRevision(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want int64
+		ok   bool
+	}{
+		{tag: `"3"`, want: 3, ok: true},
+		{tag: `W/"42"`, want: 42, ok: true},
+		{tag: ` W/"7" `, want: 7, ok: true},
+		{tag: revisionETag(9), want: 9, ok: true},
+		{tag: `3`},
+		{tag: `"3`},
+		{tag: `""`},
+		{tag: `"abc"`},
+		{tag: `w/"3"`},
+		{tag: `"`},
+		{tag: ``},
+	}
+	for _, tt := range tests {
+		got, err := parseETagRevision(tt.tag)
+		if tt.ok && (err != nil || got != tt.want) {
+			t.Errorf("parseETagRevision(%q) = %d, %v; want %d", tt.tag, got, err, tt.want)
+		}
+		if !tt.ok && err == nil {
+			t.Errorf("parseETagRevision(%q) = %d, want error", tt.tag, got)
+		}
+	}
+}
+
+// testContext 创建带有请求头的 gin.Context
+func testContext(target string, header map[string]string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		c.Request.Header.Set(k, v)
+	}
+	return c, w
+}
+
+func TestIfMatchRevision(t *testing.T) {
+	tests := []struct {
+		header string
+		// set 表示应返回版本号，为 false 时应返回 nil
+		set  bool
+		want int64
+		err  bool
+	}{
+		{header: ""},
+		{header: "*"},
+		{header: `W/"5"`, set: true, want: 5},
+		{header: `"5"`, set: true, want: 5},
+		{header: `"5", "6"`, err: true},
+		{header: `5`, err: true},
+	}
+	for _, tt := range tests {
+		c, _ := testContext("/", map[string]string{"If-Match": tt.header})
+		got, err := ifMatchRevision(c)
+		switch {
+		case tt.err:
+			if err == nil {
+				t.Errorf("If-Match %q: want error", tt.header)
+			}
+		case err != nil:
+			t.Errorf("If-Match %q: unexpected error %v", tt.header, err)
+		case !tt.set && got != nil:
+			t.Errorf("If-Match %q: revision = %d, want nil", tt.header, *got)
+		case tt.set && (got == nil || *got != tt.want):
+			t.Errorf("If-Match %q: revision = %v, want %d", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		target string
+		header string
+		want   bool
+	}{
+		{target: "/", header: ""},
+		{target: "/", header: `W/"3"`, want: true},
+		{target: "/", header: `"3"`, want: true},
+		{target: "/", header: `W/"2", W/"3"`, want: true},
+		{target: "/", header: "*", want: true},
+		{target: "/", header: `W/"2"`},
+		{target: "/", header: `invalid`},
+		// 附带签名 URL 的响应始终完整返回
+		{target: "/?resolve=urls", header: `W/"3"`},
+	}
+	for _, tt := range tests {
+		c, w := testContext(tt.target, map[string]string{"If-None-Match": tt.header})
+		got := notModified(c, 3)
+		if got != tt.want {
+			t.Errorf("%s If-None-Match %q: notModified = %v, want %v", tt.target, tt.header, got, tt.want)
+		}
+		if got {
+			c.Writer.WriteHeaderNow()
+			if w.Code != http.StatusNotModified {
+				t.Errorf("%s If-None-Match %q: status = %d, want 304", tt.target, tt.header, w.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package controller
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/Fl0rencess720/Springboard/internal/middleware"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+type CreateShareLinkRequest struct {
+	PortfolioUID string `json:"portfolio_uid" binding:"required"`
+	ExpiresIn    int    `json:"expires_in" binding:"min=0,max=8760" doc:"有效期（小时），0 表示永不过期"`
+	Password     string `json:"password" binding:"omitempty,min=4,max=64" doc:"为空时无需密码"`
+}
+
+type RevokeShareLinkRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ViewShareRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password"`
+}
+
+type ShareLinkResponse struct {
+	Token        string     `json:"token"`
+	PortfolioUID string     `json:"portfolio_uid"`
+	HasPassword  bool       `json:"has_password"`
+	Active       bool       `json:"active" doc:"未撤销且未过期"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ViewCount    int64      `json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// SharedPortfolioResponse 为通过分享链接查看的只读作品集，不包含作品集 uid，
+// 撤销或过期的链接不能再用于读取作品集
+type SharedPortfolioResponse struct {
+	Title       string                  `json:"title"`
+	TemplateUID string                  `json:"template_uid"`
+	Template    TemplateResponse        `json:"template"`
+	Projects    []ProjectResponse       `json:"projects"`
+	Pages       []PortfolioPageResponse `json:"pages"`
+	UpdatedAt   time.Time               `json:"updated_at"`
+}
+
+func toShareLinkResponse(link data.ShareLink) ShareLinkResponse {
+	return ShareLinkResponse{
+		Token:        link.Token,
+		PortfolioUID: link.PortfolioUID,
+		HasPassword:  link.PasswordHash != "",
+		Active:       link.Active(time.Now()),
+		ExpiresAt:    link.ExpiresAt,
+		RevokedAt:    link.RevokedAt,
+		ViewCount:    link.ViewCount,
+		LastViewedAt: link.LastViewedAt,
+		CreatedAt:    link.CreatedAt,
+	}
+}
+
+// newShareToken 生成 256 位随机 token
+func newShareToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func (uc *PortfolioUsecase) CreateShareLink(c *gin.Context) {
+	req := CreateShareLinkRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	if _, ok := uc.ownedPortfolio(c, req.PortfolioUID); !ok {
+		return
+	}
+	token, err := newShareToken()
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	link := data.ShareLink{
+		Token:        token,
+		PortfolioUID: req.PortfolioUID,
+		Openid:       c.GetString("openid"),
+		CreatedAt:    time.Now(),
+	}
+	if req.ExpiresIn > 0 {
+		expiresAt := link.CreatedAt.Add(time.Duration(req.ExpiresIn) * time.Hour)
+		link.ExpiresAt = &expiresAt
+	}
+	if req.Password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+		if err != nil {
+			ErrorResponse(c, consts.ServerError, err)
+			return
+		}
+		link.PasswordHash = string(hash)
+	}
+	if err := uc.repo.CreateShareLink(c, link); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, toShareLinkResponse(link))
+}
+
+func (uc *PortfolioUsecase) ListShareLinks(c *gin.Context) {
+	portfolioUID := c.Query("portfolio_uid")
+	if _, ok := uc.ownedPortfolio(c, portfolioUID); !ok {
+		return
+	}
+	links, err := uc.repo.ListShareLinks(c, portfolioUID)
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	resp := make([]ShareLinkResponse, 0, len(links))
+	for _, link := range links {
+		resp = append(resp, toShareLinkResponse(link))
+	}
+	SuccessResponse(c, resp)
+}
+
+func (uc *PortfolioUsecase) RevokeShareLink(c *gin.Context) {
+	req := RevokeShareLinkRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	err := uc.repo.RevokeShareLink(c, c.GetString("openid"), req.Token)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.ShareLinkInvalid, req.Token)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	SuccessResponse(c, nil)
+}
+
+// ViewShare 为公开接口，校验链接后返回附带资源 URL 的只读作品集
+func (uc *PortfolioUsecase) ViewShare(c *gin.Context) {
+	req := ViewShareRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		ErrorResponse(c, consts.InvalidParams, err)
+		return
+	}
+	link, err := uc.repo.GetShareLinkByToken(c, req.Token)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && !link.Active(time.Now())) {
+		ErrorResponse(c, consts.ShareLinkInvalid)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if link.PasswordHash != "" &&
+		bcrypt.CompareHashAndPassword([]byte(link.PasswordHash), []byte(req.Password)) != nil {
+		c.Set(string(middleware.FailedAttemptKey), true)
+		ErrorResponse(c, consts.SharePasswordIncorrect)
+		return
+	}
+	portfolio, err := uc.repo.GetPortfolioByUIDFromDB(c, link.PortfolioUID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		ErrorResponse(c, consts.ShareLinkInvalid)
+		return
+	}
+	if err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	// 分享即授权查看作品原图，因此按所有者的方式签名
+	resp := []PortfolioResponse{toPortfolioResponse(portfolio)}
+	if err := uc.resolvePortfolios(c, resp, true); err != nil {
+		ErrorResponse(c, consts.ServerError, err)
+		return
+	}
+	if err := uc.repo.RecordShareView(c, link.Token); err != nil {
+		zap.L().Error("RecordShareView error", zap.Error(err))
+	}
+	SuccessResponse(c, SharedPortfolioResponse{
+		Title:       resp[0].Title,
+		TemplateUID: resp[0].TemplateUID,
+		Template:    resp[0].Template,
+		Projects:    resp[0].Projects,
+		Pages:       resp[0].Pages,
+		UpdatedAt:   resp[0].UpdatedAt,
+	})
+}
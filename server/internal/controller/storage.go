@@ -0,0 +1,25 @@
+package controller
+
+import (
+	"context"
+
+	"github.com/Fl0rencess720/Springboard/pkgs/oss"
+)
+
+// Storage 为上传、处理与清理流程依赖的对象存储操作，由 oss.Bucket 实现
+type Storage interface {
+	PresignPreviewUrl(string) (oss.PresignedURL, error)
+	PresignUploadUrl(string, string, int64) (string, map[string]string, error)
+	HeadObject(context.Context, string) (oss.ObjectMeta, error)
+	GetObject(context.Context, string, int64) ([]byte, error)
+	PutObject(context.Context, string, string, []byte) error
+	ListObjects(context.Context, string, func(oss.ObjectMeta) error) error
+	DeleteObjects(context.Context, []string) error
+
+	// 分片上传，中断后可通过 ListParts 查询已完成的分片继续上传
+	InitiateMultipartUpload(context.Context, string, string) (string, error)
+	PresignUploadPart(string, string, int32) (string, error)
+	ListParts(context.Context, string, string) ([]oss.PartMeta, error)
+	CompleteMultipartUpload(context.Context, string, string) error
+	AbortMultipartUpload(context.Context, string, string) error
+}
@@ -0,0 +1,190 @@
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/Fl0rencess720/Springboard/internal/conf"
+	"github.com/Fl0rencess720/Springboard/internal/data"
+	"github.com/Fl0rencess720/Springboard/pkgs/oss"
+	"go.uber.org/zap"
+)
+
+const uploadsPrefix = "uploads/"
+
+// minSweepInterval 为两轮清理的最小间隔，interval 未配置或过小时按此间隔等待，避免空转
+const minSweepInterval = time.Minute
+
+type SweeperRepo interface {
+	ListWorkKeys(context.Context) ([]string, error)
+	ListFeedbackAttachments(context.Context) ([]string, error)
+	ListTemplateAssetKeys(context.Context) ([]string, error)
+	ListLatestUploadKeys(context.Context, string) ([]string, error)
+	ListRenditionKeys(context.Context) (map[string][]string, error)
+	ListReferencedAssetKeys(context.Context) ([]string, error)
+	DeleteUploads(context.Context, []string) error
+	DeleteAssets(context.Context, []string) error
+	DeleteStalePendingUploads(context.Context, time.Time, map[string]struct{}) (int, error)
+	ListStaleMultipartUploads(context.Context, time.Time) ([]data.Upload, error)
+	AcquireLock(context.Context, string, time.Duration) (bool, error)
+}
+
+type SweepReport struct {
+	DryRun     bool     `json:"dry_run"`
+	Scanned    int      `json:"scanned"`
+	Referenced int      `json:"referenced"`
+	InGrace    int      `json:"in_grace"`
+	Orphans    []string `json:"orphans"`
+	Deleted    int      `json:"deleted"`
+	// Truncated 表示孤立对象数量超过 max_deletions，剩余部分留到下一轮
+	Truncated    bool  `json:"truncated"`
+	StaleUploads int   `json:"stale_uploads"`
+	DurationMs   int64 `json:"duration_ms"`
+}
+
+// OrphanSweeper 定期清理 uploads/ 下超过宽限期仍未被作品、反馈或头像引用的对象
+type OrphanSweeper struct {
+	repo    SweeperRepo
+	storage Storage
+}
+
+func NewOrphanSweeper(repo SweeperRepo, storage Storage) *OrphanSweeper {
+	return &OrphanSweeper{repo: repo, storage: storage}
+}
+
+// Run 阻塞运行直到 ctx 结束，每轮读取最新的 gc 配置
+func (s *OrphanSweeper) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(sweepInterval(conf.Get().GC)):
+		}
+		cfg := conf.Get().GC
+		if !cfg.Enabled {
+			continue
+		}
+		locked, err := s.repo.AcquireLock(ctx, "gc:uploads", sweepInterval(cfg)/2)
+		if err != nil {
+			zap.L().Error("AcquireLock error", zap.Error(err))
+			continue
+		}
+		if !locked {
+			continue
+		}
+		report, err := s.Sweep(ctx, cfg)
+		if err != nil {
+			zap.L().Error("Sweep error", zap.Error(err))
+			continue
+		}
+		zap.L().Info("orphan sweep finished", zap.Any("report", report))
+	}
+}
+
+func sweepInterval(cfg conf.GC) time.Duration {
+	return max(cfg.Interval, minSweepInterval)
+}
+
+func (s *OrphanSweeper) Sweep(ctx context.Context, cfg conf.GC) (SweepReport, error) {
+	start := time.Now()
+	report := SweepReport{DryRun: cfg.DryRun, Orphans: []string{}}
+
+	referenced, err := s.referencedKeys(ctx)
+	if err != nil {
+		return report, err
+	}
+
+	cutoff := start.Add(-cfg.GracePeriod)
+	existing := map[string]struct{}{}
+	err = s.storage.ListObjects(ctx, uploadsPrefix, func(obj oss.ObjectMeta) error {
+		report.Scanned++
+		existing[obj.Key] = struct{}{}
+		if _, ok := referenced[obj.Key]; ok {
+			report.Referenced++
+			return nil
+		}
+		if obj.LastModified.After(cutoff) {
+			report.InGrace++
+			return nil
+		}
+		report.Orphans = append(report.Orphans, obj.Key)
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	if !cfg.DryRun {
+		toDelete := report.Orphans
+		if len(toDelete) > cfg.MaxDeletions {
+			toDelete = toDelete[:cfg.MaxDeletions]
+			report.Truncated = true
+		}
+		if len(toDelete) > 0 {
+			if err := s.storage.DeleteObjects(ctx, toDelete); err != nil {
+				return report, err
+			}
+			report.Deleted = len(toDelete)
+			if err := s.repo.DeleteUploads(ctx, toDelete); err != nil {
+				return report, err
+			}
+			if err := s.repo.DeleteAssets(ctx, toDelete); err != nil {
+				return report, err
+			}
+		}
+		// 未完成的分片不会出现在对象列表中，需要单独中止，否则分片会一直占用存储
+		multiparts, err := s.repo.ListStaleMultipartUploads(ctx, cutoff)
+		if err != nil {
+			return report, err
+		}
+		for _, upload := range multiparts {
+			if err := s.storage.AbortMultipartUpload(ctx, upload.OSSKey, upload.MultipartID); err != nil {
+				zap.L().Error("AbortMultipartUpload error", zap.String("oss_key", upload.OSSKey), zap.Error(err))
+			}
+		}
+		stale, err := s.repo.DeleteStalePendingUploads(ctx, cutoff, existing)
+		if err != nil {
+			return report, err
+		}
+		report.StaleUploads = stale
+	}
+	report.DurationMs = time.Since(start).Milliseconds()
+	return report, nil
+}
+
+func (s *OrphanSweeper) referencedKeys(ctx context.Context) (map[string]struct{}, error) {
+	sources := []func(context.Context) ([]string, error){
+		s.repo.ListWorkKeys,
+		s.repo.ListFeedbackAttachments,
+		s.repo.ListTemplateAssetKeys,
+		s.repo.ListReferencedAssetKeys,
+		// 头像目前没有单独的资料表，每个用户最近确认的头像视为被引用
+		func(ctx context.Context) ([]string, error) {
+			return s.repo.ListLatestUploadKeys(ctx, PurposeAvatar)
+		},
+	}
+	referenced := map[string]struct{}{}
+	for _, source := range sources {
+		keys, err := source(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, key := range keys {
+			referenced[key] = struct{}{}
+		}
+	}
+	// 缩略图跟随原图，原图被引用时缩略图同样保留
+	renditions, err := s.repo.ListRenditionKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for original, keys := range renditions {
+		if _, ok := referenced[original]; !ok {
+			continue
+		}
+		for _, key := range keys {
+			referenced[key] = struct{}{}
+		}
+	}
+	return referenced, nil
+}
@@ -0,0 +1,37 @@
+package controller
+
+// 上传用途，决定允许的文件类型、大小以及对象 key 的前缀
+const (
+	PurposeWork     = "work"
+	PurposeAvatar   = "avatar"
+	PurposeFeedback = "feedback"
+	PurposeFont     = "font"
+)
+
+type UploadPolicy struct {
+	// ContentTypes 为允许的 MIME 类型到对象扩展名的映射
+	ContentTypes map[string]string
+	MaxSize      int64
+	// Process 表示确认上传后进入图片处理流程
+	Process bool
+	// Dedup 表示按内容哈希去重，相同内容共用同一个资源
+	Dedup bool
+}
+
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/webp": ".webp",
+}
+
+var UploadPolicies = map[string]UploadPolicy{
+	PurposeWork:     {ContentTypes: imageTypes, MaxSize: 50 << 20, Process: true, Dedup: true},
+	PurposeAvatar:   {ContentTypes: imageTypes, MaxSize: 5 << 20, Process: true},
+	PurposeFeedback: {ContentTypes: imageTypes, MaxSize: 10 << 20, Process: true},
+	PurposeFont: {ContentTypes: map[string]string{
+		"font/ttf":   ".ttf",
+		"font/otf":   ".otf",
+		"font/woff":  ".woff",
+		"font/woff2": ".woff2",
+	}, MaxSize: 30 << 20},
+}
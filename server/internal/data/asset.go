@@ -0,0 +1,139 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Asset 为按内容哈希去重后的作品文件，相同内容的多次上传共用同一个对象
+type Asset struct {
+	ID          uint    `gorm:"primarykey" json:"id"`
+	SHA256      string  `gorm:"unique;type:char(64)" json:"sha256"`
+	OSSKey      string  `gorm:"unique;type:varchar(255)" json:"oss_key"`
+	ContentType string  `gorm:"type:varchar(255)" json:"content_type"`
+	Size        int64   `json:"size"`
+	Width       int     `json:"width"`
+	Height      int     `json:"height"`
+	DPI         float64 `gorm:"type:double" json:"dpi"`
+	ThumbKey    string  `gorm:"type:varchar(255)" json:"thumb_key"`
+	PreviewKey  string  `gorm:"type:varchar(255)" json:"preview_key"`
+	// RefCount 为引用该资源的作品数量，每次保存作品集后重新统计
+	RefCount  int `gorm:"type:int;default:0" json:"ref_count"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (r *OSSRepo) GetAssetBySHA256(ctx context.Context, sum string) (Asset, error) {
+	asset := Asset{}
+	if err := r.mysqlDB.WithContext(ctx).Where("sha256 = ?", sum).First(&asset).Error; err != nil {
+		return Asset{}, err
+	}
+	return asset, nil
+}
+
+// LinkAsset 上传内容与已有资源重复时，直接复用资源的处理结果，新上传的对象由清理任务回收
+func (r *OSSRepo) LinkAsset(ctx context.Context, ossKey string, asset Asset) error {
+	return r.mysqlDB.WithContext(ctx).Model(&Upload{}).Where("oss_key = ?", ossKey).Updates(map[string]any{
+		"process_status": ProcessDone,
+		"asset_id":       asset.ID,
+		"width":          asset.Width,
+		"height":         asset.Height,
+		"dpi":            asset.DPI,
+		"thumb_key":      asset.ThumbKey,
+		"preview_key":    asset.PreviewKey,
+	}).Error
+}
+
+// CreateAsset 以该上传为内容登记资源；并发上传相同内容时以先写入者为准，并关联到上传记录
+func (r *OSSRepo) CreateAsset(ctx context.Context, upload Upload, sum string) (Asset, error) {
+	asset := Asset{}
+	err := r.mysqlDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&Asset{
+			SHA256:      sum,
+			OSSKey:      upload.OSSKey,
+			ContentType: upload.ContentType,
+			Size:        upload.Size,
+			Width:       upload.Width,
+			Height:      upload.Height,
+			DPI:         upload.DPI,
+			ThumbKey:    upload.ThumbKey,
+			PreviewKey:  upload.PreviewKey,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("sha256 = ?", sum).First(&asset).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Upload{}).Where("oss_key = ?", upload.OSSKey).Update("asset_id", asset.ID).Error
+	})
+	return asset, err
+}
+
+// ListReferencedAssetKeys 返回仍被作品引用的资源及其缩略图
+func (r *OSSRepo) ListReferencedAssetKeys(ctx context.Context) ([]string, error) {
+	assets := []Asset{}
+	if err := r.mysqlDB.WithContext(ctx).Select("oss_key", "thumb_key", "preview_key").
+		Where("ref_count > 0").Find(&assets).Error; err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(assets)*3)
+	for _, asset := range assets {
+		keys = append(keys, asset.OSSKey, asset.ThumbKey, asset.PreviewKey)
+	}
+	return keys, nil
+}
+
+// DeleteAssets 对象被清理后删除对应资源，避免后续上传被去重到已不存在的对象
+func (r *OSSRepo) DeleteAssets(ctx context.Context, ossKeys []string) error {
+	if len(ossKeys) == 0 {
+		return nil
+	}
+	return r.mysqlDB.WithContext(ctx).Where("oss_key IN ? AND ref_count = 0", ossKeys).Delete(&Asset{}).Error
+}
+
+// resolveWorkAssets 将引用上传对象的作品关联到去重后的资源，并改为引用资源的对象
+func resolveWorkAssets(tx *gorm.DB, works []Work) error {
+	keys := []string{}
+	for _, work := range works {
+		if work.OSSKey != "" {
+			keys = append(keys, work.OSSKey)
+		}
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	rows := []struct {
+		UploadKey string
+		AssetID   uint
+		AssetKey  string
+	}{}
+	if err := tx.Model(&Upload{}).
+		Select("uploads.oss_key AS upload_key, assets.id AS asset_id, assets.oss_key AS asset_key").
+		Joins("JOIN assets ON assets.id = uploads.asset_id").
+		Where("uploads.oss_key IN ?", keys).Scan(&rows).Error; err != nil {
+		return err
+	}
+	assets := map[string]Asset{}
+	for _, row := range rows {
+		assets[row.UploadKey] = Asset{ID: row.AssetID, OSSKey: row.AssetKey}
+	}
+	for i := range works {
+		if asset, ok := assets[works[i].OSSKey]; ok {
+			works[i].AssetID = &asset.ID
+			works[i].OSSKey = asset.OSSKey
+		}
+	}
+	return nil
+}
+
+// recountAssetRefs 按 works 表重新统计资源引用数，不依赖增量加减，重复保存也不会计错
+func recountAssetRefs(tx *gorm.DB, assetIDs []uint) error {
+	if len(assetIDs) == 0 {
+		return nil
+	}
+	return tx.Model(&Asset{}).Where("id IN ?", assetIDs).
+		Update("ref_count", tx.Model(&Work{}).Select("COUNT(*)").Where("works.asset_id = assets.id")).Error
+}
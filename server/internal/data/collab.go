@@ -0,0 +1,321 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+type OpType string
+
+const (
+	OpMoveWork   OpType = "move_work"
+	OpUpdateText OpType = "update_text"
+	OpMovePage   OpType = "move_page"
+	OpAddWork    OpType = "add_work"
+	// OpMoveProject 只调整项目顺序，不移动项目的页面
+	OpMoveProject OpType = "move_project"
+	OpDeletePage  OpType = "delete_page"
+)
+
+// Op 为协同编辑中的一次细粒度修改
+// move_work 写入 Work 的位置与变换，update_text 写入 Text 除归属外的全部字段，move_page 将页面移动到 To，
+// add_work 在 Work.ProjectUID 中创建 Work，move_project 将项目移动到 To，delete_page 删除页面及其中的内容
+type Op struct {
+	Type OpType
+	// UID 为被修改的作品、文本、页面或项目，add_work 时为新作品的 UID
+	UID  string
+	Work Work
+	Text Text
+	To   int
+}
+
+var (
+	transformColumns = []string{"crop", "rotation", "flip_x", "flip_y", "z_index", "opacity"}
+	workMoveColumns  = append([]string{"size", "margin_top", "margin_left", "scale", "page", "slot_uid"}, transformColumns...)
+	textEditColumns  = append([]string{"content", "runs", "font_size", "font_color", "font_family", "font_weight", "align",
+		"line_height", "letter_spacing", "size", "margin_top", "margin_left", "page", "slot_uid"}, transformColumns...)
+)
+
+// OpLogEntry 为操作日志中的一条记录，Payload 为广播给客户端的原始消息
+type OpLogEntry struct {
+	Seq     int64
+	Payload []byte
+}
+
+// CollabEvent 为从其他副本收到的协同消息
+type CollabEvent struct {
+	PortfolioUID string
+	Payload      []byte
+}
+
+// Presence 为一个在线的协同连接，UserID 为 openid 的摘要，不暴露 openid 本身
+type Presence struct {
+	ConnID   string    `json:"conn_id"`
+	UserID   string    `json:"user_id"`
+	Role     string    `json:"role"`
+	JoinedAt time.Time `json:"joined_at"`
+	LastSeen time.Time `json:"last_seen"`
+}
+
+const (
+	collabLogKeyPrefix      = "collab:log:"
+	collabPresenceKeyPrefix = "collab:presence:"
+	collabChannelPrefix     = "collab:events:"
+
+	// 日志只用于断线重连补发，更早的操作由快照覆盖
+	collabLogSize = 500
+	collabLogTTL  = 24 * time.Hour
+	// PresenceTimeout 内没有心跳的连接视为已离开，用于清理崩溃副本遗留的记录
+	PresenceTimeout = 90 * time.Second
+)
+
+type CollabRepo struct {
+	mysqlDB     *gorm.DB
+	redisClient *redis.Client
+}
+
+func NewCollabRepo(mysqlDB *gorm.DB, redisClient *redis.Client) CollabRepo {
+	return CollabRepo{mysqlDB: mysqlDB, redisClient: redisClient}
+}
+
+// ApplyOps 在同一事务中依次执行 ops，以作品集的新版本号作为序号，并在持有行锁时调用 publish，
+// 保证各副本收到的顺序与写入顺序一致；任一修改的目标不存在时返回 gorm.ErrRecordNotFound。
+// 整体保存等其他修改同样会增加版本号，序号递增但不保证连续；publish 在提交前调用，
+// 调用后提交失败时由调用方广播 resync 撤回已广播的修改。
+// ifRevision 不为空且与当前版本不一致时返回 ErrRevisionConflict
+func (r CollabRepo) ApplyOps(ctx context.Context, portfolioUID string, ops []Op, ifRevision *int64, publish func(seq int64) error) (int64, error) {
+	var seq int64
+	err := r.mysqlDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if seq, err = bumpRevision(tx, portfolioUID, "", ifRevision); err != nil {
+			return err
+		}
+		if err := syncPages(tx, portfolioUID); err != nil {
+			return err
+		}
+		for _, op := range ops {
+			if err := applyOp(tx, portfolioUID, op); err != nil {
+				return err
+			}
+		}
+		// 作品或文本移动到新页码时补全页面
+		if err := syncPages(tx, portfolioUID); err != nil {
+			return err
+		}
+		return publish(seq)
+	})
+	return seq, err
+}
+
+func applyOp(tx *gorm.DB, portfolioUID string, op Op) error {
+	switch op.Type {
+	case OpMoveWork:
+		work := Work{}
+		if err := tx.Where("uid = ? AND project_uid IN (?)", op.UID, portfolioProjects(tx, portfolioUID)).
+			First(&work).Error; err != nil {
+			return err
+		}
+		return tx.Model(&work).Select(workMoveColumns).Updates(&op.Work).Error
+	case OpUpdateText:
+		text := Text{}
+		if err := tx.Where("uid = ? AND project_uid IN (?)", op.UID, portfolioProjects(tx, portfolioUID)).
+			First(&text).Error; err != nil {
+			return err
+		}
+		return tx.Model(&text).Select(textEditColumns).Updates(&op.Text).Error
+	case OpMovePage:
+		var count int64
+		if err := tx.Model(&PortfolioPage{}).Where("portfolio_uid = ?", portfolioUID).Count(&count).Error; err != nil {
+			return err
+		}
+		return movePage(tx, portfolioUID, op.UID, op.To, int(count))
+	case OpAddWork:
+		if err := tx.Where("uid = ? AND portfolio_uid = ?", op.Work.ProjectUID, portfolioUID).
+			First(&Project{}).Error; err != nil {
+			return err
+		}
+		works := []Work{op.Work}
+		works[0].UID = op.UID
+		if err := resolveWorkAssets(tx, works); err != nil {
+			return err
+		}
+		if err := tx.Create(&works).Error; err != nil {
+			return err
+		}
+		if works[0].AssetID != nil {
+			return recountAssetRefs(tx, []uint{*works[0].AssetID})
+		}
+		return nil
+	case OpMoveProject:
+		return moveProject(tx, portfolioUID, op.UID, op.To)
+	case OpDeletePage:
+		return deletePage(tx, portfolioUID, op.UID)
+	}
+	return errors.New("unknown op type: " + string(op.Type))
+}
+
+// moveProject 将项目移动到 to，超出末尾时移到最后，全部项目的 Order 重新从 0 编号
+func moveProject(tx *gorm.DB, portfolioUID, projectUID string, to int) error {
+	projects := []Project{}
+	if err := tx.Select("id", "uid", "order").Where("portfolio_uid = ?", portfolioUID).
+		Order("`order`").Order("id").Find(&projects).Error; err != nil {
+		return err
+	}
+	from := -1
+	for i, project := range projects {
+		if project.UID == projectUID {
+			from = i
+		}
+	}
+	if from < 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if to >= len(projects) {
+		to = len(projects) - 1
+	}
+	moved := projects[from]
+	projects = append(projects[:from], projects[from+1:]...)
+	projects = append(projects[:to], append([]Project{moved}, projects[to:]...)...)
+	for i, project := range projects {
+		if project.Order == i {
+			continue
+		}
+		if err := tx.Model(&Project{}).Where("id = ?", project.ID).Update("order", i).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Snapshot 在同一事务中读取作品集，快照恰好包含版本号不大于 portfolio.Revision 的全部修改
+func (r CollabRepo) Snapshot(ctx context.Context, portfolioUID string) (Portfolio, error) {
+	portfolio := Portfolio{}
+	err := r.mysqlDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Preload("Projects.Works").Preload("Projects.Texts").Preload("Pages", orderPages).Preload("Template.Fonts").
+			Where("uid = ?", portfolioUID).First(&portfolio).Error
+	})
+	return portfolio, err
+}
+
+// CurrentRevision 返回作品集当前的版本号
+func (r CollabRepo) CurrentRevision(ctx context.Context, portfolioUID string) (int64, error) {
+	portfolio := Portfolio{}
+	err := r.mysqlDB.WithContext(ctx).Select("revision").Where("uid = ?", portfolioUID).First(&portfolio).Error
+	return portfolio.Revision, err
+}
+
+// AppendOpLog 记录已广播的操作，只保留最近 collabLogSize 条
+func (r CollabRepo) AppendOpLog(ctx context.Context, portfolioUID string, seq int64, payload []byte) error {
+	key := collabLogKeyPrefix + portfolioUID
+	_, err := r.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, &redis.Z{Score: float64(seq), Member: payload})
+		pipe.ZRemRangeByRank(ctx, key, 0, -collabLogSize-1)
+		pipe.Expire(ctx, key, collabLogTTL)
+		return nil
+	})
+	return err
+}
+
+// GetOpLog 按序号顺序返回序号大于 after 的操作
+func (r CollabRepo) GetOpLog(ctx context.Context, portfolioUID string, after int64) ([]OpLogEntry, error) {
+	zs, err := r.redisClient.ZRangeByScoreWithScores(ctx, collabLogKeyPrefix+portfolioUID, &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(after, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]OpLogEntry, 0, len(zs))
+	for _, z := range zs {
+		member, _ := z.Member.(string)
+		entries = append(entries, OpLogEntry{Seq: int64(z.Score), Payload: []byte(member)})
+	}
+	return entries, nil
+}
+
+// Publish 将消息广播给所有副本上该作品集的连接
+func (r CollabRepo) Publish(ctx context.Context, portfolioUID string, payload []byte) error {
+	return r.redisClient.Publish(ctx, collabChannelPrefix+portfolioUID, payload).Err()
+}
+
+// Subscribe 订阅全部作品集的协同消息，ctx 结束后关闭返回的 channel
+func (r CollabRepo) Subscribe(ctx context.Context) <-chan CollabEvent {
+	events := make(chan CollabEvent, 256)
+	pubsub := r.redisClient.PSubscribe(ctx, collabChannelPrefix+"*")
+	go func() {
+		defer close(events)
+		defer pubsub.Close()
+		ch := pubsub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				select {
+				case events <- CollabEvent{
+					PortfolioUID: strings.TrimPrefix(msg.Channel, collabChannelPrefix),
+					Payload:      []byte(msg.Payload),
+				}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return events
+}
+
+// SetPresence 登记或刷新连接的在线状态
+func (r CollabRepo) SetPresence(ctx context.Context, portfolioUID string, presence Presence) error {
+	value, err := json.Marshal(presence)
+	if err != nil {
+		return err
+	}
+	key := collabPresenceKeyPrefix + portfolioUID
+	_, err = r.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, presence.ConnID, value)
+		pipe.Expire(ctx, key, PresenceTimeout)
+		return nil
+	})
+	return err
+}
+
+func (r CollabRepo) RemovePresence(ctx context.Context, portfolioUID, connID string) error {
+	return r.redisClient.HDel(ctx, collabPresenceKeyPrefix+portfolioUID, connID).Err()
+}
+
+// ListPresence 返回在线的连接，并清理超时未刷新的记录
+func (r CollabRepo) ListPresence(ctx context.Context, portfolioUID string) ([]Presence, error) {
+	key := collabPresenceKeyPrefix + portfolioUID
+	values, err := r.redisClient.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	presences := []Presence{}
+	stale := []string{}
+	now := time.Now()
+	for connID, value := range values {
+		presence := Presence{}
+		if err := json.Unmarshal([]byte(value), &presence); err != nil || now.Sub(presence.LastSeen) > PresenceTimeout {
+			stale = append(stale, connID)
+			continue
+		}
+		presences = append(presences, presence)
+	}
+	if len(stale) > 0 {
+		if err := r.redisClient.HDel(ctx, key, stale...).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return presences, nil
+}
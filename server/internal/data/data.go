@@ -3,9 +3,9 @@ package data
 import (
 	"fmt"
 
+	"github.com/Fl0rencess720/Springboard/internal/conf"
 	"github.com/go-redis/redis/extra/redisotel"
 	"github.com/go-redis/redis/v8"
-	"github.com/spf13/viper"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
@@ -21,24 +21,32 @@ func Init() {
 }
 
 func mysqlInit() {
-	mysqlDB, err := gorm.Open(mysql.Open(fmt.Sprintf("%s:%s@tcp(%s)/springboard?parseTime=True&loc=Local", viper.GetString("MYSQL_USER"), viper.GetString("MYSQL_PASSWORD"), viper.GetString("MYSQL_ADDR"))), &gorm.Config{})
+	cfg := conf.Get().Data.MySQL
+	mysqlDB, err := gorm.Open(mysql.Open(fmt.Sprintf("%s:%s@tcp(%s)/springboard?parseTime=True&loc=Local", cfg.User, cfg.Password, cfg.Addr)), &gorm.Config{})
 	if err != nil {
 		panic("failed to connect mysql")
 	}
-	if err := mysqlDB.AutoMigrate(&AppUser{}, &Portfolio{}, &Work{}, &Feedback{}, &Page{}, &Template{}, &Text{}); err != nil {
+	if err := migrateWorkIdentity(mysqlDB); err != nil {
+		panic("failed to migrate works")
+	}
+	if err := mysqlDB.AutoMigrate(&AppUser{}, &Portfolio{}, &Work{}, &Feedback{}, &Page{}, &Template{}, &Text{}, &Upload{}, &Asset{}, &TemplateFont{}, &PageSlot{}, &PortfolioPage{}, &ShareLink{}, &Review{}, &ReviewComment{}); err != nil {
 		panic("failed to migrate mysql")
 	}
+	if err := migratePageSlots(mysqlDB); err != nil {
+		panic("failed to migrate page slots")
+	}
 	db = mysqlDB
 }
 
 func redisInit() {
+	cfg := conf.Get().Data.Redis
 	redisClient := redis.NewClient(&redis.Options{
-		Addr:         viper.GetString("REDIS_ADDR"),
-		Password:     viper.GetString("REDIS_PASSWORD"),
-		DB:           viper.GetInt("data.redis.db"),
-		DialTimeout:  viper.GetDuration("data.redis.dial_timeout"),
-		WriteTimeout: viper.GetDuration("data.redis.write_timeout"),
-		ReadTimeout:  viper.GetDuration("data.redis.read_timeout"),
+		Addr:         cfg.Addr,
+		Password:     cfg.Password,
+		DB:           cfg.DB,
+		DialTimeout:  cfg.DialTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		ReadTimeout:  cfg.ReadTimeout,
 	})
 	redisClient.AddHook(redisotel.TracingHook{})
 	rdb = redisClient
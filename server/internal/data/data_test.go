@@ -0,0 +1,34 @@
+package data
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/uuid"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// testTx 返回 TEST_MYSQL_DSN 指定的数据库中的事务，测试结束后回滚，未设置时跳过
+func testTx(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_MYSQL_DSN")
+	if dsn == "" {
+		t.Skip("TEST_MYSQL_DSN not set")
+	}
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{DisableForeignKeyConstraintWhenMigrating: true})
+	if err != nil {
+		t.Fatalf("open mysql: %v", err)
+	}
+	if err := db.AutoMigrate(&Portfolio{}, &Project{}, &Work{}, &Text{}, &PortfolioPage{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+	return tx
+}
+
+// testUID 生成带前缀的 uid，避免与库中已有的数据冲突
+func testUID(name string) string {
+	return name + "-" + uuid.New().String()
+}
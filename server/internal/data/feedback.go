@@ -16,10 +16,12 @@ const (
 )
 
 type Feedback struct {
-	UID       string         `json:"uid" gorm:"type:varchar(255)"`
-	Content   string         `json:"content" gorm:"type:varchar(255)"`
-	Timestamp time.Time      `json:"timestamp"`
-	Status    FeedbackStatus `json:"status" gorm:"index;type:varchar(255)"`
+	UID     string `json:"uid" gorm:"type:varchar(255)"`
+	Content string `json:"content" gorm:"type:varchar(255)"`
+	// Attachments 为反馈截图的 oss key
+	Attachments []string       `json:"attachments" gorm:"type:json;serializer:json"`
+	Timestamp   time.Time      `json:"timestamp"`
+	Status      FeedbackStatus `json:"status" gorm:"index;type:varchar(255)"`
 }
 
 type FeedbackRepo struct {
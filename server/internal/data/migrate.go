@@ -0,0 +1,37 @@
+package data
+
+import (
+	"gorm.io/gorm"
+)
+
+// migrateWorkIdentity 作品原先以 oss_key 唯一标识，改为独立的 uid 前需要先为旧数据回填 uid，
+// 并删除 oss_key 上的唯一约束，否则 AutoMigrate 添加唯一索引会失败
+func migrateWorkIdentity(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&Work{}) {
+		return nil
+	}
+	if !m.HasColumn(&Work{}, "uid") {
+		if err := db.Exec("ALTER TABLE works ADD COLUMN uid varchar(255)").Error; err != nil {
+			return err
+		}
+	}
+	if err := db.Exec("UPDATE works SET uid = UUID() WHERE uid IS NULL OR uid = ''").Error; err != nil {
+		return err
+	}
+	// unique 标签生成的约束以列名命名
+	if m.HasIndex(&Work{}, "oss_key") {
+		if err := m.DropIndex(&Work{}, "oss_key"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migratePageSlots 为只有单个矩形空位的旧内容页生成对应的图片空位
+func migratePageSlots(db *gorm.DB) error {
+	return db.Exec("INSERT INTO page_slots (uid, page_uid, `order`, type, margin_top, margin_left, size) "+
+		"SELECT UUID(), p.uid, 0, ?, p.margin_top, p.margin_left, p.size FROM pages p "+
+		"WHERE p.is_content_page AND p.size <> '' "+
+		"AND NOT EXISTS (SELECT 1 FROM page_slots s WHERE s.page_uid = p.uid)", SlotImage).Error
+}
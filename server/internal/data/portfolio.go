@@ -3,9 +3,11 @@ package data
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
@@ -18,12 +20,19 @@ type Portfolio struct {
 	Projects    []Project `gorm:"foreignKey:PortfolioUID;references:UID" json:"projects"`
 	TemplateUID string    `gorm:"index;type:varchar(255)" json:"template_uid"`
 	Template    Template  `gorm:"foreignKey:TemplateUID;references:UID" json:"template"`
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// Pages 按页码排序
+	Pages []PortfolioPage `gorm:"foreignKey:PortfolioUID;references:UID" json:"pages"`
+	// Revision 为版本号，作品集的每次修改都会加一，用于检测并发保存
+	Revision  int64 `gorm:"default:0" json:"revision"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 type Work struct {
-	ID         uint   `gorm:"primarykey"`
-	OSSKey     string `gorm:"unique;index;type:varchar(255)" json:"oss_key"`
+	ID  uint   `gorm:"primarykey"`
+	UID string `gorm:"unique;index;type:varchar(255)" json:"uid"`
+	// 同一资源可以出现在多个作品集中，OSSKey 不再唯一
+	OSSKey     string `gorm:"index;type:varchar(255)" json:"oss_key"`
+	AssetID    *uint  `gorm:"index" json:"asset_id"`
 	ProjectUID string `gorm:"type:varchar(255)" json:"project_uid"`
 	// Size 格式为 axb 例如 1920x1080
 	Size       string  `gorm:"type:varchar(255)" json:"size"`
@@ -31,33 +40,89 @@ type Work struct {
 	MarginLeft string  `gorm:"type:varchar(255)" json:"margin_left"`
 	Scale      float64 `gorm:"type:double;default:1.0" json:"scale"` // 1.0 表示 不缩放
 	PageNum    int     `gorm:"column:page;type:int" json:"page_num"`
-	CreatedAt  time.Time
-	UpdatedAt  time.Time
+	// SlotUID 为作品所在的图片空位，为空表示自由摆放
+	SlotUID   string    `gorm:"index;type:varchar(255)" json:"slot_uid"`
+	Transform Transform `gorm:"embedded" json:"transform"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 type Text struct {
 	ID         uint   `gorm:"primarykey"`
 	UID        string `gorm:"unique;index;type:varchar(255)" json:"uid"`
 	ProjectUID string `gorm:"type:varchar(255)" json:"project_uid"`
-	Content    string `gorm:"type:varchar(255)" json:"content"`
-	FontSize   string `gorm:"type:varchar(255)" json:"font_size"`
-	FontColor  string `gorm:"type:char(6);default:'000000'" json:"font_color"`
-	Size       string `gorm:"type:varchar(255)" json:"size"` // 文本框大小
-	MarginTop  string `gorm:"type:varchar(255)" json:"margin_top"`
-	MarginLeft string `gorm:"type:varchar(255)" json:"margin_left"`
-	PageNum    int    `gorm:"column:page;type:int" json:"page_num"`
+	// Content 为纯文本，存在 Runs 时为各片段文本的拼接
+	Content    string    `gorm:"type:text" json:"content"`
+	Runs       []TextRun `gorm:"type:json;serializer:json" json:"runs"`
+	FontSize   string    `gorm:"type:varchar(255)" json:"font_size"`
+	FontColor  string    `gorm:"type:char(6);default:'000000'" json:"font_color"`
+	FontFamily string    `gorm:"type:varchar(255)" json:"font_family"` // 为空时使用模板默认字体
+	FontWeight int       `gorm:"type:int;default:400" json:"font_weight"`
+	Align      string    `gorm:"type:varchar(16);default:'left'" json:"align"`
+	// LineHeight 为字号的倍数，LetterSpacing 单位为像素
+	LineHeight    float64 `gorm:"type:double;default:1.2" json:"line_height"`
+	LetterSpacing float64 `gorm:"type:double;default:0" json:"letter_spacing"`
+	// 文本不支持裁剪，Transform.Crop 始终为空
+	Transform  Transform `gorm:"embedded" json:"transform"`
+	Size       string    `gorm:"type:varchar(255)" json:"size"` // 文本框大小
+	MarginTop  string    `gorm:"type:varchar(255)" json:"margin_top"`
+	MarginLeft string    `gorm:"type:varchar(255)" json:"margin_left"`
+	PageNum    int       `gorm:"column:page;type:int" json:"page_num"`
+	// SlotUID 为文本所在的文本或说明空位，为空表示自由摆放
+	SlotUID string `gorm:"index;type:varchar(255)" json:"slot_uid"`
+}
+
+// Transform 为作品与文本共用的变换，按裁剪、翻转、旋转的顺序应用，ZIndex 决定同一页内的层叠顺序
+type Transform struct {
+	Crop     *CropRect `gorm:"type:json;serializer:json" json:"crop"`
+	Rotation float64   `gorm:"type:double;default:0" json:"rotation"` // 顺时针角度
+	FlipX    bool      `gorm:"type:bool;default:false" json:"flip_x"`
+	FlipY    bool      `gorm:"type:bool;default:false" json:"flip_y"`
+	ZIndex   int       `gorm:"type:int;default:0" json:"z_index"`
+	Opacity  float64   `gorm:"type:double;default:1" json:"opacity"`
+}
+
+// CropRect 为裁剪框，坐标与宽高均为相对原图宽高的比例
+type CropRect struct {
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
 }
+
+// TextRun 为富文本中样式相同的一段文字，未设置的样式继承文本框
+type TextRun struct {
+	Text          string `json:"text"`
+	FontFamily    string `json:"font_family,omitempty"`
+	FontSize      string `json:"font_size,omitempty"`
+	FontColor     string `json:"font_color,omitempty"`
+	FontWeight    int    `json:"font_weight,omitempty"`
+	Italic        bool   `json:"italic,omitempty"`
+	Underline     bool   `json:"underline,omitempty"`
+	Strikethrough bool   `json:"strikethrough,omitempty"`
+}
+
 type Template struct {
 	ID         uint   `gorm:"primarykey"`
 	UID        string `gorm:"unique;index;type:varchar(255)" json:"uid"`
 	Name       string `gorm:"type:varchar(255)" json:"name"`
 	FontOSSKey string `gorm:"type:varchar(255)" json:"font_oss_key"`
 	Pages      []Page `gorm:"foreignKey:TemplateUID;references:UID" json:"pages"`
-	CreatedAt  time.Time
+	// Fonts 为模板提供的字体，文本只能使用所属模板的字体
+	Fonts     []TemplateFont `gorm:"foreignKey:TemplateUID;references:UID" json:"fonts"`
+	CreatedAt time.Time
+}
+
+type TemplateFont struct {
+	ID          uint   `gorm:"primarykey"`
+	UID         string `gorm:"unique;index;type:varchar(255)" json:"uid"`
+	TemplateUID string `gorm:"index;type:varchar(255)" json:"template_uid"`
+	Family      string `gorm:"type:varchar(255)" json:"family"`
+	OSSKey      string `gorm:"type:varchar(255)" json:"oss_key"`
 }
 
 // 模板中的固有页面
 // oss key 格式：template_5_4.svg，其中5为模板id，4为页面在模板中的顺序
-// margin, size表示矩形空位，用于放 work 的位置
+// margin, size表示矩形空位，用于放 work 的位置，已由 Slots 取代，保留供旧版客户端使用
 type Page struct {
 	ID            uint   `gorm:"primarykey"`
 	UID           string `gorm:"unique;index;type:varchar(255)" json:"uid"`
@@ -71,6 +136,32 @@ type Page struct {
 	Size          string   `gorm:"type:varchar(255)" json:"size"`     // 图片容纳框大小
 	BkgSize       string   `gorm:"type:varchar(255)" json:"bkg_size"` // 背景图大小
 	IsContentPage bool     `gorm:"type:bool" json:"is_content_page"`
+	// Slots 为页面中按顺序排列的空位
+	Slots []PageSlot `gorm:"foreignKey:PageUID;references:UID" json:"slots"`
+}
+
+type SlotType string
+
+const (
+	SlotImage   SlotType = "image"
+	SlotText    SlotType = "text"
+	SlotCaption SlotType = "caption"
+)
+
+// PageSlot 为页面中的一个空位，margin、size 的格式与 Page 相同
+type PageSlot struct {
+	ID         uint     `gorm:"primarykey"`
+	UID        string   `gorm:"unique;index;type:varchar(255)" json:"uid"`
+	PageUID    string   `gorm:"index;type:varchar(255)" json:"page_uid"`
+	Order      int      `gorm:"type:int" json:"order"`
+	Type       SlotType `gorm:"type:varchar(16)" json:"type"`
+	MarginTop  string   `gorm:"type:varchar(255)" json:"margin_top"`
+	MarginLeft string   `gorm:"type:varchar(255)" json:"margin_left"`
+	Size       string   `gorm:"type:varchar(255)" json:"size"`
+	// Policy 为图片空位的默认排版方式，为空时为 fit
+	Policy string `gorm:"type:varchar(16)" json:"policy"`
+	// MaxLength 为文本与说明空位的最大字数，0 表示不限
+	MaxLength int `gorm:"type:int;default:0" json:"max_length"`
 }
 type Project struct {
 	ID           uint   `gorm:"primarykey"`
@@ -98,7 +189,7 @@ func NewPortfolioRepo(mysqlDB *gorm.DB, redisClient *redis.Client) PortfolioRepo
 
 func (r PortfolioRepo) GetAllTemplatesFromDB(ctx context.Context) ([]Template, error) {
 	templates := []Template{}
-	if err := r.mysqlDB.Preload("Pages").Find(&templates).Error; err != nil {
+	if err := r.mysqlDB.Preload("Pages.Slots", orderSlots).Preload("Fonts").Find(&templates).Error; err != nil {
 		return nil, err
 	}
 	return templates, nil
@@ -106,14 +197,24 @@ func (r PortfolioRepo) GetAllTemplatesFromDB(ctx context.Context) ([]Template, e
 
 func (r PortfolioRepo) GetTemplatesFromDB(ctx context.Context, uids []string) ([]Template, error) {
 	templates := []Template{}
-	if err := r.mysqlDB.Preload("Pages").Where("uid IN ?", uids).Find(&templates).Error; err != nil {
+	if err := r.mysqlDB.Preload("Pages.Slots", orderSlots).Preload("Fonts").Where("uid IN ?", uids).Find(&templates).Error; err != nil {
 		return nil, err
 	}
 	return templates, nil
 }
 
+// 缓存的结构随模型变化时需要更换 key，避免读到缺少新字段的旧数据
+const (
+	templatesCacheKey        = "templates:v3"
+	portfoliosCacheKeyPrefix = "portfolios:v5:"
+)
+
+func orderSlots(db *gorm.DB) *gorm.DB {
+	return db.Order("`order`")
+}
+
 func (r PortfolioRepo) GetAllTemplatesFromRedis(ctx context.Context) ([]Template, error) {
-	data, err := r.redisClient.Get(ctx, "templates").Bytes()
+	data, err := r.redisClient.Get(ctx, templatesCacheKey).Bytes()
 	if err != nil {
 		return nil, err
 	}
@@ -126,7 +227,7 @@ func (r PortfolioRepo) GetAllTemplatesFromRedis(ctx context.Context) ([]Template
 
 func (r PortfolioRepo) GetTemplateByUIDFromDB(ctx context.Context, uid string) (Template, error) {
 	template := Template{}
-	if err := r.mysqlDB.Preload("Pages").Where("uid = ?", uid).First(&template).Error; err != nil {
+	if err := r.mysqlDB.Preload("Pages.Slots", orderSlots).Preload("Fonts").Where("uid = ?", uid).First(&template).Error; err != nil {
 		return Template{}, err
 	}
 	return template, nil
@@ -137,7 +238,7 @@ func (r PortfolioRepo) SaveAllTemplatesToRedis(ctx context.Context, templates []
 	if err != nil {
 		return err
 	}
-	if err := r.redisClient.Set(ctx, "templates", templatesJson, 0).Err(); err != nil {
+	if err := r.redisClient.Set(ctx, templatesCacheKey, templatesJson, 0).Err(); err != nil {
 		return err
 	}
 	return nil
@@ -175,14 +276,14 @@ func (r PortfolioRepo) IncreTemplateScore(ctx context.Context, uid string) error
 
 func (r PortfolioRepo) GetPortfoliosFromDB(ctx context.Context, openid string) ([]Portfolio, error) {
 	portfolios := []Portfolio{}
-	if err := r.mysqlDB.Preload("Projects.Works").Preload("Template").Where("openid = ?", openid).Find(&portfolios).Error; err != nil {
+	if err := r.mysqlDB.Preload("Projects.Works").Preload("Projects.Texts").Preload("Pages", orderPages).Preload("Template.Fonts").Where("openid = ?", openid).Find(&portfolios).Error; err != nil {
 		return nil, err
 	}
 	return portfolios, nil
 }
 
 func (r PortfolioRepo) GetPortfoliosFromRedis(ctx context.Context, openid string) ([]Portfolio, error) {
-	data, err := r.redisClient.Get(ctx, "portfolios:"+openid).Bytes()
+	data, err := r.redisClient.Get(ctx, portfoliosCacheKeyPrefix+openid).Bytes()
 	if err != nil {
 		return nil, err
 	}
@@ -195,73 +296,392 @@ func (r PortfolioRepo) GetPortfoliosFromRedis(ctx context.Context, openid string
 
 func (r PortfolioRepo) GetPortfolioByUIDFromDB(ctx context.Context, uid string) (Portfolio, error) {
 	portfolio := Portfolio{}
-	if err := r.mysqlDB.Preload("Projects.Works").Preload("Template").Where("uid = ?", uid).First(&portfolio).Error; err != nil {
+	if err := r.mysqlDB.Preload("Projects.Works").Preload("Projects.Texts").Preload("Pages", orderPages).Preload("Template.Fonts").Where("uid = ?", uid).First(&portfolio).Error; err != nil {
 		return Portfolio{}, err
 	}
 	return portfolio, nil
 }
 
+// IsActiveReviewer 判断用户是否为作品集未被撤销的评审人
+func (r PortfolioRepo) IsActiveReviewer(ctx context.Context, portfolioUID, openid string) (bool, error) {
+	var count int64
+	if err := r.mysqlDB.WithContext(ctx).Model(&Review{}).
+		Where("portfolio_uid = ? AND reviewer_openid = ? AND status <> ?", portfolioUID, openid, ReviewRevoked).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func (r PortfolioRepo) SavePortfoliosToRedis(ctx context.Context, portfolios []Portfolio, openid string) error {
 	portfoliosJson, err := json.Marshal(portfolios)
 	if err != nil {
 		return err
 	}
-	if err := r.redisClient.Set(ctx, "portfolios:"+openid, portfoliosJson, 0).Err(); err != nil {
+	if err := r.redisClient.Set(ctx, portfoliosCacheKeyPrefix+openid, portfoliosJson, 0).Err(); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r PortfolioRepo) SavePortfolioToDB(ctx context.Context, portfolio Portfolio) error {
-	projects := portfolio.Projects
-	works := []Work{}
-	for _, project := range projects {
-		for _, work := range project.Works {
-			work.ProjectUID = project.UID
-			works = append(works, work)
-		}
+func (r PortfolioRepo) GetAssetsByIDs(ctx context.Context, ids []uint) ([]Asset, error) {
+	assets := []Asset{}
+	if len(ids) == 0 {
+		return assets, nil
 	}
-	texts := []Text{}
-	for _, project := range projects {
-		for _, text := range project.Texts {
-			text.ProjectUID = project.UID
-			texts = append(texts, text)
-		}
+	if err := r.mysqlDB.WithContext(ctx).Where("id IN ?", ids).Find(&assets).Error; err != nil {
+		return nil, err
+	}
+	return assets, nil
+}
+
+// FilterUsableWorkKeys 返回其中可以放入该用户作品的对象：自己上传的对象、自己上传的内容去重后的资源以及模板公共资源
+func (r PortfolioRepo) FilterUsableWorkKeys(ctx context.Context, openid string, ossKeys []string) ([]string, error) {
+	keys := []string{}
+	if len(ossKeys) == 0 {
+		return keys, nil
+	}
+	db := r.mysqlDB.WithContext(ctx)
+	if err := db.Model(&Upload{}).
+		Where("oss_key IN ? AND openid = ?", ossKeys, openid).Pluck("oss_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	// 保存时作品改为引用资源的对象，资源的对象可能由其他用户上传，持有相同内容的上传即可使用
+	assets := []string{}
+	if err := db.Model(&Asset{}).Joins("JOIN uploads ON uploads.asset_id = assets.id").
+		Where("assets.oss_key IN ? AND uploads.openid = ?", ossKeys, openid).
+		Distinct().Pluck("assets.oss_key", &assets).Error; err != nil {
+		return nil, err
+	}
+	templates, err := filterTemplateAssetKeys(db, ossKeys)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(keys, assets...), templates...), nil
+}
+
+// GetImageSizes 返回该用户上传且已处理完成的图片的像素尺寸
+func (r PortfolioRepo) GetImageSizes(ctx context.Context, openid string, ossKeys []string) ([]Upload, error) {
+	uploads := []Upload{}
+	if len(ossKeys) == 0 {
+		return uploads, nil
 	}
+	if err := r.mysqlDB.WithContext(ctx).Select("oss_key", "width", "height").
+		Where("oss_key IN ? AND openid = ? AND process_status = ?", ossKeys, openid, ProcessDone).
+		Find(&uploads).Error; err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+// PublishCollabEvent 向所有副本上该作品集的协同连接广播消息
+func (r PortfolioRepo) PublishCollabEvent(ctx context.Context, portfolioUID string, payload []byte) error {
+	return r.redisClient.Publish(ctx, collabChannelPrefix+portfolioUID, payload).Err()
+}
+
+// DeletePortfoliosFromRedis 作品集保存后清除该用户的缓存
+func (r PortfolioRepo) DeletePortfoliosFromRedis(ctx context.Context, openid string) error {
+	return r.redisClient.Del(ctx, portfoliosCacheKeyPrefix+openid).Err()
+}
+
+// ErrRevisionConflict 为客户端提交的版本号与作品集当前版本不一致
+var ErrRevisionConflict = errors.New("portfolio revision conflict")
+
+// ErrPortfolioNotOwned 为作品集属于其他用户
+var ErrPortfolioNotOwned = errors.New("portfolio not owned")
+
+// bumpRevision 锁住作品集行并将版本号加一，返回新版本号；
+// owner 不为空且不是作品集所有者时返回 ErrPortfolioNotOwned，先于版本号检查，避免向其他用户暴露版本信息；
+// ifRevision 不为空且与当前版本不一致时返回 ErrRevisionConflict，作品集不存在时返回 gorm.ErrRecordNotFound
+func bumpRevision(tx *gorm.DB, portfolioUID, owner string, ifRevision *int64) (int64, error) {
+	current := Portfolio{}
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "openid", "revision").
+		Where("uid = ?", portfolioUID).First(&current).Error; err != nil {
+		return 0, err
+	}
+	if owner != "" && current.Openid != owner {
+		return 0, ErrPortfolioNotOwned
+	}
+	if ifRevision != nil && *ifRevision != current.Revision {
+		return 0, ErrRevisionConflict
+	}
+	revision := current.Revision + 1
+	return revision, tx.Model(&current).UpdateColumn("revision", revision).Error
+}
+
+// SavePortfolioToDB 保存整个作品集并返回新版本号，ifRevision 为空时不检查版本，新建的作品集版本号为 1。
+// 作品集已存在且不属于 portfolio.Openid 时返回 ErrPortfolioNotOwned；项目、作品与文本最终使用的 uid 会写回 portfolio。
+// pageTemplates 以页码为 key，在补全页面后写入对应页面使用的模板页面
+func (r PortfolioRepo) SavePortfolioToDB(ctx context.Context, portfolio *Portfolio, pageTemplates map[int]string, ifRevision *int64) (int64, error) {
 	err := r.mysqlDB.Transaction(func(tx *gorm.DB) error {
-		if err := tx.Clauses(clause.OnConflict{
+		revision, err := bumpRevision(tx, portfolio.UID, portfolio.Openid, ifRevision)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			revision = 1
+		} else if err != nil {
+			return err
+		}
+		if err := claimUIDs(tx, portfolio); err != nil {
+			return err
+		}
+		projects := portfolio.Projects
+		works := []Work{}
+		texts := []Text{}
+		for _, project := range projects {
+			for _, work := range project.Works {
+				work.ProjectUID = project.UID
+				works = append(works, work)
+			}
+			for _, text := range project.Texts {
+				text.ProjectUID = project.UID
+				texts = append(texts, text)
+			}
+		}
+		// Pages 为空时保留已有页面，只补全缺少的页码
+		pages := portfolio.Pages
+		portfolio.Revision = revision
+		// 关联的行在下面逐一写入，作品需要先关联资源；所有者与创建时间不随保存改变
+		if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "uid"}},
-			UpdateAll: true,
-		}).Create(&portfolio).Error; err != nil {
+			DoUpdates: clause.AssignmentColumns([]string{"title", "template_uid", "revision", "updated_at"}),
+		}).Create(portfolio).Error; err != nil {
 			return err
 		}
-		if err := tx.Clauses(clause.OnConflict{
+		if err := tx.Omit(clause.Associations).Clauses(clause.OnConflict{
 			Columns:   []clause.Column{{Name: "uid"}},
 			UpdateAll: true,
 		}).Create(&projects).Error; err != nil {
 			return err
 		}
-
-		if len(works) > 0 {
+		if err := upsertWorks(tx, works); err != nil {
+			return err
+		}
+		if len(texts) > 0 {
 			if err := tx.Clauses(clause.OnConflict{
-				Columns:   []clause.Column{{Name: "oss_key"}},
+				Columns:   []clause.Column{{Name: "uid"}},
 				UpdateAll: true,
-			}).Create(&works).Error; err != nil {
+			}).Create(&texts).Error; err != nil {
 				return err
 			}
 		}
-		if len(texts) > 0 {
+		if len(pages) > 0 {
 			if err := tx.Clauses(clause.OnConflict{
 				Columns:   []clause.Column{{Name: "uid"}},
 				UpdateAll: true,
-			}).Create(&texts).Error; err != nil {
+			}).Create(&pages).Error; err != nil {
 				return err
 			}
 		}
-		return nil
+		if err := syncPages(tx, portfolio.UID); err != nil {
+			return err
+		}
+		return assignTemplatePages(tx, portfolio.UID, pageTemplates)
 	})
 	if err != nil {
+		return 0, err
+	}
+	return portfolio.Revision, nil
+}
+
+// claimUIDs 确定整体保存时项目、作品与文本的 uid。
+// 不属于该作品集或在请求中重复出现的 uid 视为新增并重新生成，客户端不能借此覆盖其他作品集的数据；
+// 作品 uid 为空时按 (project_uid, oss_key) 沿用已有的行，其余为空的 uid 同样重新生成
+func claimUIDs(tx *gorm.DB, portfolio *Portfolio) error {
+	owned := map[string]struct{}{}
+	projectUIDs := []string{}
+	if err := tx.Model(&Project{}).Where("portfolio_uid = ?", portfolio.UID).Pluck("uid", &projectUIDs).Error; err != nil {
 		return err
 	}
+	existingWorks := []Work{}
+	textUIDs := []string{}
+	if len(projectUIDs) > 0 {
+		if err := tx.Select("uid", "project_uid", "oss_key").Where("project_uid IN ?", projectUIDs).
+			Find(&existingWorks).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&Text{}).Where("project_uid IN ?", projectUIDs).Pluck("uid", &textUIDs).Error; err != nil {
+			return err
+		}
+	}
+	for _, uid := range projectUIDs {
+		owned[uid] = struct{}{}
+	}
+	for _, uid := range textUIDs {
+		owned[uid] = struct{}{}
+	}
+	byKey := map[[2]string][]string{}
+	for _, work := range existingWorks {
+		owned[work.UID] = struct{}{}
+		key := [2]string{work.ProjectUID, work.OSSKey}
+		byKey[key] = append(byKey[key], work.UID)
+	}
+
+	seen := map[string]struct{}{}
+	claim := func(uid *string) {
+		_, ok := owned[*uid]
+		if _, dup := seen[*uid]; !ok || dup {
+			*uid = uuid.New().String()
+		}
+		seen[*uid] = struct{}{}
+	}
+	renamed := map[string]string{}
+	for i := range portfolio.Projects {
+		project := &portfolio.Projects[i]
+		project.PortfolioUID = portfolio.UID
+		old := project.UID
+		claim(&project.UID)
+		if old != "" && old != project.UID {
+			renamed[old] = project.UID
+		}
+		for j := range project.Works {
+			project.Works[j].ProjectUID = project.UID
+			if project.Works[j].UID != "" {
+				claim(&project.Works[j].UID)
+			}
+		}
+		for j := range project.Texts {
+			project.Texts[j].ProjectUID = project.UID
+			claim(&project.Texts[j].UID)
+		}
+	}
+	// uid 为空的作品最后处理，避免沿用的行与请求中显式给出的 uid 重复
+	for i := range portfolio.Projects {
+		project := &portfolio.Projects[i]
+		for j := range project.Works {
+			work := &project.Works[j]
+			if work.UID != "" {
+				continue
+			}
+			for _, uid := range byKey[[2]string{project.UID, work.OSSKey}] {
+				if _, used := seen[uid]; !used {
+					work.UID = uid
+					break
+				}
+			}
+			claim(&work.UID)
+		}
+	}
+	for i := range portfolio.Pages {
+		if uid, ok := renamed[portfolio.Pages[i].ProjectUID]; ok {
+			portfolio.Pages[i].ProjectUID = uid
+		}
+	}
 	return nil
 }
+
+// upsertWorks 按 uid 写入作品并重新统计新旧资源的引用数
+func upsertWorks(tx *gorm.DB, works []Work) error {
+	if len(works) == 0 {
+		return nil
+	}
+	if err := resolveWorkAssets(tx, works); err != nil {
+		return err
+	}
+	// 作品更换资源时，旧资源的引用数同样需要重新统计
+	assetIDs := []uint{}
+	uids := make([]string, 0, len(works))
+	for _, work := range works {
+		uids = append(uids, work.UID)
+	}
+	if err := tx.Model(&Work{}).Where("uid IN ? AND asset_id IS NOT NULL", uids).
+		Pluck("asset_id", &assetIDs).Error; err != nil {
+		return err
+	}
+	for _, work := range works {
+		if work.AssetID != nil {
+			assetIDs = append(assetIDs, *work.AssetID)
+		}
+	}
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "uid"}},
+		UpdateAll: true,
+	}).Create(&works).Error; err != nil {
+		return err
+	}
+	return recountAssetRefs(tx, assetIDs)
+}
+
+// PortfolioDiff 为增量保存的内容，只写入发生变化的行
+type PortfolioDiff struct {
+	// Title 为空时不修改标题
+	Title *string
+	// Projects 只写入项目本身，其中的作品与文本由 Works、Texts 给出
+	Projects []Project
+	Works    []Work
+	Texts    []Text
+
+	DeletedProjects []string
+	DeletedWorks    []string
+	DeletedTexts    []string
+}
+
+// PatchPortfolioToDB 在同一事务中写入 diff 并返回新版本号，删除只作用于属于该作品集的行；
+// ifRevision 不为空且与当前版本不一致时返回 ErrRevisionConflict
+func (r PortfolioRepo) PatchPortfolioToDB(ctx context.Context, portfolioUID string, diff PortfolioDiff, ifRevision *int64) (int64, error) {
+	var revision int64
+	err := r.mysqlDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if revision, err = bumpRevision(tx, portfolioUID, "", ifRevision); err != nil {
+			return err
+		}
+		if diff.Title != nil {
+			if err := tx.Model(&Portfolio{}).Where("uid = ?", portfolioUID).Update("title", *diff.Title).Error; err != nil {
+				return err
+			}
+		}
+		// 先删除再写入，作品与文本可能移动到同一批新建的项目中
+		assetIDs := []uint{}
+		if len(diff.DeletedWorks) > 0 {
+			if err := tx.Model(&Work{}).
+				Where("uid IN ? AND project_uid IN (?) AND asset_id IS NOT NULL", diff.DeletedWorks, portfolioProjects(tx, portfolioUID)).
+				Pluck("asset_id", &assetIDs).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("uid IN ? AND project_uid IN (?)", diff.DeletedWorks, portfolioProjects(tx, portfolioUID)).
+				Delete(&Work{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(diff.DeletedTexts) > 0 {
+			if err := tx.Where("uid IN ? AND project_uid IN (?)", diff.DeletedTexts, portfolioProjects(tx, portfolioUID)).
+				Delete(&Text{}).Error; err != nil {
+				return err
+			}
+		}
+		if len(diff.DeletedProjects) > 0 {
+			if err := tx.Where("uid IN ? AND portfolio_uid = ?", diff.DeletedProjects, portfolioUID).
+				Delete(&Project{}).Error; err != nil {
+				return err
+			}
+			// 页面保留，不再属于被删除的项目
+			if err := tx.Model(&PortfolioPage{}).
+				Where("portfolio_uid = ? AND project_uid IN ?", portfolioUID, diff.DeletedProjects).
+				Update("project_uid", "").Error; err != nil {
+				return err
+			}
+		}
+		if err := recountAssetRefs(tx, assetIDs); err != nil {
+			return err
+		}
+		if len(diff.Projects) > 0 {
+			if err := tx.Omit("Works", "Texts").Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "uid"}},
+				UpdateAll: true,
+			}).Create(&diff.Projects).Error; err != nil {
+				return err
+			}
+		}
+		if err := upsertWorks(tx, diff.Works); err != nil {
+			return err
+		}
+		if len(diff.Texts) > 0 {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "uid"}},
+				UpdateAll: true,
+			}).Create(&diff.Texts).Error; err != nil {
+				return err
+			}
+		}
+		return syncPages(tx, portfolioUID)
+	})
+	return revision, err
+}
@@ -0,0 +1,298 @@
+package data
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+type PageKind string
+
+const (
+	PageCover   PageKind = "cover"
+	PageDivider PageKind = "divider"
+	PageContent PageKind = "content"
+)
+
+// PortfolioPage 为作品集中的一页，作品与文本通过 PageNum 与 Number 对应
+// Number 从 0 开始连续编号，增删移动页面时由服务端在同一事务中重新编号
+type PortfolioPage struct {
+	ID           uint     `gorm:"primarykey"`
+	UID          string   `gorm:"unique;index;type:varchar(255)" json:"uid"`
+	PortfolioUID string   `gorm:"index;type:varchar(255)" json:"portfolio_uid"`
+	Number       int      `gorm:"type:int" json:"page_num"`
+	Kind         PageKind `gorm:"type:varchar(16);default:'content'" json:"kind"`
+	// TemplatePageUID 为使用的模板页面，由旧数据补全的页面为空
+	TemplatePageUID string `gorm:"type:varchar(255)" json:"template_page_uid"`
+	// ProjectUID 为页面所属项目，封面等不属于项目的页面为空
+	ProjectUID string `gorm:"type:varchar(255)" json:"project_uid"`
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+func orderPages(db *gorm.DB) *gorm.DB {
+	return db.Order("number")
+}
+
+// portfolioProjects 为作品集下全部项目 uid 的子查询
+func portfolioProjects(tx *gorm.DB, portfolioUID string) *gorm.DB {
+	return tx.Model(&Project{}).Select("uid").Where("portfolio_uid = ?", portfolioUID)
+}
+
+// syncPages 为作品与文本用到但尚未登记的页码补全页面，保证页面从 0 开始连续
+func syncPages(tx *gorm.DB, portfolioUID string) error {
+	var count int64
+	if err := tx.Model(&PortfolioPage{}).Where("portfolio_uid = ?", portfolioUID).Count(&count).Error; err != nil {
+		return err
+	}
+	var maxWork, maxText *int
+	if err := tx.Model(&Work{}).Select("MAX(page)").
+		Where("project_uid IN (?)", portfolioProjects(tx, portfolioUID)).Scan(&maxWork).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&Text{}).Select("MAX(page)").
+		Where("project_uid IN (?)", portfolioProjects(tx, portfolioUID)).Scan(&maxText).Error; err != nil {
+		return err
+	}
+	last := -1
+	for _, m := range []*int{maxWork, maxText} {
+		if m != nil && *m > last {
+			last = *m
+		}
+	}
+	pages := []PortfolioPage{}
+	for number := int(count); number <= last; number++ {
+		pages = append(pages, PortfolioPage{
+			UID:          uuid.New().String(),
+			PortfolioUID: portfolioUID,
+			Number:       number,
+			Kind:         PageContent,
+		})
+	}
+	if len(pages) == 0 {
+		return nil
+	}
+	return tx.Create(&pages).Error
+}
+
+// assignTemplatePages 记录页面使用的模板页面，pageTemplates 以页码为 key
+func assignTemplatePages(tx *gorm.DB, portfolioUID string, pageTemplates map[int]string) error {
+	for number, templatePageUID := range pageTemplates {
+		if err := tx.Model(&PortfolioPage{}).Where("portfolio_uid = ? AND number = ?", portfolioUID, number).
+			Update("template_page_uid", templatePageUID).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shiftPages 将页码在 [from, to] 内的页面及其作品、文本的页码加上 delta
+func shiftPages(tx *gorm.DB, portfolioUID string, from, to, delta int) error {
+	if err := tx.Model(&PortfolioPage{}).
+		Where("portfolio_uid = ? AND number BETWEEN ? AND ?", portfolioUID, from, to).
+		Update("number", gorm.Expr("number + ?", delta)).Error; err != nil {
+		return err
+	}
+	for _, model := range []any{&Work{}, &Text{}} {
+		if err := tx.Model(model).
+			Where("project_uid IN (?) AND page BETWEEN ? AND ?", portfolioProjects(tx, portfolioUID), from, to).
+			Update("page", gorm.Expr("page + ?", delta)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listPages(tx *gorm.DB, portfolioUID string) ([]PortfolioPage, error) {
+	pages := []PortfolioPage{}
+	if err := orderPages(tx).Where("portfolio_uid = ?", portfolioUID).Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	return pages, nil
+}
+
+// pageTx 在事务中补全页面后执行 fn，返回重新编号后的全部页面与作品集的新版本号；
+// ifRevision 不为空且与当前版本不一致时返回 ErrRevisionConflict
+func (r PortfolioRepo) pageTx(ctx context.Context, portfolioUID string, ifRevision *int64, fn func(tx *gorm.DB, count int) error) ([]PortfolioPage, int64, error) {
+	pages := []PortfolioPage{}
+	var revision int64
+	err := r.mysqlDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		if revision, err = bumpRevision(tx, portfolioUID, "", ifRevision); err != nil {
+			return err
+		}
+		if err := syncPages(tx, portfolioUID); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&PortfolioPage{}).Where("portfolio_uid = ?", portfolioUID).Count(&count).Error; err != nil {
+			return err
+		}
+		if err := fn(tx, int(count)); err != nil {
+			return err
+		}
+		pages, err = listPages(tx, portfolioUID)
+		return err
+	})
+	return pages, revision, err
+}
+
+func findPage(tx *gorm.DB, portfolioUID, pageUID string) (PortfolioPage, error) {
+	page := PortfolioPage{}
+	err := tx.Where("portfolio_uid = ? AND uid = ?", portfolioUID, pageUID).First(&page).Error
+	return page, err
+}
+
+// InsertPortfolioPage 在 page.Number 处插入空白页，超出范围时追加到末尾
+func (r PortfolioRepo) InsertPortfolioPage(ctx context.Context, page PortfolioPage, ifRevision *int64) ([]PortfolioPage, int64, error) {
+	return r.pageTx(ctx, page.PortfolioUID, ifRevision, func(tx *gorm.DB, count int) error {
+		if page.Number > count {
+			page.Number = count
+		}
+		if err := shiftPages(tx, page.PortfolioUID, page.Number, math.MaxInt32, 1); err != nil {
+			return err
+		}
+		return tx.Create(&page).Error
+	})
+}
+
+// DuplicatePortfolioPage 复制页面及其中的作品与文本，副本插入在原页面之后
+func (r PortfolioRepo) DuplicatePortfolioPage(ctx context.Context, portfolioUID, pageUID string, ifRevision *int64) ([]PortfolioPage, int64, error) {
+	return r.pageTx(ctx, portfolioUID, ifRevision, func(tx *gorm.DB, count int) error {
+		page, err := findPage(tx, portfolioUID, pageUID)
+		if err != nil {
+			return err
+		}
+		works := []Work{}
+		if err := tx.Where("project_uid IN (?) AND page = ?", portfolioProjects(tx, portfolioUID), page.Number).
+			Find(&works).Error; err != nil {
+			return err
+		}
+		texts := []Text{}
+		if err := tx.Where("project_uid IN (?) AND page = ?", portfolioProjects(tx, portfolioUID), page.Number).
+			Find(&texts).Error; err != nil {
+			return err
+		}
+		if err := shiftPages(tx, portfolioUID, page.Number+1, math.MaxInt32, 1); err != nil {
+			return err
+		}
+
+		page.ID, page.UID, page.Number = 0, uuid.New().String(), page.Number+1
+		page.CreatedAt, page.UpdatedAt = time.Time{}, time.Time{}
+		if err := tx.Create(&page).Error; err != nil {
+			return err
+		}
+		assetIDs := []uint{}
+		for i := range works {
+			works[i].ID, works[i].UID, works[i].PageNum = 0, uuid.New().String(), page.Number
+			works[i].CreatedAt, works[i].UpdatedAt = time.Time{}, time.Time{}
+			if works[i].AssetID != nil {
+				assetIDs = append(assetIDs, *works[i].AssetID)
+			}
+		}
+		if len(works) > 0 {
+			if err := tx.Create(&works).Error; err != nil {
+				return err
+			}
+		}
+		for i := range texts {
+			texts[i].ID, texts[i].UID, texts[i].PageNum = 0, uuid.New().String(), page.Number
+		}
+		if len(texts) > 0 {
+			if err := tx.Create(&texts).Error; err != nil {
+				return err
+			}
+		}
+		return recountAssetRefs(tx, assetIDs)
+	})
+}
+
+// MovePortfolioPage 将页面移动到 to，其间的页面依次前移或后移
+func (r PortfolioRepo) MovePortfolioPage(ctx context.Context, portfolioUID, pageUID string, to int, ifRevision *int64) ([]PortfolioPage, int64, error) {
+	return r.pageTx(ctx, portfolioUID, ifRevision, func(tx *gorm.DB, count int) error {
+		return movePage(tx, portfolioUID, pageUID, to, count)
+	})
+}
+
+// movePage 在事务中移动页面，count 为作品集的页面总数
+func movePage(tx *gorm.DB, portfolioUID, pageUID string, to, count int) error {
+	page, err := findPage(tx, portfolioUID, pageUID)
+	if err != nil {
+		return err
+	}
+	if to >= count {
+		to = count - 1
+	}
+	from := page.Number
+	if from == to {
+		return nil
+	}
+	// 先记下被移动页面上的内容，编号调整后再单独改写
+	workIDs, textIDs := []uint{}, []uint{}
+	if err := tx.Model(&Work{}).Where("project_uid IN (?) AND page = ?", portfolioProjects(tx, portfolioUID), from).
+		Pluck("id", &workIDs).Error; err != nil {
+		return err
+	}
+	if err := tx.Model(&Text{}).Where("project_uid IN (?) AND page = ?", portfolioProjects(tx, portfolioUID), from).
+		Pluck("id", &textIDs).Error; err != nil {
+		return err
+	}
+	if from < to {
+		err = shiftPages(tx, portfolioUID, from+1, to, -1)
+	} else {
+		err = shiftPages(tx, portfolioUID, to, from-1, 1)
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(&PortfolioPage{}).Where("id = ?", page.ID).Update("number", to).Error; err != nil {
+		return err
+	}
+	if len(workIDs) > 0 {
+		if err := tx.Model(&Work{}).Where("id IN ?", workIDs).Update("page", to).Error; err != nil {
+			return err
+		}
+	}
+	if len(textIDs) > 0 {
+		if err := tx.Model(&Text{}).Where("id IN ?", textIDs).Update("page", to).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeletePortfolioPage 删除页面及其中的作品与文本，之后的页面依次前移
+func (r PortfolioRepo) DeletePortfolioPage(ctx context.Context, portfolioUID, pageUID string, ifRevision *int64) ([]PortfolioPage, int64, error) {
+	return r.pageTx(ctx, portfolioUID, ifRevision, func(tx *gorm.DB, count int) error {
+		return deletePage(tx, portfolioUID, pageUID)
+	})
+}
+
+func deletePage(tx *gorm.DB, portfolioUID, pageUID string) error {
+	page, err := findPage(tx, portfolioUID, pageUID)
+	if err != nil {
+		return err
+	}
+	assetIDs := []uint{}
+	if err := tx.Model(&Work{}).
+		Where("project_uid IN (?) AND page = ? AND asset_id IS NOT NULL", portfolioProjects(tx, portfolioUID), page.Number).
+		Pluck("asset_id", &assetIDs).Error; err != nil {
+		return err
+	}
+	for _, model := range []any{&Work{}, &Text{}} {
+		if err := tx.Where("project_uid IN (?) AND page = ?", portfolioProjects(tx, portfolioUID), page.Number).
+			Delete(model).Error; err != nil {
+			return err
+		}
+	}
+	if err := tx.Delete(&page).Error; err != nil {
+		return err
+	}
+	if err := shiftPages(tx, portfolioUID, page.Number+1, math.MaxInt32, -1); err != nil {
+		return err
+	}
+	return recountAssetRefs(tx, assetIDs)
+}
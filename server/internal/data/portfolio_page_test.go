@@ -0,0 +1,143 @@
+package data
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// createPages 创建作品集的项目与 n 个页面，每页放一个作品与一段文本，返回各页的 uid
+func createPages(t *testing.T, tx *gorm.DB, portfolioUID string, n int) []string {
+	t.Helper()
+	project := testUID("project")
+	if err := tx.Create(&Project{UID: project, PortfolioUID: portfolioUID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	uids := []string{}
+	for i := 0; i < n; i++ {
+		page := PortfolioPage{UID: testUID("page"), PortfolioUID: portfolioUID, Number: i}
+		work := Work{UID: page.UID, ProjectUID: project, PageNum: i}
+		text := Text{UID: page.UID, ProjectUID: project, PageNum: i}
+		for _, row := range []any{&page, &work, &text} {
+			if err := tx.Create(row).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+		uids = append(uids, page.UID)
+	}
+	return uids
+}
+
+// pageOrder 返回按页码排列的页面 uid，并检查每页的作品与文本仍在同一页
+func pageOrder(t *testing.T, tx *gorm.DB, portfolioUID string) []string {
+	t.Helper()
+	pages, err := listPages(tx, portfolioUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uids := []string{}
+	for _, page := range pages {
+		work, text := Work{}, Text{}
+		if err := tx.Where("uid = ?", page.UID).First(&work).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.Where("uid = ?", page.UID).First(&text).Error; err != nil {
+			t.Fatal(err)
+		}
+		if work.PageNum != page.Number || text.PageNum != page.Number {
+			t.Errorf("page %d: work on %d, text on %d", page.Number, work.PageNum, text.PageNum)
+		}
+		uids = append(uids, page.UID)
+	}
+	return uids
+}
+
+func TestShiftPages(t *testing.T) {
+	tx := testTx(t)
+	portfolio, other := testUID("portfolio"), testUID("other")
+	pages := createPages(t, tx, portfolio, 5)
+	otherPages := createPages(t, tx, other, 3)
+
+	if err := shiftPages(tx, portfolio, 2, 3, 1); err != nil {
+		t.Fatalf("shiftPages: %v", err)
+	}
+	numbers := map[string]int{}
+	for _, uid := range pages {
+		page, err := findPage(tx, portfolio, uid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		numbers[uid] = page.Number
+	}
+	want := []int{0, 1, 3, 4, 4}
+	for i, uid := range pages {
+		if numbers[uid] != want[i] {
+			t.Errorf("page %d moved to %d, want %d", i, numbers[uid], want[i])
+		}
+		work := Work{}
+		if err := tx.Where("uid = ?", uid).First(&work).Error; err != nil {
+			t.Fatal(err)
+		}
+		if work.PageNum != want[i] {
+			t.Errorf("work on page %d moved to %d, want %d", i, work.PageNum, want[i])
+		}
+	}
+	// 其他作品集的页面不受影响
+	if got := pageOrder(t, tx, other); !equalStrings(got, otherPages) {
+		t.Errorf("other portfolio pages = %v, want %v", got, otherPages)
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestMovePage(t *testing.T) {
+	tests := []struct {
+		name string
+		from int
+		to   int
+		want []int
+	}{
+		{name: "forward", from: 0, to: 2, want: []int{1, 2, 0, 3}},
+		{name: "backward", from: 3, to: 0, want: []int{3, 0, 1, 2}},
+		{name: "adjacent", from: 1, to: 2, want: []int{0, 2, 1, 3}},
+		{name: "same position", from: 2, to: 2, want: []int{0, 1, 2, 3}},
+		{name: "beyond last page", from: 0, to: 10, want: []int{1, 2, 3, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tx := testTx(t)
+			portfolio := testUID("portfolio")
+			pages := createPages(t, tx, portfolio, 4)
+			if err := movePage(tx, portfolio, pages[tt.from], tt.to, len(pages)); err != nil {
+				t.Fatalf("movePage: %v", err)
+			}
+			want := []string{}
+			for _, i := range tt.want {
+				want = append(want, pages[i])
+			}
+			if got := pageOrder(t, tx, portfolio); !equalStrings(got, want) {
+				t.Errorf("pages = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestMovePageNotFound(t *testing.T) {
+	tx := testTx(t)
+	portfolio, other := testUID("portfolio"), testUID("other")
+	createPages(t, tx, portfolio, 2)
+	otherPages := createPages(t, tx, other, 2)
+	if err := movePage(tx, portfolio, otherPages[0], 1, 2); err == nil {
+		t.Fatal("moved a page of another portfolio")
+	}
+}
@@ -0,0 +1,107 @@
+package data
+
+import "testing"
+
+func TestClaimUIDs(t *testing.T) {
+	tx := testTx(t)
+	portfolio, other := testUID("portfolio"), testUID("other")
+	project, foreignProject := testUID("project"), testUID("foreign-project")
+	work1, work2, foreignWork := testUID("work1"), testUID("work2"), testUID("foreign-work")
+	text, foreignText := testUID("text"), testUID("foreign-text")
+	for _, row := range []any{
+		&Project{UID: project, PortfolioUID: portfolio},
+		&Project{UID: foreignProject, PortfolioUID: other},
+		&Work{UID: work1, ProjectUID: project, OSSKey: "a"},
+		&Work{UID: work2, ProjectUID: project, OSSKey: "a"},
+		&Work{UID: foreignWork, ProjectUID: foreignProject, OSSKey: "a"},
+		&Text{UID: text, ProjectUID: project},
+		&Text{UID: foreignText, ProjectUID: foreignProject},
+	} {
+		if err := tx.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := &Portfolio{UID: portfolio, Projects: []Project{
+		{
+			UID: project,
+			Works: []Work{
+				{UID: foreignWork, OSSKey: "a"},
+				{UID: work1, OSSKey: "a"},
+				{UID: work1, OSSKey: "a"},
+				{OSSKey: "a"},
+				{OSSKey: "a"},
+			},
+			Texts: []Text{{UID: text}, {UID: foreignText}},
+		},
+		{UID: foreignProject, Works: []Work{{UID: foreignWork, OSSKey: "a"}}},
+		{},
+	}, Pages: []PortfolioPage{{ProjectUID: project}, {ProjectUID: foreignProject}}}
+	if err := claimUIDs(tx, p); err != nil {
+		t.Fatalf("claimUIDs: %v", err)
+	}
+
+	owned := p.Projects[0]
+	if owned.UID != project {
+		t.Fatalf("owned project renamed to %s", owned.UID)
+	}
+	works := owned.Works
+	if works[0].UID == foreignWork || works[0].UID == "" {
+		t.Errorf("foreign work uid kept: %s", works[0].UID)
+	}
+	if works[1].UID != work1 {
+		t.Errorf("owned work renamed to %s", works[1].UID)
+	}
+	if works[2].UID == work1 || works[2].UID == "" {
+		t.Errorf("duplicate work uid kept: %s", works[2].UID)
+	}
+	// 空 uid 按 (project_uid, oss_key) 沿用尚未使用的行，没有可沿用的行时生成新的 uid
+	if works[3].UID != work2 {
+		t.Errorf("work without uid = %s, want reused %s", works[3].UID, work2)
+	}
+	if works[4].UID == "" || works[4].UID == work1 || works[4].UID == work2 {
+		t.Errorf("work without uid = %s, want a new uid", works[4].UID)
+	}
+	if owned.Texts[0].UID != text {
+		t.Errorf("owned text renamed to %s", owned.Texts[0].UID)
+	}
+	if owned.Texts[1].UID == foreignText {
+		t.Error("foreign text uid kept")
+	}
+
+	foreign := p.Projects[1]
+	if foreign.UID == foreignProject || foreign.UID == "" {
+		t.Errorf("foreign project uid kept: %s", foreign.UID)
+	}
+	if foreign.Works[0].UID == foreignWork {
+		t.Error("work of foreign project kept its uid")
+	}
+	if p.Projects[2].UID == "" {
+		t.Error("empty project uid was not generated")
+	}
+	// 页面跟随重新生成的项目 uid
+	if p.Pages[0].ProjectUID != project || p.Pages[1].ProjectUID != foreign.UID {
+		t.Errorf("page project uids = %s, %s; want %s, %s", p.Pages[0].ProjectUID, p.Pages[1].ProjectUID, project, foreign.UID)
+	}
+
+	seen := map[string]bool{}
+	for _, project := range p.Projects {
+		if project.PortfolioUID != portfolio {
+			t.Errorf("project %s portfolio_uid = %s", project.UID, project.PortfolioUID)
+		}
+		for _, work := range project.Works {
+			if work.ProjectUID != project.UID {
+				t.Errorf("work %s project_uid = %s, want %s", work.UID, work.ProjectUID, project.UID)
+			}
+			if seen[work.UID] {
+				t.Errorf("work uid %s used twice", work.UID)
+			}
+			seen[work.UID] = true
+		}
+		for _, text := range project.Texts {
+			if text.ProjectUID != project.UID {
+				t.Errorf("text %s project_uid = %s, want %s", text.UID, text.ProjectUID, project.UID)
+			}
+		}
+	}
+}
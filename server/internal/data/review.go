@@ -0,0 +1,162 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ReviewStatus string
+
+const (
+	// ReviewInvited 为邀请已发出、尚未被接受
+	ReviewInvited          ReviewStatus = "invited"
+	ReviewInProgress       ReviewStatus = "in_progress"
+	ReviewApproved         ReviewStatus = "approved"
+	ReviewChangesRequested ReviewStatus = "changes_requested"
+	// ReviewRevoked 为学生撤销了评审，评审人不再能查看作品集
+	ReviewRevoked ReviewStatus = "revoked"
+)
+
+// Review 为学生邀请老师评审作品集的记录，评审人通过 InviteToken 接受邀请后绑定
+type Review struct {
+	ID             uint         `gorm:"primarykey"`
+	UID            string       `gorm:"unique;index;type:varchar(255)" json:"uid"`
+	PortfolioUID   string       `gorm:"index;type:varchar(255)" json:"portfolio_uid"`
+	OwnerOpenid    string       `gorm:"index;type:varchar(255)" json:"-"`
+	ReviewerOpenid string       `gorm:"index;type:varchar(255)" json:"-"`
+	InviteToken    string       `gorm:"unique;index;type:varchar(64)" json:"-"`
+	Status         ReviewStatus `gorm:"type:varchar(32)" json:"status"`
+	// Note 为邀请时的说明，Verdict 为评审人给出结论时的总结
+	Note       string     `gorm:"type:text" json:"note"`
+	Verdict    string     `gorm:"type:text" json:"verdict"`
+	AcceptedAt *time.Time `json:"accepted_at"`
+	DecidedAt  *time.Time `json:"decided_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+type AnchorType string
+
+const (
+	AnchorPortfolio AnchorType = "portfolio"
+	AnchorPage      AnchorType = "page"
+	AnchorWork      AnchorType = "work"
+	// AnchorRegion 为页面上的矩形区域，坐标为相对页面宽高的比例
+	AnchorRegion AnchorType = "region"
+)
+
+// ReviewComment 为评审中的评论，回复的 ParentUID 为所在讨论的首条评论，锚点与之相同
+type ReviewComment struct {
+	ID           uint       `gorm:"primarykey"`
+	UID          string     `gorm:"unique;index;type:varchar(255)" json:"uid"`
+	ReviewUID    string     `gorm:"index;type:varchar(255)" json:"review_uid"`
+	ParentUID    string     `gorm:"index;type:varchar(255)" json:"parent_uid"`
+	AuthorOpenid string     `gorm:"type:varchar(255)" json:"-"`
+	Body         string     `gorm:"type:text" json:"body"`
+	AnchorType   AnchorType `gorm:"type:varchar(16)" json:"anchor_type"`
+	PageUID      string     `gorm:"type:varchar(255)" json:"page_uid"`
+	WorkUID      string     `gorm:"type:varchar(255)" json:"work_uid"`
+	Region       *CropRect  `gorm:"type:json;serializer:json" json:"region"`
+	// 只有首条评论可以标记为已解决，代表整个讨论
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type ReviewRepo struct {
+	mysqlDB *gorm.DB
+}
+
+func NewReviewRepo(mysqlDB *gorm.DB) ReviewRepo {
+	return ReviewRepo{mysqlDB: mysqlDB}
+}
+
+func (r ReviewRepo) CreateReview(ctx context.Context, review Review) error {
+	return r.mysqlDB.WithContext(ctx).Create(&review).Error
+}
+
+func (r ReviewRepo) GetReviewByUID(ctx context.Context, uid string) (Review, error) {
+	review := Review{}
+	err := r.mysqlDB.WithContext(ctx).Where("uid = ?", uid).First(&review).Error
+	return review, err
+}
+
+// AcceptReview 将邀请绑定到评审人，邀请只能被接受一次，无效时返回 gorm.ErrRecordNotFound
+func (r ReviewRepo) AcceptReview(ctx context.Context, token, openid string) (Review, error) {
+	review := Review{}
+	err := r.mysqlDB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Review{}).
+			Where("invite_token = ? AND status = ? AND owner_openid <> ?", token, ReviewInvited, openid).
+			Updates(map[string]any{
+				"reviewer_openid": openid,
+				"status":          ReviewInProgress,
+				"accepted_at":     time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("invite_token = ?", token).First(&review).Error
+	})
+	return review, err
+}
+
+func (r ReviewRepo) ListReviewsByPortfolio(ctx context.Context, portfolioUID string) ([]Review, error) {
+	reviews := []Review{}
+	if err := r.mysqlDB.WithContext(ctx).Where("portfolio_uid = ?", portfolioUID).
+		Order("created_at DESC").Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+func (r ReviewRepo) ListReviewsByReviewer(ctx context.Context, openid string) ([]Review, error) {
+	reviews := []Review{}
+	if err := r.mysqlDB.WithContext(ctx).Where("reviewer_openid = ? AND status <> ?", openid, ReviewRevoked).
+		Order("updated_at DESC").Find(&reviews).Error; err != nil {
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// UpdateReviewStatus 更新评审状态，fields 为需要同时写入的其他列
+func (r ReviewRepo) UpdateReviewStatus(ctx context.Context, uid string, status ReviewStatus, fields map[string]any) error {
+	updates := map[string]any{"status": status}
+	for k, v := range fields {
+		updates[k] = v
+	}
+	return r.mysqlDB.WithContext(ctx).Model(&Review{}).Where("uid = ?", uid).Updates(updates).Error
+}
+
+func (r ReviewRepo) CreateReviewComment(ctx context.Context, comment ReviewComment) error {
+	return r.mysqlDB.WithContext(ctx).Create(&comment).Error
+}
+
+func (r ReviewRepo) GetReviewComment(ctx context.Context, uid string) (ReviewComment, error) {
+	comment := ReviewComment{}
+	err := r.mysqlDB.WithContext(ctx).Where("uid = ?", uid).First(&comment).Error
+	return comment, err
+}
+
+func (r ReviewRepo) ListReviewComments(ctx context.Context, reviewUID string) ([]ReviewComment, error) {
+	comments := []ReviewComment{}
+	if err := r.mysqlDB.WithContext(ctx).Where("review_uid = ?", reviewUID).
+		Order("created_at, id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	return comments, nil
+}
+
+// SetCommentResolved 标记或取消标记讨论为已解决
+func (r ReviewRepo) SetCommentResolved(ctx context.Context, uid string, resolved bool) error {
+	var resolvedAt *time.Time
+	if resolved {
+		now := time.Now()
+		resolvedAt = &now
+	}
+	return r.mysqlDB.WithContext(ctx).Model(&ReviewComment{}).Where("uid = ?", uid).
+		Update("resolved_at", resolvedAt).Error
+}
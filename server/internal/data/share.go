@@ -0,0 +1,69 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ShareLink 为作品集的公开分享链接，持有 token 的人无需登录即可只读查看
+type ShareLink struct {
+	ID           uint   `gorm:"primarykey"`
+	Token        string `gorm:"unique;index;type:varchar(64)" json:"token"`
+	PortfolioUID string `gorm:"index;type:varchar(255)" json:"portfolio_uid"`
+	Openid       string `gorm:"index;type:varchar(255)" json:"-"`
+	// PasswordHash 为 bcrypt 哈希，为空表示无需密码
+	PasswordHash string     `gorm:"type:varchar(255)" json:"-"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	ViewCount    int64      `gorm:"default:0" json:"view_count"`
+	LastViewedAt *time.Time `json:"last_viewed_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// Active 判断链接当前是否可用
+func (s ShareLink) Active(now time.Time) bool {
+	return s.RevokedAt == nil && (s.ExpiresAt == nil || now.Before(*s.ExpiresAt))
+}
+
+func (r PortfolioRepo) CreateShareLink(ctx context.Context, link ShareLink) error {
+	return r.mysqlDB.WithContext(ctx).Create(&link).Error
+}
+
+func (r PortfolioRepo) ListShareLinks(ctx context.Context, portfolioUID string) ([]ShareLink, error) {
+	links := []ShareLink{}
+	if err := r.mysqlDB.WithContext(ctx).Where("portfolio_uid = ?", portfolioUID).
+		Order("created_at DESC").Find(&links).Error; err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+func (r PortfolioRepo) GetShareLinkByToken(ctx context.Context, token string) (ShareLink, error) {
+	link := ShareLink{}
+	err := r.mysqlDB.WithContext(ctx).Where("token = ?", token).First(&link).Error
+	return link, err
+}
+
+// RevokeShareLink 撤销该用户创建的链接，链接不存在或已撤销时返回 gorm.ErrRecordNotFound
+func (r PortfolioRepo) RevokeShareLink(ctx context.Context, openid, token string) error {
+	result := r.mysqlDB.WithContext(ctx).Model(&ShareLink{}).
+		Where("token = ? AND openid = ? AND revoked_at IS NULL", token, openid).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// RecordShareView 累加链接的查看次数
+func (r PortfolioRepo) RecordShareView(ctx context.Context, token string) error {
+	return r.mysqlDB.WithContext(ctx).Model(&ShareLink{}).Where("token = ?", token).Updates(map[string]any{
+		"view_count":     gorm.Expr("view_count + 1"),
+		"last_viewed_at": time.Now(),
+	}).Error
+}
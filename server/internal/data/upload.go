@@ -0,0 +1,280 @@
+package data
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"gorm.io/gorm"
+)
+
+type UploadStatus int
+
+const (
+	UploadPending UploadStatus = iota
+	UploadConfirmed
+)
+
+// ProcessStatus 为图片处理状态，非图片用途的上传保持 ProcessNone
+type ProcessStatus int
+
+const (
+	ProcessNone ProcessStatus = iota
+	ProcessRunning
+	ProcessDone
+	ProcessFailed
+)
+
+// Upload 记录签发过上传 URL 的对象及其上传者
+type Upload struct {
+	ID          uint         `gorm:"primarykey"`
+	OSSKey      string       `gorm:"unique;index;type:varchar(255)" json:"oss_key"`
+	Openid      string       `gorm:"index;type:varchar(255)" json:"-"`
+	Purpose     string       `gorm:"type:varchar(32)" json:"purpose"`
+	ContentType string       `gorm:"type:varchar(255)" json:"content_type"`
+	Size        int64        `json:"size"`
+	Status      UploadStatus `gorm:"index;type:tinyint" json:"status"`
+	CreatedAt   time.Time    `json:"created_at"`
+	ConfirmedAt *time.Time   `json:"confirmed_at"`
+	// MultipartID 为分片上传的 upload id，普通上传为空
+	MultipartID string `gorm:"type:varchar(255)" json:"-"`
+	// 以下字段由图片处理流程回填，Width、Height 为校正方向后的像素尺寸
+	ProcessStatus ProcessStatus `gorm:"type:tinyint;default:0" json:"process_status"`
+	Width         int           `json:"width"`
+	Height        int           `json:"height"`
+	DPI           float64       `gorm:"type:double" json:"dpi"`
+	ThumbKey      string        `gorm:"type:varchar(255)" json:"thumb_key"`
+	PreviewKey    string        `gorm:"type:varchar(255)" json:"preview_key"`
+	// AssetID 为内容去重后对应的资源，内容重复时与其他上传指向同一资源
+	AssetID *uint `gorm:"index" json:"asset_id"`
+}
+
+// ImageMeta 为图片处理完成后需要回填的字段
+type ImageMeta struct {
+	Size       int64
+	Width      int
+	Height     int
+	DPI        float64
+	ThumbKey   string
+	PreviewKey string
+}
+
+type OSSRepo struct {
+	mysqlDB     *gorm.DB
+	redisClient *redis.Client
+}
+
+func NewOSSRepo(mysqlDB *gorm.DB, redisClient *redis.Client) *OSSRepo {
+	return &OSSRepo{mysqlDB: mysqlDB, redisClient: redisClient}
+}
+
+func (r *OSSRepo) CreateUpload(ctx context.Context, upload Upload) error {
+	return r.mysqlDB.WithContext(ctx).Create(&upload).Error
+}
+
+func (r *OSSRepo) GetUploadByKey(ctx context.Context, ossKey string) (Upload, error) {
+	upload := Upload{}
+	if err := r.mysqlDB.WithContext(ctx).Where("oss_key = ?", ossKey).First(&upload).Error; err != nil {
+		return Upload{}, err
+	}
+	return upload, nil
+}
+
+func (r *OSSRepo) ConfirmUpload(ctx context.Context, ossKey string, size int64) error {
+	now := time.Now()
+	return r.mysqlDB.WithContext(ctx).Model(&Upload{}).Where("oss_key = ?", ossKey).Updates(map[string]any{
+		"status":       UploadConfirmed,
+		"size":         size,
+		"confirmed_at": &now,
+	}).Error
+}
+
+// StartProcessing 将状态从未处理或失败切换为处理中，返回 false 表示已有其他请求在处理
+func (r *OSSRepo) StartProcessing(ctx context.Context, ossKey string) (bool, error) {
+	result := r.mysqlDB.WithContext(ctx).Model(&Upload{}).
+		Where("oss_key = ? AND process_status IN ?", ossKey, []ProcessStatus{ProcessNone, ProcessFailed}).
+		Update("process_status", ProcessRunning)
+	return result.RowsAffected > 0, result.Error
+}
+
+func (r *OSSRepo) SaveImageMeta(ctx context.Context, ossKey string, meta ImageMeta) error {
+	return r.mysqlDB.WithContext(ctx).Model(&Upload{}).Where("oss_key = ?", ossKey).Updates(map[string]any{
+		"process_status": ProcessDone,
+		"size":           meta.Size,
+		"width":          meta.Width,
+		"height":         meta.Height,
+		"dpi":            meta.DPI,
+		"thumb_key":      meta.ThumbKey,
+		"preview_key":    meta.PreviewKey,
+	}).Error
+}
+
+func (r *OSSRepo) SetProcessStatus(ctx context.Context, ossKey string, status ProcessStatus) error {
+	return r.mysqlDB.WithContext(ctx).Model(&Upload{}).Where("oss_key = ?", ossKey).
+		Update("process_status", status).Error
+}
+
+// ListRenditionKeys 返回已处理图片的原图 key 到缩略图 key 的映射
+func (r *OSSRepo) ListRenditionKeys(ctx context.Context) (map[string][]string, error) {
+	uploads := []Upload{}
+	if err := r.mysqlDB.WithContext(ctx).Select("oss_key", "thumb_key", "preview_key").
+		Where("process_status = ?", ProcessDone).Find(&uploads).Error; err != nil {
+		return nil, err
+	}
+	renditions := make(map[string][]string, len(uploads))
+	for _, upload := range uploads {
+		renditions[upload.OSSKey] = []string{upload.ThumbKey, upload.PreviewKey}
+	}
+	return renditions, nil
+}
+
+func (r *OSSRepo) GetUploadsByKeys(ctx context.Context, ossKeys []string) ([]Upload, error) {
+	uploads := []Upload{}
+	if err := r.mysqlDB.WithContext(ctx).Where("oss_key IN ?", ossKeys).Find(&uploads).Error; err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+// FilterOwnedKeys 返回其中由该用户上传的对象
+func (r *OSSRepo) FilterOwnedKeys(ctx context.Context, openid string, ossKeys []string) ([]string, error) {
+	keys := []string{}
+	if err := r.mysqlDB.WithContext(ctx).Model(&Upload{}).
+		Where("oss_key IN ? AND openid = ?", ossKeys, openid).Pluck("oss_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// FilterPortfolioKeys 返回其中被该用户的作品集引用的对象
+func (r *OSSRepo) FilterPortfolioKeys(ctx context.Context, openid string, ossKeys []string) ([]string, error) {
+	keys := []string{}
+	if err := r.mysqlDB.WithContext(ctx).Model(&Work{}).
+		Joins("JOIN projects ON projects.uid = works.project_uid").
+		Joins("JOIN portfolios ON portfolios.uid = projects.portfolio_uid").
+		Where("portfolios.openid = ? AND works.oss_key IN ?", openid, ossKeys).
+		Distinct().Pluck("works.oss_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// FilterTemplateAssetKeys 返回其中属于模板公共资源（页面、预览图、字体）的对象
+func (r *OSSRepo) FilterTemplateAssetKeys(ctx context.Context, ossKeys []string) ([]string, error) {
+	return filterTemplateAssetKeys(r.mysqlDB.WithContext(ctx), ossKeys)
+}
+
+func filterTemplateAssetKeys(db *gorm.DB, ossKeys []string) ([]string, error) {
+	pages := []Page{}
+	if err := db.Select("oss_key", "preview_oss_key").
+		Where("oss_key IN ? OR preview_oss_key IN ?", ossKeys, ossKeys).Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	fonts := []string{}
+	if err := db.Model(&Template{}).
+		Where("font_oss_key IN ?", ossKeys).Pluck("font_oss_key", &fonts).Error; err != nil {
+		return nil, err
+	}
+	fontKeys := []string{}
+	if err := db.Model(&TemplateFont{}).
+		Where("oss_key IN ?", ossKeys).Pluck("oss_key", &fontKeys).Error; err != nil {
+		return nil, err
+	}
+	keys := append(fonts, fontKeys...)
+	for _, page := range pages {
+		keys = append(keys, page.OSSKey, page.PreviewOSSKey)
+	}
+	return keys, nil
+}
+
+func (r *OSSRepo) ListWorkKeys(ctx context.Context) ([]string, error) {
+	keys := []string{}
+	if err := r.mysqlDB.WithContext(ctx).Model(&Work{}).Distinct().Pluck("oss_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *OSSRepo) ListFeedbackAttachments(ctx context.Context) ([]string, error) {
+	feedbacks := []Feedback{}
+	if err := r.mysqlDB.WithContext(ctx).Select("attachments").
+		Where("attachments IS NOT NULL").Find(&feedbacks).Error; err != nil {
+		return nil, err
+	}
+	keys := []string{}
+	for _, feedback := range feedbacks {
+		keys = append(keys, feedback.Attachments...)
+	}
+	return keys, nil
+}
+
+func (r *OSSRepo) ListTemplateAssetKeys(ctx context.Context) ([]string, error) {
+	pages := []Page{}
+	if err := r.mysqlDB.WithContext(ctx).Select("oss_key", "preview_oss_key").Find(&pages).Error; err != nil {
+		return nil, err
+	}
+	fonts := []string{}
+	if err := r.mysqlDB.WithContext(ctx).Model(&Template{}).Pluck("font_oss_key", &fonts).Error; err != nil {
+		return nil, err
+	}
+	fontKeys := []string{}
+	if err := r.mysqlDB.WithContext(ctx).Model(&TemplateFont{}).Pluck("oss_key", &fontKeys).Error; err != nil {
+		return nil, err
+	}
+	keys := append(fonts, fontKeys...)
+	for _, page := range pages {
+		keys = append(keys, page.OSSKey, page.PreviewOSSKey)
+	}
+	return keys, nil
+}
+
+// ListLatestUploadKeys 返回每个用户在该用途下最近一次确认的上传
+func (r *OSSRepo) ListLatestUploadKeys(ctx context.Context, purpose string) ([]string, error) {
+	latest := r.mysqlDB.Model(&Upload{}).Select("MAX(id)").
+		Where("purpose = ? AND status = ?", purpose, UploadConfirmed).Group("openid")
+	keys := []string{}
+	if err := r.mysqlDB.WithContext(ctx).Model(&Upload{}).
+		Where("id IN (?)", latest).Pluck("oss_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *OSSRepo) DeleteUploads(ctx context.Context, ossKeys []string) error {
+	if len(ossKeys) == 0 {
+		return nil
+	}
+	return r.mysqlDB.WithContext(ctx).Where("oss_key IN ?", ossKeys).Delete(&Upload{}).Error
+}
+
+// DeleteStalePendingUploads 删除超过 before 仍未确认、且对象已不存在的上传记录
+func (r *OSSRepo) DeleteStalePendingUploads(ctx context.Context, before time.Time, existing map[string]struct{}) (int, error) {
+	uploads := []Upload{}
+	if err := r.mysqlDB.WithContext(ctx).Select("oss_key").
+		Where("status = ? AND created_at < ?", UploadPending, before).Find(&uploads).Error; err != nil {
+		return 0, err
+	}
+	stale := []string{}
+	for _, upload := range uploads {
+		if _, ok := existing[upload.OSSKey]; !ok {
+			stale = append(stale, upload.OSSKey)
+		}
+	}
+	return len(stale), r.DeleteUploads(ctx, stale)
+}
+
+// ListStaleMultipartUploads 返回超过 before 仍未完成的分片上传
+func (r *OSSRepo) ListStaleMultipartUploads(ctx context.Context, before time.Time) ([]Upload, error) {
+	uploads := []Upload{}
+	if err := r.mysqlDB.WithContext(ctx).Select("oss_key", "multipart_id").
+		Where("status = ? AND multipart_id <> '' AND created_at < ?", UploadPending, before).
+		Find(&uploads).Error; err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+// AcquireLock 多副本部署时保证同一时间只有一个副本执行后台任务
+func (r *OSSRepo) AcquireLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	return r.redisClient.SetNX(ctx, "lock:"+name, 1, ttl).Result()
+}
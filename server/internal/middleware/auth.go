@@ -5,9 +5,11 @@ import (
 	"strings"
 	"time"
 
+	"github.com/Fl0rencess720/Springboard/consts"
+	"github.com/Fl0rencess720/Springboard/internal/conf"
+	"github.com/Fl0rencess720/Springboard/pkgs/i18n"
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v4"
-	"github.com/spf13/viper"
 )
 
 type ContextKey string
@@ -28,10 +30,10 @@ func GenAccessToken(openid string) (string, error) {
 			ID:        time.Now().String(),
 			Issuer:    "Springboard",
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(2 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(conf.Get().Auth.AccessTTL)),
 		},
 	}
-	accessSecret := viper.GetString("ACCESS_SECRET")
+	accessSecret := conf.Get().Auth.AccessSecret
 	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, ac).SignedString([]byte(accessSecret))
 	if err != nil {
 		return "", err
@@ -44,9 +46,9 @@ func GenRefreshToken() (string, error) {
 		ID:        time.Now().String(),
 		Issuer:    "Springboard",
 		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(14 * 24 * time.Hour)),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(conf.Get().Auth.RefreshTTL)),
 	}
-	refreshSecret := viper.GetString("REFRESH_SECRET")
+	refreshSecret := conf.Get().Auth.RefreshSecret
 	refreshToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, rc).SignedString([]byte(refreshSecret))
 	if err != nil {
 		return "", err
@@ -67,7 +69,7 @@ func GenToken(phone string) (string, string, error) {
 }
 
 func ParseToken(aToken string) (*AuthClaims, bool, error) {
-	accessSecret := viper.GetString("ACCESS_SECRET")
+	accessSecret := conf.Get().Auth.AccessSecret
 	accessToken, err := jwt.ParseWithClaims(aToken, &AuthClaims{}, func(token *jwt.Token) (interface{}, error) {
 		return []byte(accessSecret), nil
 	})
@@ -81,8 +83,8 @@ func ParseToken(aToken string) (*AuthClaims, bool, error) {
 }
 
 func RefreshToken(aToken, rToken string) (string, error) {
-	accessSecret := viper.GetString("ACCESS_SECRET")
-	refreshSecret := viper.GetString("REFRESH_SECRET")
+	accessSecret := conf.Get().Auth.AccessSecret
+	refreshSecret := conf.Get().Auth.RefreshSecret
 	rToken = strings.TrimPrefix(rToken, "Bearer ")
 	_, err := jwt.Parse(rToken, func(token *jwt.Token) (interface{}, error) {
 		return []byte(refreshSecret), nil
@@ -105,36 +107,33 @@ func Auth() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := c.GetHeader("Authorization")
 		if tokenString == "" {
-			c.AbortWithStatusJSON(401, gin.H{
-				"code":    401,
-				"message": "miss token string",
-			})
+			abortWithCode(c, consts.TokenMissing)
 			return
 		}
 		parts := strings.Split(tokenString, " ")
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.AbortWithStatusJSON(401, gin.H{
-				"code":    401,
-				"message": "wrong token format",
-			})
+			abortWithCode(c, consts.TokenFormatError)
 			return
 		}
 		parsedToken, isExpire, err := ParseToken(parts[1])
-		if err != nil {
-			c.AbortWithStatusJSON(401, gin.H{
-				"code":    401,
-				"message": "invalid token",
-			})
+		if errors.Is(err, jwt.ErrTokenExpired) || isExpire {
+			abortWithCode(c, consts.TokenExpired)
 			return
 		}
-		if isExpire {
-			c.AbortWithStatusJSON(401, gin.H{
-				"code":    401,
-				"message": "token expired",
-			})
+		if err != nil {
+			abortWithCode(c, consts.AuthError)
 			return
 		}
 		c.Set(string(OpenidKey), parsedToken.Openid)
 		c.Next()
 	}
 }
+
+// abortWithCode 与 controller.ErrorResponse 使用相同的响应结构
+func abortWithCode(c *gin.Context, code uint) {
+	c.AbortWithStatusJSON(consts.HttpCode[code], gin.H{
+		"code": code,
+		"msg":  i18n.Localize(c, code),
+		"data": nil,
+	})
+}
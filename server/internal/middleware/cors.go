@@ -1,17 +1,88 @@
 package middleware
 
 import (
+	"net/url"
+	"strings"
+	"sync/atomic"
+
+	"github.com/Fl0rencess720/Springboard/internal/conf"
 	"github.com/gin-contrib/cors"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Cors 按 project.mode 选择跨域策略，配置热更新后重新构建
 func Cors() gin.HandlerFunc {
+	var handler atomic.Pointer[gin.HandlerFunc]
+	build := func(cfg *conf.Config) {
+		h := newCors(cfg.CORSPolicy())
+		handler.Store(&h)
+	}
+	build(conf.Get())
+	conf.OnReload(build)
+
+	return func(c *gin.Context) {
+		(*handler.Load())(c)
+	}
+}
+
+func newCors(policy conf.CORSPolicy) gin.HandlerFunc {
+	origins := policy.AllowOrigins
+	methods := policy.AllowMethods
+	if len(methods) == 0 {
+		methods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
+	}
+	headers := policy.AllowHeaders
+	if len(headers) == 0 {
+		headers = []string{"Origin", "Content-Type", "Accept", "Accept-Language", "Authorization", "If-Match", "If-None-Match"}
+	}
 	return cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length, Access-Control-Allow-Origin, Access-Control-Allow-Headers, Content-Type"},
-		AllowCredentials: true,
+		AllowOriginFunc: func(origin string) bool {
+			return allowOrigin(origins, origin)
+		},
+		AllowMethods:     methods,
+		AllowHeaders:     headers,
+		ExposeHeaders:    policy.ExposeHeaders,
+		AllowCredentials: policy.AllowCredentials,
+		MaxAge:           policy.MaxAge,
 	})
 }
+
+// OriginAllowed 按当前的跨域策略判断 origin，供 WebSocket 握手等不经过 CORS 检查的请求使用
+func OriginAllowed(origin string) bool {
+	return allowOrigin(conf.Get().CORSPolicy().AllowOrigins, origin)
+}
+
+func allowOrigin(origins []string, origin string) bool {
+	for _, pattern := range origins {
+		if matchOrigin(pattern, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOrigin 支持 "*"、完整 origin 以及 https://*.example.com 形式的子域名通配，
+// 通配只匹配至少一级子域名，不匹配 example.com 本身
+func matchOrigin(pattern, origin string) bool {
+	if pattern == "*" || pattern == origin {
+		return true
+	}
+	if !strings.Contains(pattern, "*.") {
+		return false
+	}
+	p, err := url.Parse(strings.Replace(pattern, "*.", "wildcard.", 1))
+	if err != nil {
+		return false
+	}
+	o, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	if p.Scheme != o.Scheme || p.Port() != o.Port() {
+		return false
+	}
+	suffix := strings.TrimPrefix(p.Hostname(), "wildcard")
+	host := o.Hostname()
+	return strings.HasSuffix(host, suffix) && len(host) > len(suffix)
+}
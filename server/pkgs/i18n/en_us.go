@@ -0,0 +1,27 @@
+package i18n
+
+import "github.com/Fl0rencess720/Springboard/consts"
+
+var enUS = map[uint]string{
+	consts.ServerError:            "server error",
+	consts.AuthError:              "unauthorized",
+	consts.TokenExpired:           "token expired",
+	consts.LoginError:             "login failed",
+	consts.RefreshTokenError:      "failed to refresh token",
+	consts.RegisterError:          "registration failed",
+	consts.TokenMissing:           "missing token",
+	consts.TokenFormatError:       "wrong token format",
+	consts.InvalidParams:          "invalid parameters",
+	consts.TooManyRequests:        "too many requests, please retry later",
+	consts.UnsupportedContentType: "unsupported file type",
+	consts.FileTooLarge:           "file too large",
+	consts.ObjectAccessDenied:     "access to this file is denied",
+	consts.ObjectNotUploaded:      "file has not been uploaded",
+	consts.PortfolioAccessDenied:  "access to this portfolio is denied",
+	consts.ShareLinkInvalid:       "share link does not exist, has expired or was revoked",
+	consts.SharePasswordIncorrect: "incorrect share password",
+	consts.PortfolioNotFound:      "portfolio not found",
+	consts.ReviewAccessDenied:     "access to this review is denied",
+	consts.ReviewInviteInvalid:    "review invitation does not exist or was already accepted",
+	consts.PortfolioConflict:      "portfolio was modified elsewhere, merge and save again",
+}
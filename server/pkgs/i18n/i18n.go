@@ -0,0 +1,63 @@
+package i18n
+
+import (
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/language"
+)
+
+const (
+	ZhCN = "zh-CN"
+	EnUS = "en-US"
+
+	DefaultLang = ZhCN
+)
+
+// 顺序需与 matcher 中的 tag 一致
+var langs = []string{ZhCN, EnUS}
+
+var matcher = language.NewMatcher([]language.Tag{
+	language.SimplifiedChinese,
+	language.AmericanEnglish,
+})
+
+var bundles = map[string]map[uint]string{
+	ZhCN: zhCN,
+	EnUS: enUS,
+}
+
+var unknown = map[string]string{
+	ZhCN: "未知错误",
+	EnUS: "unknown error",
+}
+
+// Match 根据 Accept-Language 选择消息包，无法匹配时返回 DefaultLang
+func Match(acceptLanguage string) string {
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLang
+	}
+	_, idx, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLang
+	}
+	return langs[idx]
+}
+
+func Message(lang string, code uint) string {
+	bundle, ok := bundles[lang]
+	if !ok {
+		lang = DefaultLang
+		bundle = bundles[lang]
+	}
+	if msg, ok := bundle[code]; ok {
+		return msg
+	}
+	return unknown[lang]
+}
+
+// Localize 按请求的 Accept-Language 返回错误码对应的消息，并设置 Content-Language
+func Localize(c *gin.Context, code uint) string {
+	lang := Match(c.GetHeader("Accept-Language"))
+	c.Header("Content-Language", lang)
+	return Message(lang, code)
+}
@@ -0,0 +1,62 @@
+package i18n
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", DefaultLang},
+		{"zh-CN", ZhCN},
+		{"zh", ZhCN},
+		{"zh-Hans-CN,zh;q=0.9", ZhCN},
+		{"en-US", EnUS},
+		{"en", EnUS},
+		{"en-GB,en;q=0.8", EnUS},
+		{"fr-FR,en;q=0.5", EnUS},
+		{"en;q=0.5,zh-CN;q=0.9", ZhCN},
+		{"fr-FR", DefaultLang},
+		{"*", DefaultLang},
+		{"not a language;;;", DefaultLang},
+	}
+	for _, tt := range tests {
+		if got := Match(tt.header); got != tt.want {
+			t.Errorf("Match(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	for lang, bundle := range bundles {
+		for code, msg := range bundle {
+			if got := Message(lang, code); got != msg {
+				t.Errorf("Message(%q, %d) = %q, want %q", lang, code, got, msg)
+			}
+		}
+	}
+	if got := Message("fr-FR", 0); got != Message(DefaultLang, 0) {
+		t.Errorf("unknown language = %q, want default language", got)
+	}
+	if got := Message(EnUS, ^uint(0)); got != unknown[EnUS] {
+		t.Errorf("unknown code = %q, want %q", got, unknown[EnUS])
+	}
+}
+
+// 各语言的消息包需覆盖相同的错误码
+func TestBundlesComplete(t *testing.T) {
+	for code := range bundles[DefaultLang] {
+		for lang, bundle := range bundles {
+			if _, ok := bundle[code]; !ok {
+				t.Errorf("code %d missing in %s", code, lang)
+			}
+		}
+	}
+	for lang, bundle := range bundles {
+		for code := range bundle {
+			if _, ok := bundles[DefaultLang][code]; !ok {
+				t.Errorf("code %d in %s missing in %s", code, lang, DefaultLang)
+			}
+		}
+	}
+}
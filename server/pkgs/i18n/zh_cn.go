@@ -0,0 +1,27 @@
+package i18n
+
+import "github.com/Fl0rencess720/Springboard/consts"
+
+var zhCN = map[uint]string{
+	consts.ServerError:            "服务器错误",
+	consts.AuthError:              "无权访问",
+	consts.TokenExpired:           "Token过期",
+	consts.LoginError:             "登录失败",
+	consts.RefreshTokenError:      "刷新Token失败",
+	consts.RegisterError:          "注册失败",
+	consts.TokenMissing:           "缺少Token",
+	consts.TokenFormatError:       "Token格式错误",
+	consts.InvalidParams:          "参数错误",
+	consts.TooManyRequests:        "请求过于频繁，请稍后再试",
+	consts.UnsupportedContentType: "不支持的文件类型",
+	consts.FileTooLarge:           "文件过大",
+	consts.ObjectAccessDenied:     "无权访问该文件",
+	consts.ObjectNotUploaded:      "文件尚未上传",
+	consts.PortfolioAccessDenied:  "无权访问该作品集",
+	consts.ShareLinkInvalid:       "分享链接不存在、已过期或已撤销",
+	consts.SharePasswordIncorrect: "分享密码错误",
+	consts.PortfolioNotFound:      "作品集不存在",
+	consts.ReviewAccessDenied:     "无权访问该评审",
+	consts.ReviewInviteInvalid:    "评审邀请不存在或已被接受",
+	consts.PortfolioConflict:      "作品集已在其他设备上修改，请合并后重新保存",
+}
@@ -0,0 +1,85 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+const (
+	tagOrientation    = 0x0112
+	tagXResolution    = 0x011A
+	tagResolutionUnit = 0x0128
+)
+
+type exifInfo struct {
+	orientation int
+	dpi         float64
+}
+
+// parseExif 解析 TIFF 结构的 EXIF 数据，只读取 IFD0 中的方向与分辨率
+func parseExif(b []byte) exifInfo {
+	info := exifInfo{orientation: 1}
+	b = bytes.TrimPrefix(b, []byte("Exif\x00\x00"))
+	if len(b) < 8 {
+		return info
+	}
+	var order binary.ByteOrder
+	switch string(b[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return info
+	}
+	offset := int(order.Uint32(b[4:8]))
+	if offset+2 > len(b) {
+		return info
+	}
+	count := int(order.Uint16(b[offset:]))
+	resolution, unit := 0.0, 2
+	for i := 0; i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > len(b) {
+			break
+		}
+		tag := order.Uint16(b[entry:])
+		switch tag {
+		case tagOrientation:
+			if o := int(order.Uint16(b[entry+8:])); o >= 1 && o <= 8 {
+				info.orientation = o
+			}
+		case tagResolutionUnit:
+			unit = int(order.Uint16(b[entry+8:]))
+		case tagXResolution:
+			valueOffset := int(order.Uint32(b[entry+8:]))
+			if valueOffset+8 <= len(b) {
+				num := order.Uint32(b[valueOffset:])
+				den := order.Uint32(b[valueOffset+4:])
+				if den != 0 {
+					resolution = float64(num) / float64(den)
+				}
+			}
+		}
+	}
+	switch unit {
+	case 2:
+		info.dpi = resolution
+	case 3:
+		info.dpi = resolution * 2.54
+	}
+	return info
+}
+
+// orientationExif 生成只包含 IFD0 方向的最小 EXIF（TIFF 结构，小端序）
+func orientationExif(orientation int) []byte {
+	b := make([]byte, 26)
+	copy(b, "II*\x00")
+	binary.LittleEndian.PutUint32(b[4:], 8)
+	binary.LittleEndian.PutUint16(b[8:], 1)
+	binary.LittleEndian.PutUint16(b[10:], tagOrientation)
+	binary.LittleEndian.PutUint16(b[12:], 3)
+	binary.LittleEndian.PutUint32(b[14:], 1)
+	binary.LittleEndian.PutUint16(b[18:], uint16(orientation))
+	return b
+}
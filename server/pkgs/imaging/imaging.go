@@ -0,0 +1,151 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// MaxPixels 限制解码的像素数，避免超大图片耗尽内存。
+// 解码、校正方向与铺白底各占一份 RGBA，4000 万像素单张约需 500MB
+const MaxPixels = 40_000_000
+
+var ErrUnsupportedFormat = errors.New("unsupported image format")
+
+type Info struct {
+	Format string
+	// Width、Height 为校正方向后的尺寸
+	Width  int
+	Height int
+	// DPI 为 0 表示文件中没有分辨率信息
+	DPI float64
+	// Orientation 为原文件中的 EXIF 方向，1 表示无需旋转
+	Orientation int
+}
+
+// Rendition 描述一种缩略图规格，长边不超过 MaxSide，统一输出 JPEG
+type Rendition struct {
+	Name    string
+	MaxSide int
+	Quality int
+}
+
+type Result struct {
+	Info
+	// Original 为去除 EXIF、GPS 并校正方向后的原图，格式不变；原图无需改动时为 nil
+	Original   []byte
+	Renditions map[string][]byte
+}
+
+// Process 解码 JPEG、PNG、WebP 图片，记录真实尺寸与 DPI，去除元数据，校正方向并生成缩略图。
+// WebP 没有编码器，方向不为 1 时原图保留 EXIF 方向，缩略图仍会被校正
+func Process(raw []byte, renditions []Rendition) (*Result, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrUnsupportedFormat
+	}
+	if cfg.Width*cfg.Height > MaxPixels {
+		return nil, fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+	}
+
+	var meta metadata
+	switch format {
+	case "jpeg":
+		meta, err = readJPEG(raw)
+	case "png":
+		meta, err = readPNG(raw)
+	case "webp":
+		meta, err = readWebP(raw)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	img = orient(img, meta.exif.orientation)
+
+	result := &Result{
+		Info: Info{
+			Format:      format,
+			Width:       img.Bounds().Dx(),
+			Height:      img.Bounds().Dy(),
+			DPI:         meta.dpi,
+			Orientation: meta.exif.orientation,
+		},
+		Renditions: map[string][]byte{},
+	}
+	if !bytes.Equal(meta.stripped, raw) {
+		result.Original = meta.stripped
+	}
+	if meta.exif.dpi > 0 {
+		result.DPI = meta.exif.dpi
+	}
+
+	if meta.exif.orientation > 1 {
+		switch format {
+		case "jpeg":
+			if result.Original, err = encodeJPEG(img, 95); err != nil {
+				return nil, err
+			}
+		case "png":
+			buf := bytes.Buffer{}
+			if err := png.Encode(&buf, img); err != nil {
+				return nil, err
+			}
+			result.Original = buf.Bytes()
+		}
+	}
+
+	for _, r := range renditions {
+		encoded, err := encodeJPEG(fit(img, r.MaxSide), r.Quality)
+		if err != nil {
+			return nil, err
+		}
+		result.Renditions[r.Name] = encoded
+	}
+	return result, nil
+}
+
+// fit 等比缩放到长边不超过 maxSide，图片本身更小时不放大
+func fit(img image.Image, maxSide int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w <= maxSide && h <= maxSide {
+		return img
+	}
+	if w >= h {
+		h = max(1, h*maxSide/w)
+		w = maxSide
+	} else {
+		w = max(1, w*maxSide/h)
+		h = maxSide
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Src, nil)
+	return dst
+}
+
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	// JPEG 不支持透明，先铺白底
+	b := img.Bounds()
+	canvas := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(canvas, canvas.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(canvas, canvas.Bounds(), img, b.Min, draw.Over)
+
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, canvas, &jpeg.Options{Quality: quality}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,288 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// buildExif 生成 IFD0 含方向与分辨率的 EXIF，unit 为 2 表示英寸、3 表示厘米
+func buildExif(order binary.ByteOrder, orientation int, resolution uint32, unit int) []byte {
+	b := []byte("Exif\x00\x00")
+	tiff := make([]byte, 8+2+3*12+4+8)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 3)
+	entry := func(i int, tag, typ uint16, value uint32) {
+		e := tiff[10+i*12:]
+		order.PutUint16(e, tag)
+		order.PutUint16(e[2:], typ)
+		order.PutUint32(e[4:], 1)
+		if typ == 3 {
+			order.PutUint16(e[8:], uint16(value))
+		} else {
+			order.PutUint32(e[8:], value)
+		}
+	}
+	rational := 8 + 2 + 3*12 + 4
+	entry(0, tagOrientation, 3, uint32(orientation))
+	entry(1, tagXResolution, 5, uint32(rational))
+	entry(2, tagResolutionUnit, 3, uint32(unit))
+	order.PutUint32(tiff[rational:], resolution)
+	order.PutUint32(tiff[rational+4:], 1)
+	return append(b, tiff...)
+}
+
+func TestParseExif(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  []byte
+		want exifInfo
+	}{
+		{name: "little endian", raw: buildExif(binary.LittleEndian, 6, 300, 2), want: exifInfo{orientation: 6, dpi: 300}},
+		{name: "big endian", raw: buildExif(binary.BigEndian, 3, 72, 2), want: exifInfo{orientation: 3, dpi: 72}},
+		{name: "centimeters", raw: buildExif(binary.LittleEndian, 1, 100, 3), want: exifInfo{orientation: 1, dpi: 254}},
+		{name: "invalid orientation", raw: buildExif(binary.LittleEndian, 9, 72, 2), want: exifInfo{orientation: 1, dpi: 72}},
+		{name: "truncated", raw: buildExif(binary.LittleEndian, 6, 300, 2)[:20], want: exifInfo{orientation: 1}},
+		{name: "unknown byte order", raw: []byte("Exif\x00\x00XX*\x00\x08\x00\x00\x00"), want: exifInfo{orientation: 1}},
+		{name: "empty", want: exifInfo{orientation: 1}},
+	}
+	for _, tt := range tests {
+		if got := parseExif(tt.raw); got != tt.want {
+			t.Errorf("%s: parseExif = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// 2x3 的图像，每个像素的颜色不同
+	src := image.NewRGBA(image.Rect(0, 0, 2, 3))
+	for y := 0; y < 3; y++ {
+		for x := 0; x < 2; x++ {
+			src.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), A: 255})
+		}
+	}
+	// 原图左上角像素 (0,0) 在各方向校正后的位置
+	tests := []struct {
+		orientation int
+		w, h        int
+		x, y        int
+	}{
+		{1, 2, 3, 0, 0},
+		{2, 2, 3, 1, 0},
+		{3, 2, 3, 1, 2},
+		{4, 2, 3, 0, 2},
+		{5, 3, 2, 0, 0},
+		{6, 3, 2, 2, 0},
+		{7, 3, 2, 2, 1},
+		{8, 3, 2, 0, 1},
+		{9, 2, 3, 0, 0},
+	}
+	for _, tt := range tests {
+		got := orient(src, tt.orientation)
+		if b := got.Bounds(); b.Dx() != tt.w || b.Dy() != tt.h {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation, b.Dx(), b.Dy(), tt.w, tt.h)
+			continue
+		}
+		if r, g, _, _ := got.At(tt.x, tt.y).RGBA(); r != 0 || g != 0 {
+			t.Errorf("orientation %d: pixel (0,0) not at (%d,%d)", tt.orientation, tt.x, tt.y)
+		}
+	}
+}
+
+func TestFit(t *testing.T) {
+	tests := []struct {
+		w, h, maxSide int
+		wantW, wantH  int
+	}{
+		{100, 50, 200, 100, 50},
+		{400, 200, 200, 200, 100},
+		{200, 400, 100, 50, 100},
+		{1000, 1, 100, 100, 1},
+		{300, 300, 300, 300, 300},
+	}
+	for _, tt := range tests {
+		b := fit(image.NewRGBA(image.Rect(0, 0, tt.w, tt.h)), tt.maxSide).Bounds()
+		if b.Dx() != tt.wantW || b.Dy() != tt.wantH {
+			t.Errorf("fit %dx%d to %d = %dx%d, want %dx%d", tt.w, tt.h, tt.maxSide, b.Dx(), b.Dy(), tt.wantW, tt.wantH)
+		}
+	}
+}
+
+func testImage(w, h int) image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	return img
+}
+
+func TestProcessJPEG(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, testImage(40, 20), nil); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	// 在 SOI 之后插入 APP1 EXIF，方向为 6（顺时针旋转 90 度）
+	exif := buildExif(binary.BigEndian, 6, 300, 2)
+	app1 := append([]byte{0xFF, 0xE1}, binary.BigEndian.AppendUint16(nil, uint16(len(exif)+2))...)
+	raw := append(append(append([]byte{}, encoded[:2]...), append(app1, exif...)...), encoded[2:]...)
+
+	result, err := Process(raw, []Rendition{{Name: "thumb", MaxSide: 10, Quality: 80}})
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if result.Format != "jpeg" || result.Width != 20 || result.Height != 40 || result.Orientation != 6 || result.DPI != 300 {
+		t.Fatalf("info = %+v", result.Info)
+	}
+	if result.Original == nil || bytes.Contains(result.Original, []byte("Exif\x00\x00")) {
+		t.Fatal("original still contains EXIF")
+	}
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(result.Original))
+	if err != nil || cfg.Width != 20 || cfg.Height != 40 {
+		t.Fatalf("original = %dx%d, %v; want 20x40", cfg.Width, cfg.Height, err)
+	}
+	cfg, err = jpeg.DecodeConfig(bytes.NewReader(result.Renditions["thumb"]))
+	if err != nil || cfg.Width != 5 || cfg.Height != 10 {
+		t.Fatalf("rendition = %dx%d, %v; want 5x10", cfg.Width, cfg.Height, err)
+	}
+}
+
+// pngChunk 生成带 CRC 的 PNG chunk
+func pngChunk(typ string, data []byte) []byte {
+	b := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	b = append(b, typ...)
+	b = append(b, data...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b[4:]))
+}
+
+func TestProcessPNG(t *testing.T) {
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, testImage(30, 10)); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+	// IHDR 固定为 25 字节，之后插入 pHYs（每米 11811 像素，约 300 DPI）与文本
+	ihdrEnd := len(pngSignature) + 25
+	phys := binary.BigEndian.AppendUint32(nil, 11811)
+	phys = binary.BigEndian.AppendUint32(phys, 11811)
+	phys = append(phys, 1)
+	raw := append([]byte{}, encoded[:ihdrEnd]...)
+	raw = append(raw, pngChunk("pHYs", phys)...)
+	raw = append(raw, pngChunk("tEXt", []byte("Comment\x00secret"))...)
+	raw = append(raw, encoded[ihdrEnd:]...)
+
+	result, err := Process(raw, nil)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if result.Format != "png" || result.Width != 30 || result.Height != 10 || result.Orientation != 1 {
+		t.Fatalf("info = %+v", result.Info)
+	}
+	if result.DPI < 299.9 || result.DPI > 300.1 {
+		t.Fatalf("dpi = %v, want about 300", result.DPI)
+	}
+	if result.Original == nil || bytes.Contains(result.Original, []byte("secret")) {
+		t.Fatal("text chunk was not stripped")
+	}
+	if !bytes.Contains(result.Original, []byte("pHYs")) {
+		t.Fatal("pHYs chunk was stripped")
+	}
+	if _, err := png.Decode(bytes.NewReader(result.Original)); err != nil {
+		t.Fatalf("stripped png is invalid: %v", err)
+	}
+
+	// 没有需要去除的内容时不返回原图
+	result, err = Process(encoded, nil)
+	if err != nil || result.Original != nil {
+		t.Fatalf("original = %d bytes, %v; want nil", len(result.Original), err)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	if _, err := Process([]byte("GIF89a not really"), nil); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("err = %v, want ErrUnsupportedFormat", err)
+	}
+
+	// 只有 IHDR 的 PNG 即可让 DecodeConfig 得到尺寸
+	ihdr := binary.BigEndian.AppendUint32(nil, 10000)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 10000)
+	ihdr = append(ihdr, 8, 2, 0, 0, 0)
+	raw := append(append([]byte{}, pngSignature...), pngChunk("IHDR", ihdr)...)
+	if _, err := Process(raw, nil); err == nil || errors.Is(err, ErrUnsupportedFormat) {
+		t.Fatalf("err = %v, want image too large", err)
+	}
+}
+
+// webpChunk 生成 RIFF chunk，奇数长度补齐一个字节
+func webpChunk(fourcc string, data []byte) []byte {
+	b := append([]byte(fourcc), binary.LittleEndian.AppendUint32(nil, uint32(len(data)))...)
+	b = append(b, data...)
+	if len(data)%2 == 1 {
+		b = append(b, 0)
+	}
+	return b
+}
+
+func buildWebP(chunks ...[]byte) []byte {
+	b := []byte("RIFF\x00\x00\x00\x00WEBP")
+	for _, c := range chunks {
+		b = append(b, c...)
+	}
+	binary.LittleEndian.PutUint32(b[4:], uint32(len(b)-8))
+	return b
+}
+
+func TestReadWebP(t *testing.T) {
+	vp8x := make([]byte, 10)
+	// 0x08 为 EXIF 标志，0x04 为 XMP 标志
+	vp8x[0] = 0x0C
+	bitstream := webpChunk("VP8L", []byte{1, 2, 3})
+
+	tests := []struct {
+		name        string
+		orientation int
+		flags       byte
+	}{
+		{name: "keeps orientation", orientation: 6, flags: 0x08},
+		{name: "drops exif", orientation: 1, flags: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			raw := buildWebP(
+				webpChunk("VP8X", vp8x),
+				webpChunk("EXIF", buildExif(binary.LittleEndian, tt.orientation, 300, 2)[6:]),
+				webpChunk("XMP ", []byte("<x:xmpmeta>secret</x:xmpmeta>")),
+				bitstream,
+			)
+			m, err := readWebP(raw)
+			if err != nil {
+				t.Fatalf("readWebP: %v", err)
+			}
+			if m.exif.orientation != tt.orientation {
+				t.Fatalf("orientation = %d, want %d", m.exif.orientation, tt.orientation)
+			}
+			want := [][]byte{webpChunk("VP8X", append([]byte{tt.flags}, vp8x[1:]...))}
+			if tt.orientation > 1 {
+				want = append(want, webpChunk("EXIF", orientationExif(tt.orientation)))
+			}
+			want = append(want, bitstream)
+			if expected := buildWebP(want...); !bytes.Equal(m.stripped, expected) {
+				t.Fatalf("stripped = %q, want %q", m.stripped, expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+var errMalformed = errors.New("malformed image")
+
+// metadata 为从文件结构中读取的元信息，以及去除 EXIF、GPS 等隐私信息后的文件
+type metadata struct {
+	exif     exifInfo
+	dpi      float64
+	stripped []byte
+}
+
+// readJPEG 逐段解析 JPEG，丢弃 APP1（EXIF、XMP）与 APP13（IPTC），保留 ICC 等其余数据
+func readJPEG(b []byte) (metadata, error) {
+	m := metadata{exif: exifInfo{orientation: 1}}
+	if len(b) < 4 || b[0] != 0xFF || b[1] != 0xD8 {
+		return m, errMalformed
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(b)))
+	out.Write(b[:2])
+	i := 2
+	for i+4 <= len(b) {
+		if b[i] != 0xFF {
+			return m, errMalformed
+		}
+		marker := b[i+1]
+		if marker == 0xDA {
+			// SOS 之后为压缩数据，原样保留
+			out.Write(b[i:])
+			m.stripped = out.Bytes()
+			return m, nil
+		}
+		length := int(binary.BigEndian.Uint16(b[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(b) {
+			return m, errMalformed
+		}
+		payload := b[i+4 : end]
+		switch marker {
+		case 0xE0:
+			if bytes.HasPrefix(payload, []byte("JFIF\x00")) && len(payload) >= 12 && m.dpi == 0 {
+				density := float64(binary.BigEndian.Uint16(payload[8:]))
+				switch payload[7] {
+				case 1:
+					m.dpi = density
+				case 2:
+					m.dpi = density * 2.54
+				}
+			}
+			out.Write(b[i:end])
+		case 0xE1:
+			if bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+				m.exif = parseExif(payload)
+			}
+		case 0xED:
+		default:
+			out.Write(b[i:end])
+		}
+		i = end
+	}
+	return m, errMalformed
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// readPNG 丢弃 eXIf 与文本类 chunk，其余 chunk 连同 CRC 原样保留
+func readPNG(b []byte) (metadata, error) {
+	m := metadata{exif: exifInfo{orientation: 1}}
+	if !bytes.HasPrefix(b, pngSignature) {
+		return m, errMalformed
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(b)))
+	out.Write(pngSignature)
+	i := len(pngSignature)
+	for i+12 <= len(b) {
+		length := int(binary.BigEndian.Uint32(b[i:]))
+		typ := string(b[i+4 : i+8])
+		end := i + 12 + length
+		if end > len(b) {
+			return m, errMalformed
+		}
+		data := b[i+8 : i+8+length]
+		switch typ {
+		case "eXIf":
+			m.exif = parseExif(data)
+		case "tEXt", "zTXt", "iTXt", "tIME":
+		case "pHYs":
+			if len(data) == 9 && data[8] == 1 {
+				m.dpi = float64(binary.BigEndian.Uint32(data)) * 0.0254
+			}
+			out.Write(b[i:end])
+		default:
+			out.Write(b[i:end])
+		}
+		i = end
+		if typ == "IEND" {
+			m.stripped = out.Bytes()
+			return m, nil
+		}
+	}
+	return m, errMalformed
+}
+
+// readWebP 丢弃 XMP chunk，EXIF chunk 只保留方向，并同步清除 VP8X 中对应的标志位
+func readWebP(b []byte) (metadata, error) {
+	m := metadata{exif: exifInfo{orientation: 1}}
+	if len(b) < 12 || string(b[:4]) != "RIFF" || string(b[8:12]) != "WEBP" {
+		return m, errMalformed
+	}
+	out := bytes.NewBuffer(make([]byte, 0, len(b)))
+	out.Write(b[:12])
+	vp8x := -1
+	i := 12
+	for i+8 <= len(b) {
+		fourcc := string(b[i : i+4])
+		length := int(binary.LittleEndian.Uint32(b[i+4:]))
+		end := i + 8 + length + length%2
+		if end > len(b) {
+			end = len(b)
+		}
+		switch fourcc {
+		case "EXIF":
+			m.exif = parseExif(b[i+8 : min(i+8+length, len(b))])
+			// WebP 无法重新编码，原图只保留方向，否则去除 EXIF 后显示方向会出错
+			if m.exif.orientation > 1 {
+				orientation := orientationExif(m.exif.orientation)
+				out.WriteString("EXIF")
+				out.Write(binary.LittleEndian.AppendUint32(nil, uint32(len(orientation))))
+				out.Write(orientation)
+			}
+		case "XMP ":
+		case "VP8X":
+			vp8x = out.Len()
+			out.Write(b[i:end])
+		default:
+			out.Write(b[i:end])
+		}
+		i = end
+	}
+	stripped := out.Bytes()
+	if vp8x >= 0 && vp8x+9 <= len(stripped) {
+		stripped[vp8x+8] &^= 0x04
+		if m.exif.orientation == 1 {
+			stripped[vp8x+8] &^= 0x08
+		}
+	}
+	binary.LittleEndian.PutUint32(stripped[4:], uint32(len(stripped)-8))
+	m.stripped = stripped
+	return m, nil
+}
@@ -0,0 +1,281 @@
+// Package jsonpatch 实现 RFC 6902 JSON Patch，作用于 encoding/json 解码得到的 map[string]any、[]any 文档
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Operation 为一条补丁操作，from 仅用于 move 与 copy
+type Operation struct {
+	Op    string `json:"op" binding:"required,oneof=add remove replace move copy test"`
+	Path  string `json:"path"`
+	From  string `json:"from,omitempty"`
+	Value any    `json:"value,omitempty"`
+}
+
+// Error 为第 Index 条操作执行失败
+type Error struct {
+	Index  int
+	Op     Operation
+	Reason string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("patch[%d] %s %s: %s", e.Index, e.Op.Op, e.Op.Path, e.Reason)
+}
+
+// Apply 依次执行 ops 并返回修改后的文档，任一操作失败时返回 *Error，doc 可能已被部分修改
+func Apply(doc any, ops []Operation) (any, error) {
+	var err error
+	for i, op := range ops {
+		if doc, err = apply(doc, op); err != nil {
+			return nil, &Error{Index: i, Op: op, Reason: err.Error()}
+		}
+	}
+	return doc, nil
+}
+
+func apply(doc any, op Operation) (any, error) {
+	switch op.Op {
+	case "add":
+		return add(doc, op.Path, clone(op.Value))
+	case "remove":
+		doc, _, err := remove(doc, op.Path)
+		return doc, err
+	case "replace":
+		if _, err := get(doc, op.Path); err != nil {
+			return nil, err
+		}
+		doc, _, err := remove(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, clone(op.Value))
+	case "move":
+		if op.Path != op.From && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, fmt.Errorf("cannot move %s into its own child", op.From)
+		}
+		doc, value, err := remove(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, value)
+	case "copy":
+		value, err := get(doc, op.From)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, op.Path, clone(value))
+	case "test":
+		value, err := get(doc, op.Path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(normalize(value), normalize(op.Value)) {
+			return nil, fmt.Errorf("test failed")
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("unknown op")
+}
+
+// parsePointer 将 JSON Pointer 拆分为引用记号，空字符串表示整个文档
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid pointer %q", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// arrayIndex 解析数组下标，allowEnd 为 true 时允许 len 与 "-" 表示末尾
+func arrayIndex(token string, length int, allowEnd bool) (int, error) {
+	if token == "-" && allowEnd {
+		return length, nil
+	}
+	// 下标只能是不带符号与前导零的十进制数，strconv.Atoi 会接受 "+1" 与 "-0"
+	if token == "" || (len(token) > 1 && token[0] == '0') || strings.Trim(token, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > length || (i == length && !allowEnd) {
+		return 0, fmt.Errorf("array index %d out of range", i)
+	}
+	return i, nil
+}
+
+func get(doc any, pointer string) (any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	node := doc
+	for _, token := range tokens {
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[token]
+			if !ok {
+				return nil, fmt.Errorf("path %s does not exist", pointer)
+			}
+			node = child
+		case []any:
+			i, err := arrayIndex(token, len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			node = n[i]
+		default:
+			return nil, fmt.Errorf("path %s does not exist", pointer)
+		}
+	}
+	return node, nil
+}
+
+// update 找到 pointer 的父容器后调用 fn，fn 返回修改后的父容器，数组长度变化时逐级写回
+func update(doc any, pointer string, fn func(parent any, token string) (any, error)) (any, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("cannot modify the whole document")
+	}
+	var walk func(node any, tokens []string) (any, error)
+	walk = func(node any, tokens []string) (any, error) {
+		if len(tokens) == 1 {
+			return fn(node, tokens[0])
+		}
+		switch n := node.(type) {
+		case map[string]any:
+			child, ok := n[tokens[0]]
+			if !ok {
+				return nil, fmt.Errorf("path %s does not exist", pointer)
+			}
+			child, err := walk(child, tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			n[tokens[0]] = child
+			return n, nil
+		case []any:
+			i, err := arrayIndex(tokens[0], len(n), false)
+			if err != nil {
+				return nil, err
+			}
+			child, err := walk(n[i], tokens[1:])
+			if err != nil {
+				return nil, err
+			}
+			n[i] = child
+			return n, nil
+		}
+		return nil, fmt.Errorf("path %s does not exist", pointer)
+	}
+	return walk(doc, tokens)
+}
+
+func add(doc any, pointer string, value any) (any, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	return update(doc, pointer, func(parent any, token string) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			p[token] = value
+			return p, nil
+		case []any:
+			i, err := arrayIndex(token, len(p), true)
+			if err != nil {
+				return nil, err
+			}
+			p = append(p, nil)
+			copy(p[i+1:], p[i:])
+			p[i] = value
+			return p, nil
+		}
+		return nil, fmt.Errorf("parent of %s is not a container", pointer)
+	})
+}
+
+func remove(doc any, pointer string) (any, any, error) {
+	var removed any
+	doc, err := update(doc, pointer, func(parent any, token string) (any, error) {
+		switch p := parent.(type) {
+		case map[string]any:
+			value, ok := p[token]
+			if !ok {
+				return nil, fmt.Errorf("path %s does not exist", pointer)
+			}
+			removed = value
+			delete(p, token)
+			return p, nil
+		case []any:
+			i, err := arrayIndex(token, len(p), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = p[i]
+			return append(p[:i:i], p[i+1:]...), nil
+		}
+		return nil, fmt.Errorf("path %s does not exist", pointer)
+	})
+	return doc, removed, err
+}
+
+// clone 深拷贝值，避免同一个值同时出现在文档的两个位置
+func clone(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, child := range v {
+			m[k] = clone(child)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, child := range v {
+			s[i] = clone(child)
+		}
+		return s
+	}
+	return value
+}
+
+// normalize 统一数字的表示，使 json.Number 与 float64 可以比较
+func normalize(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, child := range v {
+			m[k] = normalize(child)
+		}
+		return m
+	case []any:
+		s := make([]any, len(v))
+		for i, child := range v {
+			s[i] = normalize(child)
+		}
+		return s
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	}
+	return value
+}
@@ -0,0 +1,69 @@
+package layout
+
+import "errors"
+
+// Policy 为图片放入空位的方式
+type Policy string
+
+const (
+	// Fit 等比缩放至完整放入空位并居中，空位可能留白
+	Fit Policy = "fit"
+	// Fill 拉伸至与空位同大，图片可能变形
+	Fill Policy = "fill"
+	// Cover 等比缩放至铺满空位，超出部分居中裁掉
+	Cover Policy = "cover"
+)
+
+var ErrInvalidSize = errors.New("layout: width and height must be positive")
+
+// Rect 为页面坐标系中的矩形，单位与模板页面一致
+type Rect struct {
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+}
+
+// Placement 为图片在页面上的位置，Crop 为相对原图宽高比例的裁剪框，为空表示不裁剪
+type Placement struct {
+	Box  Rect
+	Crop *Rect
+}
+
+// Place 按 policy 将 width x height 像素的图片放入 slot
+func Place(slot Rect, width, height int, policy Policy) (Placement, error) {
+	if slot.Width <= 0 || slot.Height <= 0 || width <= 0 || height <= 0 {
+		return Placement{}, ErrInvalidSize
+	}
+	imageRatio := float64(width) / float64(height)
+	slotRatio := slot.Width / slot.Height
+
+	switch policy {
+	case Fill:
+		return Placement{Box: slot}, nil
+	case Cover:
+		crop := Rect{Width: 1, Height: 1}
+		if imageRatio > slotRatio {
+			crop.Width = slotRatio / imageRatio
+			crop.X = (1 - crop.Width) / 2
+		} else if imageRatio < slotRatio {
+			crop.Height = imageRatio / slotRatio
+			crop.Y = (1 - crop.Height) / 2
+		}
+		placement := Placement{Box: slot}
+		if crop.Width < 1 || crop.Height < 1 {
+			placement.Crop = &crop
+		}
+		return placement, nil
+	default:
+		box := slot
+		if imageRatio > slotRatio {
+			box.Height = slot.Width / imageRatio
+			box.Y += (slot.Height - box.Height) / 2
+		} else {
+			box.Width = slot.Height * imageRatio
+			box.X += (slot.Width - box.Width) / 2
+		}
+		return Placement{Box: box}, nil
+	}
+}
@@ -0,0 +1,93 @@
+package layout
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestPlace(t *testing.T) {
+	square := Rect{Width: 100, Height: 100}
+	tests := []struct {
+		name          string
+		slot          Rect
+		width, height int
+		policy        Policy
+		box           Rect
+		crop          *Rect
+	}{
+		{name: "fit wide image", slot: square, width: 200, height: 100, policy: Fit,
+			box: Rect{Y: 25, Width: 100, Height: 50}},
+		{name: "fit tall image", slot: square, width: 100, height: 200, policy: Fit,
+			box: Rect{X: 25, Width: 50, Height: 100}},
+		{name: "fit same ratio", slot: square, width: 300, height: 300, policy: Fit, box: square},
+		{name: "fit keeps slot offset", slot: Rect{X: 10, Y: 20, Width: 100, Height: 50}, width: 100, height: 100, policy: Fit,
+			box: Rect{X: 35, Y: 20, Width: 50, Height: 50}},
+		{name: "empty policy fits", slot: square, width: 200, height: 100,
+			box: Rect{Y: 25, Width: 100, Height: 50}},
+		{name: "fit extreme wide image", slot: square, width: 10000, height: 1, policy: Fit,
+			box: Rect{Y: 49.995, Width: 100, Height: 0.01}},
+		{name: "fit extreme tall image", slot: square, width: 1, height: 10000, policy: Fit,
+			box: Rect{X: 49.995, Width: 0.01, Height: 100}},
+		{name: "fill stretches", slot: square, width: 200, height: 100, policy: Fill, box: square},
+		{name: "fill extreme image", slot: square, width: 1, height: 10000, policy: Fill, box: square},
+		{name: "cover wide image", slot: square, width: 200, height: 100, policy: Cover, box: square,
+			crop: &Rect{X: 0.25, Width: 0.5, Height: 1}},
+		{name: "cover tall image", slot: square, width: 100, height: 200, policy: Cover, box: square,
+			crop: &Rect{Y: 0.25, Width: 1, Height: 0.5}},
+		{name: "cover same ratio", slot: square, width: 50, height: 50, policy: Cover, box: square},
+		{name: "cover extreme wide image", slot: square, width: 10000, height: 1, policy: Cover, box: square,
+			crop: &Rect{X: 0.49995, Width: 0.0001, Height: 1}},
+		{name: "cover extreme wide slot", slot: Rect{Width: 10000, Height: 1}, width: 100, height: 100, policy: Cover,
+			box: Rect{Width: 10000, Height: 1}, crop: &Rect{Y: 0.49995, Width: 1, Height: 0.0001}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Place(tt.slot, tt.width, tt.height, tt.policy)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !rectEqual(got.Box, tt.box) {
+				t.Errorf("box = %+v, want %+v", got.Box, tt.box)
+			}
+			switch {
+			case tt.crop == nil && got.Crop != nil:
+				t.Errorf("crop = %+v, want nil", *got.Crop)
+			case tt.crop != nil && got.Crop == nil:
+				t.Errorf("crop = nil, want %+v", *tt.crop)
+			case tt.crop != nil && !rectEqual(*got.Crop, *tt.crop):
+				t.Errorf("crop = %+v, want %+v", *got.Crop, *tt.crop)
+			}
+		})
+	}
+}
+
+func TestPlaceInvalidSize(t *testing.T) {
+	tests := []struct {
+		name          string
+		slot          Rect
+		width, height int
+	}{
+		{name: "zero slot width", slot: Rect{Height: 100}, width: 100, height: 100},
+		{name: "zero slot height", slot: Rect{Width: 100}, width: 100, height: 100},
+		{name: "negative slot", slot: Rect{Width: -100, Height: 100}, width: 100, height: 100},
+		{name: "zero image width", slot: Rect{Width: 100, Height: 100}, height: 100},
+		{name: "zero image height", slot: Rect{Width: 100, Height: 100}, width: 100},
+		{name: "negative image", slot: Rect{Width: 100, Height: 100}, width: -1, height: 100},
+	}
+	for _, tt := range tests {
+		for _, policy := range []Policy{Fit, Fill, Cover} {
+			t.Run(tt.name+"/"+string(policy), func(t *testing.T) {
+				if _, err := Place(tt.slot, tt.width, tt.height, policy); !errors.Is(err, ErrInvalidSize) {
+					t.Fatalf("err = %v, want ErrInvalidSize", err)
+				}
+			})
+		}
+	}
+}
+
+func rectEqual(a, b Rect) bool {
+	const epsilon = 1e-9
+	return math.Abs(a.X-b.X) < epsilon && math.Abs(a.Y-b.Y) < epsilon &&
+		math.Abs(a.Width-b.Width) < epsilon && math.Abs(a.Height-b.Height) < epsilon
+}
@@ -8,28 +8,36 @@ import (
 	"github.com/Fl0rencess720/Springboard/consts"
 
 	"github.com/natefinch/lumberjack"
-	"github.com/spf13/viper"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
-func Init(logFilePath string) {
+var level = zap.NewAtomicLevel()
+
+// Init 初始化全局 logger，level 为空时 dev 模式使用 debug，其余使用 error
+func Init(logFilePath string, dev bool, lvl string) {
 
 	if logFilePath == "" {
 		logFilePath = consts.DefaultLogFilePath
 	}
+	SetLevel(dev, lvl)
 
 	zap.ReplaceGlobals(zap.New(
 		zapcore.NewCore(
 			getEncoder(),
-			getWriteSyncer(logFilePath),
-			getLogLevel(),
+			getWriteSyncer(logFilePath, dev),
+			level,
 		),
 		zap.AddCaller(),
 	))
 
 }
 
+// SetLevel 可在运行时调整日志级别
+func SetLevel(dev bool, lvl string) {
+	level.SetLevel(getLogLevel(dev, lvl))
+}
+
 func Sync(l *zap.Logger) {
 	err := l.Sync()
 	if err != nil {
@@ -46,15 +54,18 @@ func getEncoder() zapcore.Encoder {
 	return zapcore.NewJSONEncoder(encoderConfig)
 }
 
-func getLogLevel() zapcore.Level {
-	if viper.GetString("project.mode") == "dev" {
+func getLogLevel(dev bool, lvl string) zapcore.Level {
+	if l, err := zapcore.ParseLevel(lvl); err == nil && lvl != "" {
+		return l
+	}
+	if dev {
 		return zapcore.DebugLevel
 	} else {
 		return zapcore.ErrorLevel
 	}
 }
 
-func getWriteSyncer(logFilePath string) zapcore.WriteSyncer {
+func getWriteSyncer(logFilePath string, dev bool) zapcore.WriteSyncer {
 	lumberJackLogger := &lumberjack.Logger{
 		Filename:   logFilePath,
 		MaxSize:    5,
@@ -63,7 +74,7 @@ func getWriteSyncer(logFilePath string) zapcore.WriteSyncer {
 		Compress:   true,
 	}
 
-	if viper.GetString("project.mode") == "dev" {
+	if dev {
 		return zapcore.AddSync(io.MultiWriter(os.Stdout, lumberJackLogger))
 	}
 	return zapcore.AddSync(lumberJackLogger)
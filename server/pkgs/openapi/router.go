@@ -0,0 +1,162 @@
+package openapi
+
+import (
+	"net/http"
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Operation 为路由声明的元数据
+type Operation struct {
+	Summary     string
+	Description string
+	Query       []Param
+	// Body 为请求体类型的零值，nil 表示无请求体
+	Body any
+	// Response 为响应中 data 字段类型的零值，nil 表示 data 为 null
+	Response any
+	// Before 在参数校验之前执行，例如限流，格式错误的请求同样需要计数
+	Before []gin.HandlerFunc
+}
+
+// Param 描述 query 参数，Type 取值 string、integer、number、boolean
+type Param struct {
+	Name        string
+	Description string
+	Type        string
+	Required    bool
+	Enum        []string
+	Default     string
+}
+
+// Router 包装 gin.RouterGroup，注册路由的同时写入文档并挂载校验中间件
+type Router struct {
+	spec    *Spec
+	group   *gin.RouterGroup
+	tag     string
+	secured bool
+}
+
+// Router 创建顶层路由，secured 表示该组路由需要 Bearer Token
+func (s *Spec) Router(group *gin.RouterGroup, tag string, secured bool) *Router {
+	return &Router{spec: s, group: group, tag: tag, secured: secured}
+}
+
+func (r *Router) Group(relativePath, tag string, handlers ...gin.HandlerFunc) *Router {
+	return &Router{spec: r.spec, group: r.group.Group(relativePath, handlers...), tag: tag, secured: r.secured}
+}
+
+func (r *Router) GET(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodGet, relativePath, op, handlers)
+}
+
+func (r *Router) POST(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPost, relativePath, op, handlers)
+}
+
+func (r *Router) PUT(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPut, relativePath, op, handlers)
+}
+
+func (r *Router) PATCH(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodPatch, relativePath, op, handlers)
+}
+
+func (r *Router) DELETE(relativePath string, op Operation, handlers ...gin.HandlerFunc) {
+	r.handle(http.MethodDelete, relativePath, op, handlers)
+}
+
+func (r *Router) handle(method, relativePath string, op Operation, handlers []gin.HandlerFunc) {
+	fullPath := joinPaths(r.group.BasePath(), relativePath)
+	obj := &OperationObject{
+		Summary:     op.Summary,
+		Description: op.Description,
+		Responses: map[string]ResponseObject{
+			"200": {
+				Description: "成功",
+				Content:     map[string]MediaType{"application/json": {Schema: r.spec.envelope(op.Response)}},
+			},
+			"default": {
+				Description: "失败",
+				Content:     map[string]MediaType{"application/json": {Schema: &Schema{Ref: refPrefix + "Error"}}},
+			},
+		},
+	}
+	if r.tag != "" {
+		obj.Tags = []string{r.tag}
+	}
+	if r.secured {
+		obj.Security = []map[string][]string{{bearerAuth: {}}}
+	}
+
+	docPath, pathParams := convertPath(fullPath)
+	for _, name := range pathParams {
+		obj.Parameters = append(obj.Parameters, ParameterObject{Name: name, In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	for _, p := range op.Query {
+		obj.Parameters = append(obj.Parameters, ParameterObject{
+			Name:        p.Name,
+			In:          "query",
+			Description: p.Description,
+			Required:    p.Required,
+			Schema:      p.schema(),
+		})
+	}
+
+	var bodySchema *Schema
+	if op.Body != nil {
+		bodySchema = r.spec.schemaOf(reflect.TypeOf(op.Body))
+		obj.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]MediaType{"application/json": {Schema: bodySchema}},
+		}
+	}
+	r.spec.addOperation(strings.ToLower(method), docPath, obj)
+
+	validator := r.spec.validator(op.Query, bodySchema)
+	chain := append(append(op.Before[:len(op.Before):len(op.Before)], validator), handlers...)
+	r.group.Handle(method, relativePath, chain...)
+}
+
+func (p Param) schema() *Schema {
+	typ := p.Type
+	if typ == "" {
+		typ = "string"
+	}
+	schema := &Schema{Type: typ}
+	for _, v := range p.Enum {
+		schema.Enum = append(schema.Enum, enumValue(typ, v))
+	}
+	if p.Default != "" {
+		schema.Description = "默认值：" + p.Default
+	}
+	return schema
+}
+
+// joinPaths 与 gin 一致，保留末尾的 /
+func joinPaths(absolutePath, relativePath string) string {
+	if relativePath == "" {
+		return absolutePath
+	}
+	finalPath := path.Join(absolutePath, relativePath)
+	if strings.HasSuffix(relativePath, "/") && !strings.HasSuffix(finalPath, "/") {
+		return finalPath + "/"
+	}
+	return finalPath
+}
+
+// convertPath 将 gin 的 :name、*name 转换为 OpenAPI 的 {name}
+func convertPath(p string) (string, []string) {
+	segments := strings.Split(p, "/")
+	params := []string{}
+	for i, seg := range segments {
+		if strings.HasPrefix(seg, ":") || strings.HasPrefix(seg, "*") {
+			params = append(params, seg[1:])
+			segments[i] = "{" + seg[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), params
+}
@@ -0,0 +1,170 @@
+package openapi
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+
+	// omitEmpty 对应 binding 中的 omitempty，零值跳过 enum 与范围校验
+	omitEmpty bool
+}
+
+const refPrefix = "#/components/schemas/"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// schemaOf 通过反射生成结构，具名结构体注册到 components 中并返回引用。
+// 字段名取自 json tag，binding tag 中的 required、oneof、min、max 会写入结构约束
+func (s *Spec) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		name := s.componentName(t)
+		if _, ok := s.doc.Components.Schemas[name]; !ok {
+			// 先占位，避免自引用类型无限递归
+			s.doc.Components.Schemas[name] = &Schema{}
+			*s.doc.Components.Schemas[name] = *s.structSchema(t)
+		}
+		return &Schema{Ref: refPrefix + name}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schemaOf(t.Elem())}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	default:
+		return &Schema{}
+	}
+}
+
+// componentName 使用类型名，不同包的同名类型加上包名区分
+func (s *Spec) componentName(t reflect.Type) string {
+	name := t.Name()
+	if existing, ok := s.types[name]; ok && existing != t {
+		pkg := t.PkgPath()
+		name = pkg[strings.LastIndex(pkg, "/")+1:] + "." + name
+	}
+	s.types[name] = t
+	return name
+}
+
+func (s *Spec) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			for embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				inner := s.structSchema(embedded)
+				for k, v := range inner.Properties {
+					schema.Properties[k] = v
+				}
+				schema.Required = append(schema.Required, inner.Required...)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		prop := s.schemaOf(f.Type)
+		// $ref 的兄弟字段会被忽略，因此只给内联结构附加描述
+		if doc := f.Tag.Get("doc"); doc != "" && prop.Ref == "" {
+			prop.Description = doc
+		}
+		if applyBinding(prop, f.Tag.Get("binding")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+	return schema
+}
+
+// applyBinding 将 binding tag 中能表达的规则写入结构，返回字段是否必填
+func applyBinding(schema *Schema, tag string) bool {
+	required := false
+	for _, rule := range strings.Split(tag, ",") {
+		key, value, _ := strings.Cut(rule, "=")
+		switch key {
+		case "required":
+			required = true
+		case "omitempty":
+			schema.omitEmpty = true
+		case "oneof":
+			for _, v := range strings.Fields(value) {
+				schema.Enum = append(schema.Enum, enumValue(schema.Type, v))
+			}
+		case "min", "gte":
+			if n, err := strconv.ParseFloat(value, 64); err == nil && isNumeric(schema.Type) {
+				schema.Minimum = &n
+			}
+		case "max", "lte":
+			if n, err := strconv.ParseFloat(value, 64); err == nil && isNumeric(schema.Type) {
+				schema.Maximum = &n
+			}
+		}
+	}
+	return required
+}
+
+func isNumeric(typ string) bool {
+	return typ == "integer" || typ == "number"
+}
+
+func enumValue(typ, v string) any {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	}
+	return v
+}
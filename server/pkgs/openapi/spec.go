@@ -0,0 +1,158 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Document 为 OpenAPI 3 文档中用到的子集
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem 以小写的 HTTP 方法为 key
+type PathItem map[string]*OperationObject
+
+type OperationObject struct {
+	Tags        []string                  `json:"tags,omitempty"`
+	Summary     string                    `json:"summary,omitempty"`
+	Description string                    `json:"description,omitempty"`
+	Parameters  []ParameterObject         `json:"parameters,omitempty"`
+	RequestBody *RequestBody              `json:"requestBody,omitempty"`
+	Responses   map[string]ResponseObject `json:"responses"`
+	Security    []map[string][]string     `json:"security,omitempty"`
+}
+
+type ParameterObject struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type ResponseObject struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+// Spec 收集路由声明的元数据，生成文档并据此校验请求
+type Spec struct {
+	doc   Document
+	types map[string]reflect.Type
+
+	// OnInvalid 在请求未通过校验时调用，需自行终止请求
+	OnInvalid func(c *gin.Context, err error)
+
+	once sync.Once
+	raw  []byte
+}
+
+const bearerAuth = "bearerAuth"
+
+func NewSpec(title, version string) *Spec {
+	s := &Spec{
+		doc: Document{
+			OpenAPI: "3.0.3",
+			Info:    Info{Title: title, Version: version},
+			Paths:   map[string]*PathItem{},
+			Components: Components{
+				Schemas: map[string]*Schema{},
+				SecuritySchemes: map[string]SecurityScheme{
+					bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+				},
+			},
+		},
+		types: map[string]reflect.Type{},
+		OnInvalid: func(c *gin.Context, err error) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"code": http.StatusBadRequest, "msg": err.Error(), "data": nil})
+		},
+	}
+	s.doc.Components.Schemas["Error"] = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code": {Type: "integer", Description: "错误码"},
+			"msg":  {Type: "string", Description: "按 Accept-Language 本地化的错误信息"},
+			"data": {Nullable: true},
+		},
+		Required: []string{"code", "msg"},
+	}
+	return s
+}
+
+// Document 返回生成的文档，路由全部注册后调用
+func (s *Spec) Document() *Document {
+	return &s.doc
+}
+
+// Handler 输出 JSON 格式的文档，首次请求时序列化并缓存
+func (s *Spec) Handler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		s.once.Do(func() {
+			raw, err := json.Marshal(s.doc)
+			if err != nil {
+				panic(err)
+			}
+			s.raw = raw
+		})
+		c.Data(http.StatusOK, "application/json; charset=utf-8", s.raw)
+	}
+}
+
+func (s *Spec) addOperation(method, path string, op *OperationObject) {
+	item, ok := s.doc.Paths[path]
+	if !ok {
+		item = &PathItem{}
+		s.doc.Paths[path] = item
+	}
+	(*item)[method] = op
+}
+
+// envelope 生成 {code,msg,data} 包装后的响应结构
+func (s *Spec) envelope(data any) *Schema {
+	dataSchema := &Schema{Nullable: true}
+	if data != nil {
+		dataSchema = s.schemaOf(reflect.TypeOf(data))
+	}
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code": {Type: "integer", Enum: []any{200}},
+			"msg":  {Type: "string"},
+			"data": dataSchema,
+		},
+		Required: []string{"code", "msg", "data"},
+	}
+}
@@ -0,0 +1,20 @@
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed ui.html
+var uiHTML string
+
+// UIHandler 返回内置的文档浏览页面，specURL 为文档地址
+func UIHandler(specURL string) gin.HandlerFunc {
+	page := []byte(strings.ReplaceAll(uiHTML, "{{SPEC_URL}}", specURL))
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+	}
+}
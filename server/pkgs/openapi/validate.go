@@ -0,0 +1,223 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return e.Field + ": " + e.Reason
+}
+
+// validator 按声明的 query 参数与请求体结构校验请求，校验后请求体会被还原供 handler 读取
+func (s *Spec) validator(params []Param, body *Schema) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, p := range params {
+			if err := validateParam(c, p); err != nil {
+				s.OnInvalid(c, err)
+				return
+			}
+		}
+		if body != nil {
+			raw, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				s.OnInvalid(c, &ValidationError{Reason: "failed to read body"})
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(raw))
+			decoder := json.NewDecoder(bytes.NewReader(raw))
+			decoder.UseNumber()
+			var v any
+			if err := decoder.Decode(&v); err != nil {
+				s.OnInvalid(c, &ValidationError{Reason: "invalid json body"})
+				return
+			}
+			if err := s.validate(body, v, ""); err != nil {
+				s.OnInvalid(c, err)
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+func validateParam(c *gin.Context, p Param) error {
+	value, ok := c.GetQuery(p.Name)
+	if !ok || value == "" {
+		if p.Required {
+			return &ValidationError{Field: p.Name, Reason: "is required"}
+		}
+		return nil
+	}
+	switch p.Type {
+	case "integer":
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return &ValidationError{Field: p.Name, Reason: "must be an integer"}
+		}
+	case "number":
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return &ValidationError{Field: p.Name, Reason: "must be a number"}
+		}
+	case "boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return &ValidationError{Field: p.Name, Reason: "must be a boolean"}
+		}
+	}
+	if len(p.Enum) > 0 {
+		for _, e := range p.Enum {
+			if e == value {
+				return nil
+			}
+		}
+		return &ValidationError{Field: p.Name, Reason: "must be one of " + strings.Join(p.Enum, ", ")}
+	}
+	return nil
+}
+
+func (s *Spec) resolve(schema *Schema) *Schema {
+	for schema.Ref != "" {
+		schema = s.doc.Components.Schemas[strings.TrimPrefix(schema.Ref, refPrefix)]
+	}
+	return schema
+}
+
+// validate 校验 json 解码后的值，null 总是合法的，与 encoding/json 的行为保持一致
+func (s *Spec) validate(schema *Schema, v any, field string) error {
+	schema = s.resolve(schema)
+	if v == nil {
+		return nil
+	}
+	switch schema.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return &ValidationError{Field: field, Reason: "must be an object"}
+		}
+		for _, name := range schema.Required {
+			if _, ok := obj[name]; !ok {
+				return &ValidationError{Field: joinField(field, name), Reason: "is required"}
+			}
+		}
+		for name, value := range obj {
+			prop, ok := schema.Properties[name]
+			if !ok {
+				prop = schema.AdditionalProperties
+			}
+			if prop == nil {
+				continue
+			}
+			if err := s.validate(prop, value, joinField(field, name)); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return &ValidationError{Field: field, Reason: "must be an array"}
+		}
+		for i, item := range arr {
+			if err := s.validate(schema.Items, item, fmt.Sprintf("%s[%d]", field, i)); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return &ValidationError{Field: field, Reason: "must be a string"}
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, str); err != nil {
+				return &ValidationError{Field: field, Reason: "must be an RFC 3339 date-time"}
+			}
+		}
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return &ValidationError{Field: field, Reason: "must be an integer"}
+		}
+		if _, err := n.Int64(); err != nil {
+			return &ValidationError{Field: field, Reason: "must be an integer"}
+		}
+	case "number":
+		if _, ok := v.(json.Number); !ok {
+			return &ValidationError{Field: field, Reason: "must be a number"}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return &ValidationError{Field: field, Reason: "must be a boolean"}
+		}
+	}
+	if schema.omitEmpty && isZero(v) {
+		return nil
+	}
+	if err := validateEnum(schema, v, field); err != nil {
+		return err
+	}
+	return validateRange(schema, v, field)
+}
+
+func isZero(v any) bool {
+	switch v := v.(type) {
+	case string:
+		return v == ""
+	case json.Number:
+		f, err := v.Float64()
+		return err == nil && f == 0
+	case bool:
+		return !v
+	}
+	return false
+}
+
+func validateEnum(schema *Schema, v any, field string) error {
+	if len(schema.Enum) == 0 {
+		return nil
+	}
+	actual := fmt.Sprint(v)
+	for _, e := range schema.Enum {
+		if fmt.Sprint(e) == actual {
+			return nil
+		}
+	}
+	return &ValidationError{Field: field, Reason: fmt.Sprintf("must be one of %v", schema.Enum)}
+}
+
+func validateRange(schema *Schema, v any, field string) error {
+	n, ok := v.(json.Number)
+	if !ok {
+		return nil
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return nil
+	}
+	if schema.Minimum != nil && f < *schema.Minimum {
+		return &ValidationError{Field: field, Reason: fmt.Sprintf("must be >= %v", *schema.Minimum)}
+	}
+	if schema.Maximum != nil && f > *schema.Maximum {
+		return &ValidationError{Field: field, Reason: fmt.Sprintf("must be <= %v", *schema.Maximum)}
+	}
+	return nil
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
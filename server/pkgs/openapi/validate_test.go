@@ -0,0 +1,147 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testItem struct {
+	Name  string `json:"name" binding:"required"`
+	Count int    `json:"count" binding:"min=1,max=10"`
+}
+
+type testBody struct {
+	Title string            `json:"title" binding:"required"`
+	Kind  string            `json:"kind" binding:"oneof=a b"`
+	Ratio float64           `json:"ratio" binding:"gte=0,lte=1"`
+	Flag  bool              `json:"flag"`
+	At    time.Time         `json:"at"`
+	Items []testItem        `json:"items"`
+	Tags  map[string]string `json:"tags"`
+	Align string            `json:"align" binding:"omitempty,oneof=left right"`
+	Size  int               `json:"size" binding:"omitempty,min=8"`
+}
+
+func TestValidateBody(t *testing.T) {
+	tests := []struct {
+		name  string
+		body  string
+		ok    bool
+		field string
+	}{
+		{name: "valid", ok: true, body: `{"title":"t","kind":"a","ratio":0.5,"flag":true,"at":"2024-01-02T03:04:05Z","items":[{"name":"n","count":3}],"tags":{"k":"v"}}`},
+		{name: "null fields", ok: true, body: `{"title":"t","kind":null,"items":null}`},
+		{name: "invalid json", body: `{"title":`},
+		{name: "not an object", body: `[]`},
+		{name: "missing required", body: `{}`, field: "title"},
+		{name: "wrong type", body: `{"title":1}`, field: "title"},
+		{name: "enum", body: `{"title":"t","kind":"c"}`, field: "kind"},
+		{name: "below minimum", body: `{"title":"t","ratio":-0.1}`, field: "ratio"},
+		{name: "above maximum", body: `{"title":"t","ratio":1.5}`, field: "ratio"},
+		{name: "boolean", body: `{"title":"t","flag":"yes"}`, field: "flag"},
+		{name: "date-time", body: `{"title":"t","at":"yesterday"}`, field: "at"},
+		{name: "array", body: `{"title":"t","items":{}}`, field: "items"},
+		{name: "nested required", body: `{"title":"t","items":[{"name":"n","count":1},{"count":1}]}`, field: "items[1].name"},
+		{name: "nested integer", body: `{"title":"t","items":[{"name":"n","count":1.5}]}`, field: "items[0].count"},
+		{name: "nested range", body: `{"title":"t","items":[{"name":"n","count":11}]}`, field: "items[0].count"},
+		{name: "omitempty zero values", ok: true, body: `{"title":"t","align":"","size":0}`},
+		{name: "omitempty enum", body: `{"title":"t","align":"center"}`, field: "align"},
+		{name: "omitempty range", body: `{"title":"t","size":4}`, field: "size"},
+		{name: "map values", body: `{"title":"t","tags":{"k":1}}`, field: "tags.k"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, err := serve(Operation{Body: testBody{}}, http.MethodPost, "/", tt.body)
+			if tt.ok {
+				if status != http.StatusOK {
+					t.Fatalf("status = %d, err = %v; want 200", status, err)
+				}
+				return
+			}
+			if status != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", status)
+			}
+			if tt.field != "" && (err == nil || err.Field != tt.field) {
+				t.Fatalf("err = %v, want error on %s", err, tt.field)
+			}
+		})
+	}
+}
+
+func TestValidateQuery(t *testing.T) {
+	op := Operation{Query: []Param{
+		{Name: "uid", Required: true},
+		{Name: "page", Type: "integer"},
+		{Name: "ratio", Type: "number"},
+		{Name: "all", Type: "boolean"},
+		{Name: "resolve", Enum: []string{"urls"}},
+	}}
+	tests := []struct {
+		query string
+		field string
+	}{
+		{query: "uid=1&page=2&ratio=0.5&all=true&resolve=urls"},
+		{query: "uid=1&page="},
+		{query: "", field: "uid"},
+		{query: "uid=", field: "uid"},
+		{query: "uid=1&page=a", field: "page"},
+		{query: "uid=1&page=1.5", field: "page"},
+		{query: "uid=1&ratio=x", field: "ratio"},
+		{query: "uid=1&all=maybe", field: "all"},
+		{query: "uid=1&resolve=all", field: "resolve"},
+	}
+	for _, tt := range tests {
+		status, err := serve(op, http.MethodGet, "/?"+tt.query, "")
+		if tt.field == "" {
+			if status != http.StatusOK {
+				t.Errorf("%q: status = %d, err = %v; want 200", tt.query, status, err)
+			}
+			continue
+		}
+		if status != http.StatusBadRequest || err == nil || err.Field != tt.field {
+			t.Errorf("%q: status = %d, err = %v; want 400 on %s", tt.query, status, err, tt.field)
+		}
+	}
+}
+
+// 校验后 handler 仍能读取完整的请求体
+func TestValidateRestoresBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	spec := NewSpec("test", "1")
+	body := `{"title":"t"}`
+	var got string
+	spec.Router(&engine.RouterGroup, "", false).POST("/", Operation{Body: testBody{}}, func(c *gin.Context) {
+		raw, _ := io.ReadAll(c.Request.Body)
+		got = string(raw)
+	})
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body)))
+	if got != body {
+		t.Fatalf("body = %q, want %q", got, body)
+	}
+}
+
+// serve 注册路由并发送请求，返回状态码与校验错误
+func serve(op Operation, method, target, body string) (int, *ValidationError) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	spec := NewSpec("test", "1")
+	var verr *ValidationError
+	spec.OnInvalid = func(c *gin.Context, err error) {
+		verr, _ = err.(*ValidationError)
+		c.AbortWithStatus(http.StatusBadRequest)
+	}
+	router := spec.Router(&engine.RouterGroup, "", false)
+	router.handle(method, "/", op, []gin.HandlerFunc{func(c *gin.Context) { c.Status(http.StatusOK) }})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w.Code, verr
+}
@@ -0,0 +1,72 @@
+package oss
+
+import (
+	"sync"
+	"time"
+)
+
+// urlCache 缓存已签发的预签名 URL，避免同一对象被反复签名。
+// 条目数不超过 maxEntries，已满时先清理过期的 URL，仍然已满则淘汰最早过期的 URL
+type urlCache struct {
+	mu         sync.Mutex
+	urls       map[string]PresignedURL
+	maxEntries int
+	lastSweep  time.Time
+}
+
+const (
+	cacheSweepInterval = time.Minute
+	cacheMaxEntries    = 10000
+)
+
+func newURLCache(maxEntries int) *urlCache {
+	return &urlCache{urls: map[string]PresignedURL{}, maxEntries: maxEntries, lastSweep: time.Now()}
+}
+
+// get 仅返回剩余有效期不少于 minRemaining 的 URL，保证客户端拿到后仍有足够时间使用
+func (c *urlCache) get(key string, minRemaining time.Duration) (PresignedURL, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	url, ok := c.urls[key]
+	if !ok || time.Until(url.Expiration) < minRemaining {
+		return PresignedURL{}, false
+	}
+	return url, true
+}
+
+func (c *urlCache) set(key string, url PresignedURL) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	_, exists := c.urls[key]
+	full := !exists && len(c.urls) >= c.maxEntries
+	if full || now.Sub(c.lastSweep) >= cacheSweepInterval {
+		c.sweep(now)
+	}
+	if !exists && len(c.urls) >= c.maxEntries {
+		c.evict()
+	}
+	c.urls[key] = url
+}
+
+func (c *urlCache) sweep(now time.Time) {
+	for k, u := range c.urls {
+		if !u.Expiration.After(now) {
+			delete(c.urls, k)
+		}
+	}
+	c.lastSweep = now
+}
+
+// evict 淘汰最早过期的 URL
+func (c *urlCache) evict() {
+	oldest := ""
+	for k, u := range c.urls {
+		if oldest == "" || u.Expiration.Before(c.urls[oldest].Expiration) {
+			oldest = k
+		}
+	}
+	delete(c.urls, oldest)
+}
@@ -0,0 +1,84 @@
+package oss
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestURLCacheGet(t *testing.T) {
+	cache := newURLCache(10)
+	url := PresignedURL{URL: "https://example.com/a", Expiration: time.Now().Add(10 * time.Minute)}
+	cache.set("a", url)
+
+	if got, ok := cache.get("a", 5*time.Minute); !ok || got != url {
+		t.Fatalf("get = %v, %v; want %v, true", got, ok, url)
+	}
+	if _, ok := cache.get("a", 15*time.Minute); ok {
+		t.Fatal("expected miss when remaining lifetime is too short")
+	}
+	if _, ok := cache.get("b", 0); ok {
+		t.Fatal("expected miss for unknown key")
+	}
+}
+
+func TestURLCacheExpired(t *testing.T) {
+	cache := newURLCache(10)
+	cache.set("a", PresignedURL{URL: "https://example.com/a", Expiration: time.Now().Add(-time.Second)})
+	if _, ok := cache.get("a", 0); ok {
+		t.Fatal("expected miss for expired url")
+	}
+
+	// 超过清理间隔后写入时清理过期的 URL
+	cache.lastSweep = time.Now().Add(-cacheSweepInterval)
+	cache.set("b", PresignedURL{URL: "https://example.com/b", Expiration: time.Now().Add(time.Minute)})
+	if _, ok := cache.urls["a"]; ok {
+		t.Fatal("expired url was not swept")
+	}
+}
+
+func TestURLCacheReplace(t *testing.T) {
+	cache := newURLCache(1)
+	cache.set("a", PresignedURL{URL: "https://example.com/old", Expiration: time.Now().Add(time.Minute)})
+	url := PresignedURL{URL: "https://example.com/new", Expiration: time.Now().Add(time.Hour)}
+	cache.set("a", url)
+	if got, ok := cache.get("a", 0); !ok || got != url {
+		t.Fatalf("get = %v, %v; want %v, true", got, ok, url)
+	}
+}
+
+func TestURLCacheBounded(t *testing.T) {
+	const size = 3
+	cache := newURLCache(size)
+	now := time.Now()
+	for i := 0; i < size; i++ {
+		key := strconv.Itoa(i)
+		cache.set(key, PresignedURL{URL: key, Expiration: now.Add(time.Duration(i+1) * time.Hour)})
+	}
+
+	// 已满时淘汰最早过期的 URL
+	cache.set("new", PresignedURL{URL: "new", Expiration: now.Add(time.Hour)})
+	if len(cache.urls) != size {
+		t.Fatalf("len = %d, want %d", len(cache.urls), size)
+	}
+	if _, ok := cache.get("0", 0); ok {
+		t.Fatal("url expiring first was not evicted")
+	}
+	for _, key := range []string{"1", "2", "new"} {
+		if _, ok := cache.get(key, 0); !ok {
+			t.Fatalf("url %s was evicted", key)
+		}
+	}
+
+	// 已满时优先清理过期的 URL
+	cache.urls["1"] = PresignedURL{URL: "1", Expiration: now.Add(-time.Second)}
+	cache.set("newer", PresignedURL{URL: "newer", Expiration: now.Add(time.Minute)})
+	if _, ok := cache.urls["1"]; ok {
+		t.Fatal("expired url was not swept")
+	}
+	for _, key := range []string{"2", "new", "newer"} {
+		if _, ok := cache.get(key, 0); !ok {
+			t.Fatalf("url %s was evicted", key)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package oss
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss"
+	"go.uber.org/zap"
+)
+
+// OSS 分片上传的限制：除最后一片外每片不小于 100KB，最多 10000 片
+const (
+	MinPartSize = 100 << 10
+	MaxParts    = 10000
+)
+
+type PartMeta struct {
+	PartNumber   int32     `json:"part_number"`
+	ETag         string    `json:"etag"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// InitiateMultipartUpload 初始化分片上传并返回 upload id
+func (b *Bucket) InitiateMultipartUpload(ctx context.Context, objectkey string, contentType string) (string, error) {
+	result, err := b.client.InitiateMultipartUpload(ctx, &oss.InitiateMultipartUploadRequest{
+		Bucket:      oss.Ptr(b.name),
+		Key:         oss.Ptr(objectkey),
+		ContentType: oss.Ptr(contentType),
+	})
+	if err != nil {
+		zap.L().Error("failed to initiate multipart upload "+objectkey, zap.Error(err))
+		return "", fmt.Errorf("failed to initiate multipart upload %s: %w", objectkey, err)
+	}
+	return oss.ToString(result.UploadId), nil
+}
+
+// PresignUploadPart 为单个分片签发上传 URL，客户端需保存响应中的 ETag
+func (b *Bucket) PresignUploadPart(objectkey string, uploadID string, partNumber int32) (string, error) {
+	result, err := b.client.Presign(context.TODO(), &oss.UploadPartRequest{
+		Bucket:     oss.Ptr(b.name),
+		Key:        oss.Ptr(objectkey),
+		UploadId:   oss.Ptr(uploadID),
+		PartNumber: partNumber,
+	}, oss.PresignExpires(time.Duration(presignTTL.Load())))
+	if err != nil {
+		zap.L().Error("failed to upload part presign: ", zap.Error(err))
+		return "", fmt.Errorf("failed to upload part presign: %w", err)
+	}
+	return result.URL, nil
+}
+
+// ListParts 列出已上传的分片，上传中断后客户端据此跳过已完成的分片
+func (b *Bucket) ListParts(ctx context.Context, objectkey string, uploadID string) ([]PartMeta, error) {
+	parts := []PartMeta{}
+	p := b.client.NewListPartsPaginator(&oss.ListPartsRequest{
+		Bucket:   oss.Ptr(b.name),
+		Key:      oss.Ptr(objectkey),
+		UploadId: oss.Ptr(uploadID),
+	})
+	for p.HasNext() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list parts of %s: %w", objectkey, err)
+		}
+		for _, part := range page.Parts {
+			parts = append(parts, PartMeta{
+				PartNumber:   part.PartNumber,
+				ETag:         oss.ToString(part.ETag),
+				Size:         part.Size,
+				LastModified: oss.ToTime(part.LastModified),
+			})
+		}
+	}
+	return parts, nil
+}
+
+// CompleteMultipartUpload 由服务端按分片号合并全部已上传分片
+func (b *Bucket) CompleteMultipartUpload(ctx context.Context, objectkey string, uploadID string) error {
+	if _, err := b.client.CompleteMultipartUpload(ctx, &oss.CompleteMultipartUploadRequest{
+		Bucket:      oss.Ptr(b.name),
+		Key:         oss.Ptr(objectkey),
+		UploadId:    oss.Ptr(uploadID),
+		CompleteAll: oss.Ptr("yes"),
+	}); err != nil {
+		zap.L().Error("failed to complete multipart upload "+objectkey, zap.Error(err))
+		return fmt.Errorf("failed to complete multipart upload %s: %w", objectkey, err)
+	}
+	return nil
+}
+
+func (b *Bucket) AbortMultipartUpload(ctx context.Context, objectkey string, uploadID string) error {
+	if _, err := b.client.AbortMultipartUpload(ctx, &oss.AbortMultipartUploadRequest{
+		Bucket:   oss.Ptr(b.name),
+		Key:      oss.Ptr(objectkey),
+		UploadId: oss.Ptr(uploadID),
+	}); err != nil {
+		zap.L().Error("failed to abort multipart upload "+objectkey, zap.Error(err))
+		return fmt.Errorf("failed to abort multipart upload %s: %w", objectkey, err)
+	}
+	return nil
+}
@@ -1,15 +1,20 @@
 package oss
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"io"
+	"net/http"
+	"sync/atomic"
 	"time"
 
 	openapi "github.com/alibabacloud-go/darabonba-openapi/v2/client"
 	sts20150401 "github.com/alibabacloud-go/sts-20150401/v2/client"
 	util "github.com/alibabacloud-go/tea-utils/v2/service"
 	"github.com/alibabacloud-go/tea/tea"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 
 	"github.com/aliyun/alibabacloud-oss-go-sdk-v2/oss"
@@ -22,33 +27,69 @@ import (
 // 	SecurityToken   string `json:"SecurityToken"`
 // }
 
+type Config struct {
+	AccessKeyID     string
+	AccessKeySecret string
+	RoleArn         string
+	Region          string // SDK 会在前面添加 "oss-" 前缀
+	Bucket          string
+	// Endpoint 请参考 https://api.aliyun.com/product/Sts
+	STSEndpoint string
+	PresignTTL  time.Duration
+}
+
 var (
-	region     string = "cn-shenzhen" // SDK 会在前面添加 "oss-" 前缀
-	bucketName string = "springboard"
-	cfg        *oss.Config
+	options       Config
+	cfg           *oss.Config
+	defaultBucket *Bucket
+
+	presignTTL atomic.Int64
 )
 
-func init() {
+// Bucket 封装单个存储桶上的对象操作，client 在多次调用间复用
+type Bucket struct {
+	name        string
+	client      *oss.Client
+	credentials credentials.CredentialsProvider
+	preview     *urlCache
+}
+
+// Default 返回 Init 配置的存储桶
+func Default() *Bucket {
+	return defaultBucket
+}
+
+func Init(c Config) {
+	options = c
+	SetPresignTTL(c.PresignTTL)
 	// SDK 会自动调用传入的函数刷新 credential
+	provider := credentials.NewCredentialsFetcherProvider(
+		credentials.CredentialsFetcherFunc(GenerateAssumeRoleCredential),
+	)
 	cfg = oss.LoadDefaultConfig().
-		WithCredentialsProvider(
-			credentials.NewCredentialsFetcherProvider(
-				credentials.CredentialsFetcherFunc(GenerateAssumeRoleCredential),
-			),
-		).
-		WithRegion(region)
+		WithCredentialsProvider(provider).
+		WithRegion(c.Region).
+		// 上传预签名时将 Content-Length 纳入签名，客户端只能上传声明大小的文件
+		WithAdditionalHeaders([]string{"content-length"})
+	defaultBucket = &Bucket{
+		name:        c.Bucket,
+		client:      oss.NewClient(cfg),
+		credentials: provider,
+		preview:     newURLCache(cacheMaxEntries),
+	}
+}
+
+// SetPresignTTL 调整预签名 URL 的有效期，支持配置热更新
+func SetPresignTTL(ttl time.Duration) {
+	presignTTL.Store(int64(ttl))
 }
 
 func GenerateAssumeRoleCredential(ctx context.Context) (credentials.Credentials, error) {
-	accessKeyId := os.Getenv("OSSAccessKeyId")
-	accessKeySecret := os.Getenv("OSSAccessKeySecret")
-	roleArn := os.Getenv("OSSRoleArn")
 	config := &openapi.Config{
-		AccessKeyId:     tea.String(accessKeyId),
-		AccessKeySecret: tea.String(accessKeySecret),
+		AccessKeyId:     tea.String(options.AccessKeyID),
+		AccessKeySecret: tea.String(options.AccessKeySecret),
 	}
-	// Endpoint 请参考 https://api.aliyun.com/product/Sts
-	config.Endpoint = tea.String("sts.cn-hangzhou.aliyuncs.com")
+	config.Endpoint = tea.String(options.STSEndpoint)
 	client, err := sts20150401.NewClient(config)
 	if err != nil {
 		zap.L().Error("Failed to create STS client", zap.Error(err))
@@ -57,7 +98,7 @@ func GenerateAssumeRoleCredential(ctx context.Context) (credentials.Credentials,
 
 	request := &sts20150401.AssumeRoleRequest{
 		DurationSeconds: tea.Int64(3600),
-		RoleArn:         tea.String(roleArn),
+		RoleArn:         tea.String(options.RoleArn),
 		RoleSessionName: tea.String("springboard"),
 	}
 	response, err := client.AssumeRoleWithOptions(request, &util.RuntimeOptions{})
@@ -87,40 +128,180 @@ func GenerateAssumeRoleCredential(ctx context.Context) (credentials.Credentials,
 	}, nil
 }
 
-func PresignPreviewUrl(objectkey string) (string, error) {
-	client := oss.NewClient(cfg)
+type PresignedURL struct {
+	URL        string    `json:"url"`
+	Expiration time.Time `json:"expires_at"`
+}
 
-	result, err := client.Presign(context.TODO(), &oss.GetObjectRequest{
-		Bucket: oss.Ptr(bucketName),
+// PresignPreviewUrl 签发下载 URL，同一对象在剩余有效期足够时复用已签发的 URL。
+// URL 由 STS 临时凭证签名，凭证过期后随之失效，因此有效期不超过签名时凭证的过期时间
+func (b *Bucket) PresignPreviewUrl(objectkey string) (PresignedURL, error) {
+	ttl := time.Duration(presignTTL.Load())
+	if cached, ok := b.preview.get(objectkey, ttl/3); ok {
+		return cached, nil
+	}
+
+	// 在签名前读取凭证，签名时若凭证已刷新，实际使用的凭证只会更晚过期
+	creds, err := b.credentials.GetCredentials(context.TODO())
+	if err != nil {
+		return PresignedURL{}, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	result, err := b.client.Presign(context.TODO(), &oss.GetObjectRequest{
+		Bucket: oss.Ptr(b.name),
 		Key:    oss.Ptr(objectkey),
-	}, oss.PresignExpires(30*time.Minute))
+	}, oss.PresignExpires(ttl))
 
 	if err != nil {
 		zap.L().Error("failed to get object "+objectkey+" presign: %w", zap.Error(err))
-		return "", fmt.Errorf("failed to get object %s presign: %w", objectkey, err)
+		return PresignedURL{}, fmt.Errorf("failed to get object %s presign: %w", objectkey, err)
 	}
 
-	return result.URL, nil
+	presigned := PresignedURL{URL: result.URL, Expiration: result.Expiration}
+	if creds.Expires != nil && creds.Expires.Before(presigned.Expiration) {
+		presigned.Expiration = *creds.Expires
+	}
+	b.preview.set(objectkey, presigned)
+	return presigned, nil
 }
 
-func PresignUploadUrl(objectkey string, contentType string) (string, error) {
-	client := oss.NewClient(cfg)
-
-	result, err := client.Presign(context.TODO(), &oss.PutObjectRequest{
-		Bucket:      oss.Ptr(bucketName),
-		Key:         oss.Ptr(objectkey),
-		ContentType: oss.Ptr(contentType),
-	}, oss.PresignExpires(30*time.Minute))
+// PresignUploadUrl 返回上传 URL 以及客户端上传时必须携带的请求头
+func (b *Bucket) PresignUploadUrl(objectkey string, contentType string, size int64) (string, map[string]string, error) {
+	result, err := b.client.Presign(context.TODO(), &oss.PutObjectRequest{
+		Bucket:        oss.Ptr(b.name),
+		Key:           oss.Ptr(objectkey),
+		ContentType:   oss.Ptr(contentType),
+		ContentLength: oss.Ptr(size),
+	}, oss.PresignExpires(time.Duration(presignTTL.Load())))
 
 	if err != nil {
 		zap.L().Error("failed to put object presign: ", zap.Error(err))
-		return "", fmt.Errorf("failed to put object presign: %w", err)
+		return "", nil, fmt.Errorf("failed to put object presign: %w", err)
 	}
 
-	return result.URL, nil
+	return result.URL, result.SignedHeaders, nil
 }
 
-func GenerateUniqueKey(filename string) string {
-	timestamp := time.Now().Unix()
-	return fmt.Sprintf("uploads/%d_%s", timestamp, filename)
+// GenerateUniqueKey 生成 uploads/<purpose>/<owner>/<uuid><ext> 格式的 key，
+// 不使用客户端提供的文件名，避免路径穿越与同名覆盖
+func GenerateUniqueKey(purpose, owner, ext string) string {
+	return fmt.Sprintf("uploads/%s/%s/%s%s", purpose, owner, uuid.New().String(), ext)
+}
+
+var ErrObjectNotFound = errors.New("object not found")
+
+type ObjectMeta struct {
+	Key          string
+	Size         int64
+	ContentType  string
+	LastModified time.Time
+}
+
+// HeadObject 获取对象元信息，对象不存在时返回 ErrObjectNotFound
+func (b *Bucket) HeadObject(ctx context.Context, objectkey string) (ObjectMeta, error) {
+	result, err := b.client.HeadObject(ctx, &oss.HeadObjectRequest{
+		Bucket: oss.Ptr(b.name),
+		Key:    oss.Ptr(objectkey),
+	})
+	if err != nil {
+		var serr *oss.ServiceError
+		if errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound {
+			return ObjectMeta{}, ErrObjectNotFound
+		}
+		return ObjectMeta{}, fmt.Errorf("failed to head object %s: %w", objectkey, err)
+	}
+
+	return ObjectMeta{
+		Key:          objectkey,
+		Size:         result.ContentLength,
+		ContentType:  oss.ToString(result.ContentType),
+		LastModified: oss.ToTime(result.LastModified),
+	}, nil
+}
+
+// GetObject 读取对象内容，limit 为允许读取的最大字节数
+func (b *Bucket) GetObject(ctx context.Context, objectkey string, limit int64) ([]byte, error) {
+	result, err := b.client.GetObject(ctx, &oss.GetObjectRequest{
+		Bucket: oss.Ptr(b.name),
+		Key:    oss.Ptr(objectkey),
+	})
+	if err != nil {
+		var serr *oss.ServiceError
+		if errors.As(err, &serr) && serr.StatusCode == http.StatusNotFound {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("failed to get object %s: %w", objectkey, err)
+	}
+	defer result.Body.Close()
+
+	if result.ContentLength > limit {
+		return nil, fmt.Errorf("object %s exceeds %d bytes", objectkey, limit)
+	}
+	body, err := io.ReadAll(io.LimitReader(result.Body, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read object %s: %w", objectkey, err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("object %s exceeds %d bytes", objectkey, limit)
+	}
+	return body, nil
+}
+
+func (b *Bucket) PutObject(ctx context.Context, objectkey string, contentType string, body []byte) error {
+	if _, err := b.client.PutObject(ctx, &oss.PutObjectRequest{
+		Bucket:        oss.Ptr(b.name),
+		Key:           oss.Ptr(objectkey),
+		ContentType:   oss.Ptr(contentType),
+		ContentLength: oss.Ptr(int64(len(body))),
+		Body:          bytes.NewReader(body),
+	}); err != nil {
+		zap.L().Error("failed to put object "+objectkey, zap.Error(err))
+		return fmt.Errorf("failed to put object %s: %w", objectkey, err)
+	}
+	return nil
+}
+
+// ListObjects 遍历 prefix 下的全部对象
+func (b *Bucket) ListObjects(ctx context.Context, prefix string, fn func(ObjectMeta) error) error {
+	p := b.client.NewListObjectsV2Paginator(&oss.ListObjectsV2Request{
+		Bucket: oss.Ptr(b.name),
+		Prefix: oss.Ptr(prefix),
+	})
+	for p.HasNext() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to list objects under %s: %w", prefix, err)
+		}
+		for _, obj := range page.Contents {
+			if err := fn(ObjectMeta{
+				Key:          oss.ToString(obj.Key),
+				Size:         obj.Size,
+				LastModified: oss.ToTime(obj.LastModified),
+			}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 单次 DeleteMultipleObjects 最多删除 1000 个对象
+const maxDeleteBatch = 1000
+
+func (b *Bucket) DeleteObjects(ctx context.Context, objectkeys []string) error {
+	for start := 0; start < len(objectkeys); start += maxDeleteBatch {
+		end := min(start+maxDeleteBatch, len(objectkeys))
+		objects := make([]oss.DeleteObject, 0, end-start)
+		for _, key := range objectkeys[start:end] {
+			objects = append(objects, oss.DeleteObject{Key: oss.Ptr(key)})
+		}
+		if _, err := b.client.DeleteMultipleObjects(ctx, &oss.DeleteMultipleObjectsRequest{
+			Bucket:  oss.Ptr(b.name),
+			Objects: objects,
+			Quiet:   true,
+		}); err != nil {
+			zap.L().Error("failed to delete objects", zap.Error(err))
+			return fmt.Errorf("failed to delete objects: %w", err)
+		}
+	}
+	return nil
 }
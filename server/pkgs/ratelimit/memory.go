@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	ts     time.Time
+	rate   float64
+	burst  int
+}
+
+func (b *bucket) refill(now time.Time) float64 {
+	return math.Min(float64(b.burst), b.tokens+now.Sub(b.ts).Seconds()*b.rate)
+}
+
+// MemoryLimiter 为进程内的令牌桶，仅在单副本内生效，用作 Redis 不可用时的兜底
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+const sweepInterval = time.Minute
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: map[string]*bucket{}, lastSweep: time.Now()}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	return l.take(key, rate, burst, 1)
+}
+
+func (l *MemoryLimiter) Peek(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	return l.take(key, rate, burst, 0)
+}
+
+// take 在桶中至少有一个令牌时放行并扣除 cost 个令牌
+func (l *MemoryLimiter) take(key string, rate float64, burst int, cost float64) (bool, time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), ts: now}
+		l.buckets[key] = b
+	}
+	b.rate, b.burst = rate, burst
+	b.tokens = b.refill(now)
+	b.ts = now
+	if b.tokens >= 1 {
+		b.tokens -= cost
+		return true, 0, nil
+	}
+	retryAfter := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+	return false, retryAfter, nil
+}
+
+// sweep 清理已经补满的桶，避免按 IP 限流时 map 无限增长
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < sweepInterval {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		if b.refill(now) >= float64(b.burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Limiter 为令牌桶限流器，rate 为每秒补充的令牌数，burst 为桶容量
+type Limiter interface {
+	Allow(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+	// Peek 与 Allow 的判断相同但不消耗令牌
+	Peek(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error)
+}
+
+// fallbackLimiter 优先使用 primary，出错时退回 secondary
+type fallbackLimiter struct {
+	primary   Limiter
+	secondary Limiter
+}
+
+// WithFallback 在 primary 不可用（例如 Redis 宕机）时使用 secondary 继续限流
+func WithFallback(primary, secondary Limiter) Limiter {
+	return &fallbackLimiter{primary: primary, secondary: secondary}
+}
+
+func (l *fallbackLimiter) Allow(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	allowed, retryAfter, err := l.primary.Allow(ctx, key, rate, burst)
+	if err == nil {
+		return allowed, retryAfter, nil
+	}
+	zap.L().Warn("primary rate limiter failed, falling back", zap.String("key", key), zap.Error(err))
+	return l.secondary.Allow(ctx, key, rate, burst)
+}
+
+func (l *fallbackLimiter) Peek(ctx context.Context, key string, rate float64, burst int) (bool, time.Duration, error) {
+	allowed, retryAfter, err := l.primary.Peek(ctx, key, rate, burst)
+	if err == nil {
+		return allowed, retryAfter, nil
+	}
+	zap.L().Warn("primary rate limiter failed, falling back", zap.String("key", key), zap.Error(err))
+	return l.secondary.Peek(ctx, key, rate, burst)
+}
@@ -0,0 +1,142 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// testLimiter 在 Limiter 之上检查公共的令牌桶行为，内存与 Redis 实现共用
+func testLimiter(t *testing.T, l Limiter, prefix string) {
+	ctx := context.Background()
+
+	t.Run("burst", func(t *testing.T) {
+		key := prefix + "burst"
+		for i := 0; i < 3; i++ {
+			if ok, _, err := l.Allow(ctx, key, 0.1, 3); err != nil || !ok {
+				t.Fatalf("request %d: allowed = %v, err = %v; want allowed", i, ok, err)
+			}
+		}
+		ok, retryAfter, err := l.Allow(ctx, key, 0.1, 3)
+		if err != nil || ok {
+			t.Fatalf("allowed = %v, err = %v; want denied", ok, err)
+		}
+		// 每 10 秒补充一个令牌
+		if retryAfter <= 9*time.Second || retryAfter > 10*time.Second {
+			t.Fatalf("retryAfter = %v, want about 10s", retryAfter)
+		}
+	})
+
+	t.Run("refill", func(t *testing.T) {
+		key := prefix + "refill"
+		if ok, _, _ := l.Allow(ctx, key, 50, 1); !ok {
+			t.Fatal("first request denied")
+		}
+		if ok, _, _ := l.Allow(ctx, key, 50, 1); ok {
+			t.Fatal("second request allowed before refill")
+		}
+		time.Sleep(50 * time.Millisecond)
+		if ok, _, _ := l.Allow(ctx, key, 50, 1); !ok {
+			t.Fatal("request denied after refill")
+		}
+	})
+
+	t.Run("peek", func(t *testing.T) {
+		key := prefix + "peek"
+		for i := 0; i < 5; i++ {
+			if ok, _, err := l.Peek(ctx, key, 0.1, 1); err != nil || !ok {
+				t.Fatalf("peek %d: allowed = %v, err = %v; want allowed", i, ok, err)
+			}
+		}
+		if ok, _, _ := l.Allow(ctx, key, 0.1, 1); !ok {
+			t.Fatal("peek consumed tokens")
+		}
+		if ok, retryAfter, _ := l.Peek(ctx, key, 0.1, 1); ok || retryAfter <= 0 {
+			t.Fatalf("peek = %v, %v; want denied with retryAfter", ok, retryAfter)
+		}
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		if ok, _, _ := l.Allow(ctx, prefix+"a", 0.1, 1); !ok {
+			t.Fatal("a denied")
+		}
+		if ok, _, _ := l.Allow(ctx, prefix+"b", 0.1, 1); !ok {
+			t.Fatal("b denied by bucket of a")
+		}
+	})
+}
+
+func TestMemoryLimiter(t *testing.T) {
+	testLimiter(t, NewMemoryLimiter(), "test:")
+}
+
+func TestMemoryLimiterSweep(t *testing.T) {
+	l := NewMemoryLimiter()
+	ctx := context.Background()
+	l.Allow(ctx, "full", 1000, 1)
+	l.Allow(ctx, "empty", 0.001, 1)
+
+	time.Sleep(5 * time.Millisecond)
+	l.lastSweep = time.Now().Add(-sweepInterval)
+	l.Peek(ctx, "other", 1, 1)
+	if _, ok := l.buckets["full"]; ok {
+		t.Fatal("refilled bucket was not swept")
+	}
+	if _, ok := l.buckets["empty"]; !ok {
+		t.Fatal("bucket still limiting was swept")
+	}
+}
+
+// TestRedisLimiter 需要可用的 Redis，通过 REDIS_ADDR 指定，未设置时跳过
+func TestRedisLimiter(t *testing.T) {
+	addr := os.Getenv("REDIS_ADDR")
+	if addr == "" {
+		t.Skip("REDIS_ADDR not set")
+	}
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	defer client.Close()
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("redis unavailable: %v", err)
+	}
+	prefix := "ratelimit_test:" + strconv.FormatInt(time.Now().UnixNano(), 10) + ":"
+	testLimiter(t, NewRedisLimiter(client), prefix)
+}
+
+type failingLimiter struct{}
+
+var errUnavailable = errors.New("unavailable")
+
+func (failingLimiter) Allow(context.Context, string, float64, int) (bool, time.Duration, error) {
+	return false, 0, errUnavailable
+}
+
+func (failingLimiter) Peek(context.Context, string, float64, int) (bool, time.Duration, error) {
+	return false, 0, errUnavailable
+}
+
+func TestFallbackLimiter(t *testing.T) {
+	ctx := context.Background()
+	secondary := NewMemoryLimiter()
+	l := WithFallback(failingLimiter{}, secondary)
+
+	if ok, _, err := l.Peek(ctx, "k", 0.1, 1); err != nil || !ok {
+		t.Fatalf("peek = %v, %v; want allowed by secondary", ok, err)
+	}
+	if ok, _, err := l.Allow(ctx, "k", 0.1, 1); err != nil || !ok {
+		t.Fatalf("allow = %v, %v; want allowed by secondary", ok, err)
+	}
+	if ok, _, err := l.Allow(ctx, "k", 0.1, 1); err != nil || ok {
+		t.Fatalf("allow = %v, %v; want denied by secondary", ok, err)
+	}
+
+	// primary 正常时不使用 secondary
+	l = WithFallback(NewMemoryLimiter(), secondary)
+	if ok, _, _ := l.Allow(ctx, "k", 0.1, 1); !ok {
+		t.Fatal("primary bucket was shared with secondary")
+	}
+}